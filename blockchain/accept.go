@@ -0,0 +1,104 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// maxFutureBlockTime is how many seconds past the clock a Block mined elsewhere may be stamped
+const maxFutureBlockTime = 2 * 60 * 60
+
+// ErrOrphanBlock is returned for a Block whose previous Block is not the last Block
+var ErrOrphanBlock = errors.New("block does not extend the last block")
+
+// AcceptBlock to connect a Block mined elsewhere, e.g. received from a peer, as the last Block, or as the genesis
+// Block of an empty BlockChain: it may not be stamped too far past the clock and must pass the checks of
+// connectChecked, which the blocks AddBlock and MineBlock mine go through as well
+func (chain *BlockChain) AcceptBlock(block *Block) error {
+	if block.Timestamp > Now()+maxFutureBlockTime {
+		return errors.New("block timestamp is out of range")
+	}
+	return chain.connectChecked(block)
+}
+
+// connectChecked to connect the Block once its hash meets the proof of work, its height and timestamp follow
+// the last Block and its transactions pass validateTransactions, a Block mined here is stamped no earlier than
+// the median time of the blocks before it, which the mock time may have moved past the clock
+func (chain *BlockChain) connectChecked(block *Block) error {
+	if !bytes.Equal(block.PreviousHash, chain.LastHash) {
+		return ErrOrphanBlock
+	}
+	header := block.Header()
+	if !header.CheckProofOfWork() {
+		return errors.New("block hash does not meet the proof of work")
+	}
+	context := chain.lockContext()
+	if block.Height != context.height {
+		return fmt.Errorf("block height %d does not follow height %d", block.Height, context.height-1)
+	}
+	if context.height > 0 && block.Timestamp <= context.medianTime {
+		return errors.New("block timestamp is out of range")
+	}
+	if err := chain.validateTransactions(block.Transactions, &context); err != nil {
+		return err
+	}
+	chain.connectBlock(block)
+	return nil
+}
+
+// GetBlock to find a stored Block by its hash, in the BlockChain or in a branch it switched away from
+func (chain *BlockChain) GetBlock(hash []byte) (*Block, error) {
+	// other keys of the badger.DB, like the last hash or pending transactions, are never as long as a hash
+	if len(hash) != sha256.Size {
+		return nil, errors.New("block is not in the blockchain")
+	}
+	var block *Block
+	err := chain.DataBase.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(hash)
+		if err != nil {
+			return err
+		}
+		encodedBlock, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		block = Deserialize(encodedBlock)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("block is not in the blockchain")
+	}
+	return block, nil
+}
+
+// HasBlock to check whether the Block of the hash is in the BlockChain, blocks of a branch it switched away
+// from are stored but not in it
+func (chain *BlockChain) HasBlock(hash []byte) bool {
+	block, err := chain.GetBlock(hash)
+	if err != nil {
+		return false
+	}
+	entry, ok := chain.heightEntry(block.Height)
+	return ok && bytes.Equal(entry.Hash, hash)
+}
+
+// BestHeight to get the height of the last Block, -1 for an empty BlockChain
+func (chain *BlockChain) BestHeight() int {
+	if chain.LastHash == nil {
+		return -1
+	}
+	return chain.Iterator().Next().Height
+}
+
+// GenesisHash to get the hash of the first Block, which peers on the same chain share, nil for an empty BlockChain
+func (chain *BlockChain) GenesisHash() []byte {
+	if chain.LastHash == nil {
+		return nil
+	}
+	entry, _ := chain.heightEntry(0)
+	return entry.Hash
+}
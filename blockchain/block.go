@@ -4,32 +4,63 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
+	"errors"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/params"
 )
 
-// Block structure for the Block type in the blockchain
+// mockTime stamps the blocks mined instead of the clock when it is not zero
+var mockTime int64
+
+// Block structure for the Block type in the blockchain, the Height counts the blocks
+// before it and the Timestamp is the Unix time it was mined at
 type Block struct {
 	Hash         []byte
 	Transactions []*Transaction
 	PreviousHash []byte
 	Nonce        int
+	Height       int
+	Timestamp    int64
+}
+
+// SetMockTime to stamp the blocks mined from now on with the Unix time instead of the clock,
+// only on networks whose blocks are generated on demand
+func SetMockTime(unix int64) error {
+	if !params.Active().OnDemand {
+		return errors.New("the time can only be overridden on " + params.Regtest.Name)
+	}
+	mockTime = unix
+	return nil
+}
+
+// Now to get the Unix time blocks are stamped with
+func Now() int64 {
+	if mockTime != 0 {
+		return mockTime
+	}
+	return time.Now().Unix()
 }
 
 // Genesis to create the genesis block in the blockchain
 func Genesis(coinbase *Transaction) *Block {
-	return CreateBlock([]*Transaction{coinbase}, []byte{})
+	return CreateBlock([]*Transaction{coinbase}, []byte{}, 0, Now())
 }
 
 // CreateBlock to create a block in the blockchain
-func CreateBlock(txns []*Transaction, previousHash []byte) *Block {
-	block := &Block{[]byte{}, txns, previousHash, 0}
+func CreateBlock(txns []*Transaction, previousHash []byte, height int, timestamp int64) *Block {
+	block := &Block{[]byte{}, txns, previousHash, 0, height, timestamp}
 	// block.DeriveHash()
-	proofOfWork := NewProof(block)
-	nonce, hash := proofOfWork.Run()
-	block.Nonce = nonce
-	block.Hash = hash
+	block.Mine()
 	return block
 }
 
+// Mine to run the proof of work of the Block, setting its nonce and hash
+func (block *Block) Mine() {
+	proofOfWork := NewProof(block)
+	block.Nonce, block.Hash = proofOfWork.Run()
+}
+
 // Serialize to serialize the input to BadgerDB
 func (block *Block) Serialize() []byte {
 	var result bytes.Buffer
@@ -48,12 +79,13 @@ func Deserialize(data []byte) *Block {
 	return &block
 }
 
-// HashTransactions to hash the transactions in the block
+// HashTransactions to hash the transactions in the block, signatures included, so that the proof of work
+// covers every byte of them and not just their IDs
 func (block *Block) HashTransactions() []byte {
 	var txHashes [][]byte
 	var txHash [32]byte
 	for _, tx := range block.Transactions {
-		txHashes = append(txHashes, tx.ID)
+		txHashes = append(txHashes, tx.Hash())
 	}
 	txHash = sha256.Sum256(bytes.Join(txHashes, []byte{}))
 	return txHash[:]
@@ -2,21 +2,38 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 
 	"github.com/dgraph-io/badger"
+	"github.com/the-code-innovator/go-blockchain/params"
+	"github.com/the-code-innovator/go-blockchain/wallet"
 )
 
-// constants used in the blockchain
-const (
-	dbPath      = "./tmp/blocks"
-	dbFile      = "./tmp/blocks/MANIFEST"
-	genesisData = "FIRST TRANSACTION FROM GENESIS."
+// locations of the badger.DB inside the data directory
+var (
+	dbPath = "./tmp/blocks"
+	dbFile = "./tmp/blocks/MANIFEST"
+)
+
+// SetDataDir to keep the BlockChain in the data directory, so that several chains can live side by side
+func SetDataDir(dir string) {
+	dbPath = filepath.Join(dir, "blocks")
+	dbFile = filepath.Join(dbPath, "MANIFEST")
+}
+
+// storageVersionKey holds the version of the format the blocks and indexes of the badger.DB are stored in,
+// storageVersion is that of this code, data stored in another format, like that from before scripts locked
+// outputs, is refused rather than read wrong
+var (
+	storageVersionKey = []byte("version")
+	storageVersion    = []byte{1}
 )
 
 // BlockChain structure for the BlockChain type in the blockchain
@@ -44,17 +61,20 @@ func InitBlockChain(address string) *BlockChain {
 	database, err := badger.Open(options)
 	PanicHandle(err)
 	err = database.Update(func(txn *badger.Txn) error {
-		coinBaseTransaction := CoinBaseTx(address, genesisData)
+		coinBaseTransaction := CoinBaseTx(address, params.Active().GenesisMessage)
 		genesis := Genesis(coinBaseTransaction)
 		fmt.Println("GENESIS CREATED.")
 		err := txn.Set(genesis.Hash, genesis.Serialize())
 		PanicHandle(err)
 		err = txn.Set([]byte("lh"), genesis.Hash)
+		PanicHandle(err)
 		lastHash = genesis.Hash
-		return err
+		return txn.Set(storageVersionKey, storageVersion)
 	})
 	PanicHandle(err)
 	blockchain := BlockChain{lastHash, database}
+	utxo := UTXO{&blockchain}
+	utxo.Reindex()
 	return &blockchain
 }
 
@@ -64,25 +84,62 @@ func ContinueBlockChain(address string) *BlockChain {
 		fmt.Println("NO EXISTING BLOCKCHAIN FOUND.\nCREATE ONE.")
 		runtime.Goexit()
 	}
+	blockchain := OpenBlockChain()
+	// a node may have created the database without downloading the genesis block yet
+	if blockchain.LastHash == nil {
+		blockchain.DataBase.Close()
+		fmt.Println("NO EXISTING BLOCKCHAIN FOUND.\nCREATE ONE.")
+		runtime.Goexit()
+	}
+	return blockchain
+}
+
+// OpenBlockChain to open the BlockChain of the data directory, creating an empty one without a genesis Block
+// when none was created, e.g. for a node to download from its peers, its LastHash is then nil, a BlockChain
+// stored in an older format is refused
+func OpenBlockChain() *BlockChain {
 	var lastHash []byte
 	options := badger.DefaultOptions("./option")
 	options.Dir = dbPath
 	options.ValueDir = dbPath
 	database, err := badger.Open(options)
 	PanicHandle(err)
+	current := true
 	err = database.Update(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte("lh"))
+		if err == badger.ErrKeyNotFound {
+			return txn.Set(storageVersionKey, storageVersion)
+		}
 		PanicHandle(err)
 		lastHash, err = item.ValueCopy(item.Key())
+		PanicHandle(err)
+		item, err = txn.Get(storageVersionKey)
+		if err == badger.ErrKeyNotFound {
+			current = false
+			return nil
+		}
+		PanicHandle(err)
+		version, err := item.ValueCopy(nil)
+		current = bytes.Equal(version, storageVersion)
 		return err
 	})
 	PanicHandle(err)
+	if !current {
+		database.Close()
+		fmt.Printf("THE BLOCKCHAIN IN %s WAS STORED BY AN OLDER VERSION.\nCREATE ONE IN ANOTHER DATA DIRECTORY.\n", dbPath)
+		runtime.Goexit()
+	}
 	blockchain := BlockChain{lastHash, database}
+	// indexes left behind the blocks are rebuilt on first use
+	utxo := UTXO{&blockchain}
+	if lastHash != nil && !utxo.IsCurrent() {
+		utxo.Reindex()
+	}
 	return &blockchain
 }
 
 // SignTransaction to sign the transaction that is added to a block
-func (chain *BlockChain) SignTransaction(tx *Transaction, privateKey ecdsa.PrivateKey) {
+func (chain *BlockChain) SignTransaction(tx *Transaction, privateKey wallet.PrivateKey) {
 	previousTXs := make(map[string]Transaction)
 	for _, in := range tx.Inputs {
 		previousTX, err := chain.FindTransaction(in.ID)
@@ -92,6 +149,32 @@ func (chain *BlockChain) SignTransaction(tx *Transaction, privateKey ecdsa.Priva
 	tx.Sign(privateKey, previousTXs)
 }
 
+// SignTransactionWithWallets to sign every input of the transaction with the wallet owning the output it spends
+func (chain *BlockChain) SignTransactionWithWallets(tx *Transaction, wallets *wallet.Wallets) {
+	// an input may spend an output of a transaction still waiting in the pool
+	previousTXs, err := chain.TxPool().previousTransactions(tx)
+	PanicHandle(err)
+	if err := tx.signWithWallets(wallets, previousTXs); err != nil {
+		log.Panic("ERROR: NO WALLET OWNS THE INPUT !")
+	}
+}
+
+// signWithWallets to sign every input with the key of the wallet owning the output it spends,
+// failing before any input is signed when no wallet owns one of them
+func (tx *Transaction) signWithWallets(wallets *wallet.Wallets, previousTXs map[string]Transaction) error {
+	previousOutputs := previousOutputsOf(tx, previousTXs)
+	for inID, previousOutput := range previousOutputs {
+		if _, _, ok := wallets.KeyFor(previousOutput.Address()); !ok {
+			return fmt.Errorf("no wallet owns input %d", inID)
+		}
+	}
+	for inID, previousOutput := range previousOutputs {
+		w, publicKey, _ := wallets.KeyFor(previousOutput.Address())
+		tx.SignInput(inID, w.PrivateKey, publicKey, previousOutput)
+	}
+	return nil
+}
+
 // VerifyTransaction to verify the transactions in a block
 func (chain *BlockChain) VerifyTransaction(tx *Transaction) bool {
 	previousTXs := make(map[string]Transaction)
@@ -103,115 +186,215 @@ func (chain *BlockChain) VerifyTransaction(tx *Transaction) bool {
 	return tx.Verify(previousTXs)
 }
 
-// FindTransaction to find a transaction by ID in the list of transactions in the blocks
+// FindTransaction to find a transaction by ID in the block the height index places it in
 func (chain *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
-	iterator := chain.Iterator()
-	for {
-		block := iterator.Next()
+	if height, ok := chain.txHeight(ID); ok {
+		entry, _ := chain.heightEntry(height)
+		block, err := chain.GetBlock(entry.Hash)
+		PanicHandle(err)
 		for _, tx := range block.Transactions {
 			if bytes.Equal(tx.ID, ID) {
 				return *tx, nil
 			}
 		}
-		if len(block.PreviousHash) == 0 {
-			break
-		}
 	}
 	return Transaction{}, errors.New("transaction doesn't exist")
 }
 
-// AddBlock to add a block to the existing BlockChain
-func (chain *BlockChain) AddBlock(transactions []*Transaction) {
-	var lastHash []byte
-	err := chain.DataBase.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("lh"))
+// AddBlock to add a block to the existing BlockChain, every transaction must be signed
+// and past its lock times at the height of the new block, pending transactions it mines
+// or conflicts with leave the pool, transactions without a coinbase get one claiming nothing
+func (chain *BlockChain) AddBlock(transactions []*Transaction) *Block {
+	block, err := chain.newTemplate(transactions)
+	if err != nil {
+		log.Panicf("ERROR: BLOCK IS NOT VALID: %v !", err)
+	}
+	return chain.mineTemplate(block)
+}
+
+// mineTemplate to run the proof of work of a checked template and connect it through connectChecked,
+// so that the blocks mined here pass the checks of those received from peers
+func (chain *BlockChain) mineTemplate(block *Block) *Block {
+	block.Mine()
+	if err := chain.connectChecked(block); err != nil {
+		log.Panicf("ERROR: BLOCK IS NOT VALID: %v !", err)
+	}
+	return block
+}
+
+// newTemplate to check the transactions of the next block and lay them out on top of the last block,
+// without running its proof of work
+func (chain *BlockChain) newTemplate(transactions []*Transaction) (*Block, error) {
+	context := chain.lockContext()
+	if len(transactions) == 0 || !transactions[0].IsCoinBase() {
+		transactions = append([]*Transaction{emptyCoinBase(context.height)}, transactions...)
+	}
+	if err := chain.validateTransactions(transactions, &context); err != nil {
+		return nil, err
+	}
+	// a block is never older than the median time of the blocks before it
+	timestamp := Now()
+	if timestamp <= context.medianTime {
+		timestamp = context.medianTime + 1
+	}
+	return &Block{[]byte{}, transactions, chain.LastHash, 0, context.height, timestamp}, nil
+}
+
+// validateTransactions to check every transaction of the next block in order, the block opens with its only
+// coinbase, which pays out no more than the subsidy and the fees the other transactions leave
+func (chain *BlockChain) validateTransactions(transactions []*Transaction, context *lockContext) error {
+	if len(transactions) == 0 || !transactions[0].IsCoinBase() {
+		return errors.New("block does not open with a coinbase")
+	}
+	view := newBlockView()
+	fees := 0
+	for txIndex, tx := range transactions {
+		if txIndex > 0 && tx.IsCoinBase() {
+			return errors.New("only the first transaction of a block can be a coinbase")
+		}
+		fee, err := chain.validateTransaction(tx, context, view)
+		if err != nil {
+			return err
+		}
+		if fees, err = addValue(fees, fee); err != nil {
+			return fmt.Errorf("fees: %v", err)
+		}
+	}
+	allowed, err := addValue(fees, params.Active().Subsidy)
+	if err != nil {
+		return fmt.Errorf("fees: %v", err)
+	}
+	if claimed, _ := transactions[0].outputTotal(); claimed > allowed {
+		return fmt.Errorf("coinbase pays out %d, more than the subsidy and fees of %d", claimed, allowed)
+	}
+	return nil
+}
+
+// connectBlock to store a validated block as the last block, updating the unspent outputs and the pool
+func (chain *BlockChain) connectBlock(block *Block) {
+	err := chain.DataBase.Update(func(txn *badger.Txn) error {
+		err := txn.Set(block.Hash, block.Serialize())
 		PanicHandle(err)
-		lastHash, err = item.ValueCopy(item.Key())
-		return err
-	})
-	PanicHandle(err)
-	newBlock := CreateBlock(transactions, lastHash)
-	err = chain.DataBase.Update(func(txn *badger.Txn) error {
-		err := txn.Set(newBlock.Hash, newBlock.Serialize())
+		err = txn.Set([]byte("lh"), block.Hash)
 		PanicHandle(err)
-		err = txn.Set([]byte("lh"), newBlock.Hash)
-		chain.LastHash = newBlock.Hash
-		return err
+		chain.LastHash = block.Hash
+		utxo := UTXO{chain}
+		if err := utxo.Update(txn, block); err != nil {
+			return err
+		}
+		return chain.TxPool().Update(txn, block)
 	})
 	PanicHandle(err)
 }
 
-// FindUnspentTransactions to find unspent transactions in the blockchain
-func (chain *BlockChain) FindUnspentTransactions(publicKeyHash []byte) []Transaction {
-	var unSpentTransactions []Transaction
-	spentTxns := make(map[string][]int)
-	iterator := chain.Iterator()
-	for {
-		block := iterator.Next()
-		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
-		OutputIterate:
-			for outID, out := range tx.Outputs {
-				if spentTxns[txID] != nil {
-					for _, spentOut := range spentTxns[txID] {
-						if spentOut == outID {
-							continue OutputIterate
-						}
-					}
-				}
-				if out.IsLockedWithKey(publicKeyHash) {
-					unSpentTransactions = append(unSpentTransactions, *tx)
-				}
+// validateTransaction to check the outputs, the spent outputs, the signatures and the lock times
+// of a transaction for the next block, returning the fee it leaves, the view collects the outputs
+// spent and created by the block so far
+func (chain *BlockChain) validateTransaction(tx *Transaction, context *lockContext, view *blockView) (int, error) {
+	if err := tx.checkID(); err != nil {
+		return 0, fmt.Errorf("transaction %x: %v", tx.ID, err)
+	}
+	if err := tx.checkDataOutputs(); err != nil {
+		return 0, fmt.Errorf("transaction %x: %v", tx.ID, err)
+	}
+	// a second transaction of the same ID would overwrite the unspent outputs of the first
+	if _, ok := context.confirmedHeight(tx.ID); ok {
+		return 0, fmt.Errorf("transaction %x is already in the blockchain", tx.ID)
+	}
+	outputTotal, err := tx.outputTotal()
+	if err != nil {
+		return 0, fmt.Errorf("transaction %x: %v", tx.ID, err)
+	}
+	fee := 0
+	if !tx.IsCoinBase() {
+		utxo := UTXO{chain}
+		inputTotal := 0
+		for inID, in := range tx.Inputs {
+			previousOutput, ok := view.spend(&utxo, in)
+			if !ok {
+				return 0, fmt.Errorf("transaction %x: input %d spends an output that is missing or already spent", tx.ID, inID)
+			}
+			if !tx.VerifyInput(inID, previousOutput) {
+				return 0, fmt.Errorf("transaction %x is not signed", tx.ID)
 			}
-			if !tx.IsCoinBase() {
-				for _, in := range tx.Inputs {
-					if in.UsesKey(publicKeyHash) {
-						inTxID := hex.EncodeToString(in.ID)
-						spentTxns[inTxID] = append(spentTxns[inTxID], in.Out)
-					}
-				}
+			if inputTotal, err = addValue(inputTotal, previousOutput.Value); err != nil {
+				return 0, fmt.Errorf("transaction %x: input %d: %v", tx.ID, inID, err)
 			}
 		}
-		if len(block.PreviousHash) == 0 {
-			break
+		if outputTotal > inputTotal {
+			return 0, fmt.Errorf("transaction %x pays out more than it spends", tx.ID)
+		}
+		if err := context.check(tx); err != nil {
+			return 0, fmt.Errorf("transaction %x: %v", tx.ID, err)
 		}
+		fee = inputTotal - outputTotal
 	}
-	return unSpentTransactions
-}
-
-// FindSpendableOutputs to find spendable outputs in the BlockChain
-func (chain *BlockChain) FindSpendableOutputs(publicKeyHash []byte, amount int) (int, map[string][]int) {
-	unSpentOutputs := make(map[string][]int)
-	unSpentTransactions := chain.FindUnspentTransactions(publicKeyHash)
-	accumulated := 0
-Work:
-	for _, tx := range unSpentTransactions {
-		txID := hex.EncodeToString(tx.ID)
-		for outID, output := range tx.Outputs {
-			if output.IsLockedWithKey(publicKeyHash) && accumulated < amount {
-				accumulated += output.Value
-				unSpentOutputs[txID] = append(unSpentOutputs[txID], outID)
-				if accumulated >= amount {
-					break Work
-				}
-			}
+	view.add(tx)
+	// a transaction spending its outputs later in the block sees it confirmed at the height of the block
+	context.confirmed[hex.EncodeToString(tx.ID)] = context.height
+	return fee, nil
+}
+
+// outputTotal to add up the values of the outputs, failing on a negative value or a total that overflows
+func (tx *Transaction) outputTotal() (int, error) {
+	total := 0
+	for outID, out := range tx.Outputs {
+		var err error
+		if total, err = addValue(total, out.Value); err != nil {
+			return 0, fmt.Errorf("output %d: %v", outID, err)
 		}
 	}
-	return accumulated, unSpentOutputs
+	return total, nil
 }
 
-// FindUnspentTransactionsOutputs to find unspent transaction outputs in the blockchain
-func (chain *BlockChain) FindUnspentTransactionsOutputs(publicKeyHash []byte) []TxOutput {
-	var unSpentTransactionOutputs []TxOutput
-	unSpentTransactions := chain.FindUnspentTransactions(publicKeyHash)
-	for _, tx := range unSpentTransactions {
-		for _, output := range tx.Outputs {
-			if output.IsLockedWithKey(publicKeyHash) {
-				unSpentTransactionOutputs = append(unSpentTransactionOutputs, output)
-			}
+// addValue to add a value to a running total, failing on a negative value or a total that overflows
+func addValue(total, value int) (int, error) {
+	if value < 0 {
+		return 0, errors.New("value is negative")
+	}
+	if total > math.MaxInt-value {
+		return 0, errors.New("values overflow")
+	}
+	return total + value, nil
+}
+
+// FindUnspentOutputs to find every unspent output locked with one of the public key hashes
+func (chain *BlockChain) FindUnspentOutputs(publicKeyHashes [][]byte) []UnspentOutput {
+	utxo := UTXO{chain}
+	return utxo.FindUnspentOutputs(publicKeyHashes)
+}
+
+// SelectCoins to choose the unspent outputs of the public key hashes that fund the amount
+func (chain *BlockChain) SelectCoins(publicKeyHashes [][]byte, amount int, selector CoinSelector) (Selection, error) {
+	return chain.selectCoins(publicKeyHashes, amount, selector, false)
+}
+
+// selectCoins to choose the unspent outputs that fund the amount, with spendPending the outputs
+// of pending transactions are candidates too
+func (chain *BlockChain) selectCoins(publicKeyHashes [][]byte, amount int, selector CoinSelector, spendPending bool) (Selection, error) {
+	pool := chain.TxPool()
+	// outputs already spent by a pending transaction would make the new one conflict with it
+	pending := pool.SpentOutputs()
+	var candidates []UnspentOutput
+	for _, candidate := range chain.FindUnspentOutputs(publicKeyHashes) {
+		if !pending[outpoint(candidate.ID, candidate.Out)] {
+			candidates = append(candidates, candidate)
 		}
 	}
-	return unSpentTransactionOutputs
+	if spendPending {
+		candidates = append(candidates, pool.UnspentOutputs(publicKeyHashes)...)
+	}
+	inputs, err := selector.Select(candidates, amount)
+	if err != nil {
+		return Selection{}, err
+	}
+	total := sumOutputs(inputs)
+	return Selection{inputs, total, amount, total - amount}, nil
+}
+
+// Exists to check whether a BlockChain has been created in the data directory
+func Exists() bool {
+	return badgerDBExists()
 }
 
 // badgerDBExists to check the availability of DataBase
@@ -228,6 +411,23 @@ func (chain *BlockChain) Iterator() *ChainIterator {
 	return iterator
 }
 
+// Blocks to collect the Blocks in the BlockChain ordered from the genesis Block to the last Block
+func (chain *BlockChain) Blocks() []*Block {
+	var blocks []*Block
+	iterator := chain.Iterator()
+	for {
+		block := iterator.Next()
+		blocks = append(blocks, block)
+		if len(block.PreviousHash) == 0 {
+			break
+		}
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks
+}
+
 // Next to navigate to the next Block in badgerDB
 func (iterator *ChainIterator) Next() *Block {
 	var block *Block
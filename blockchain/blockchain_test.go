@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/the-code-innovator/go-blockchain/params"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// newTestChain to create a regtest BlockChain in a temporary directory, along with wallets in memory holding
+// the key of the address its genesis Block pays
+func newTestChain(t *testing.T) (*BlockChain, *wallet.Wallets, string) {
+	t.Helper()
+	if err := params.SetNetwork("regtest"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetMockTime(0) })
+	SetDataDir(t.TempDir())
+	wallets := &wallet.Wallets{Wallets: make(map[string]*wallet.Wallet)}
+	address := addKey(wallets)
+	chain := InitBlockChain(address)
+	t.Cleanup(func() { chain.DataBase.Close() })
+	return chain, wallets, address
+}
+
+// addKey to add a new key to the wallets, returning its address
+func addKey(wallets *wallet.Wallets) string {
+	w := wallet.MakeWallet(wallet.P256)
+	address := string(w.Address())
+	wallets.Wallets[address] = w
+	return address
+}
+
+// testPayment to create a Transaction paying the amount from the address to the address to, the change
+// going back to the address from, signed by the wallets but neither pooled nor mined
+func testPayment(t *testing.T, chain *BlockChain, wallets *wallet.Wallets, from, to string, amount int, options TxOptions) *Transaction {
+	t.Helper()
+	return &rawPayment(t, chain, wallets, from, to, amount, options).Transaction
+}
+
+// rawPayment to create the payment of testPayment along with the outputs it spends
+func rawPayment(t *testing.T, chain *BlockChain, wallets *wallet.Wallets, from, to string, amount int, options TxOptions) *RawTransaction {
+	t.Helper()
+	raw, err := CreateRawTransaction(from, []Payment{{Address: to, Amount: amount}}, "", options, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Sign(wallets); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// resign to copy the raw transaction, let change alter the copy and sign it again
+func resign(t *testing.T, wallets *wallet.Wallets, raw *RawTransaction, change func(raw *RawTransaction)) *RawTransaction {
+	t.Helper()
+	copied := &RawTransaction{Transaction: raw.Transaction, PreviousOutputs: append([]TxOutput(nil), raw.PreviousOutputs...)}
+	copied.Transaction.Inputs = append([]TxInput(nil), raw.Transaction.Inputs...)
+	copied.Transaction.Outputs = append([]TxOutput(nil), raw.Transaction.Outputs...)
+	change(copied)
+	if _, err := copied.Sign(wallets); err != nil {
+		t.Fatal(err)
+	}
+	return copied
+}
+
+// spendOutput to create a raw Transaction spending the output of the pending or mined Transaction, paying
+// the amount to the address and leaving the fee, the rest goes back to the owner of the output
+func spendOutput(t *testing.T, wallets *wallet.Wallets, tx *Transaction, out int, to string, amount, fee int) *RawTransaction {
+	t.Helper()
+	previous := tx.Outputs[out]
+	raw := &RawTransaction{PreviousOutputs: []TxOutput{previous}}
+	raw.Transaction.Inputs = []TxInput{{ID: tx.ID, Out: out, Sequence: MaxSequence}}
+	raw.Transaction.Outputs = []TxOutput{*NewTxOutput(amount, to)}
+	if change := previous.Value - amount - fee; change > 0 {
+		raw.Transaction.Outputs = append(raw.Transaction.Outputs, *NewTxOutput(change, previous.Address()))
+	}
+	if _, err := raw.Sign(wallets); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// pending to add the Transaction to the pool, failing the test when it is refused
+func pending(t *testing.T, chain *BlockChain, tx *Transaction) {
+	t.Helper()
+	if err := chain.TxPool().Add(tx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// inPool to check whether the Transaction waits in the pool
+func inPool(chain *BlockChain, tx *Transaction) bool {
+	_, ok := chain.TxPool().Get(tx.ID)
+	return ok
+}
+
+// balance to add up the unspent outputs locked to the address
+func balance(t *testing.T, chain *BlockChain, address string) int {
+	t.Helper()
+	hash, _, err := wallet.DecodeAddress(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, unSpent := range chain.FindUnspentOutputs([][]byte{hash}) {
+		total += unSpent.Output.Value
+	}
+	return total
+}
+
+// forkBlock to mine a Block following the previous one whose coinbase pays the value to the address,
+// along with the transactions, without connecting it
+func forkBlock(previous *Block, address string, value int, transactions ...*Transaction) *Block {
+	coinbase := coinBase(address, fmt.Sprintf("FORK AT HEIGHT %d", previous.Height+1), value)
+	return CreateBlock(append([]*Transaction{coinbase}, transactions...), previous.Hash, previous.Height+1, previous.Timestamp+1)
+}
+
+// generateAt to mine the pending transactions into a Block stamped with the Unix time
+func generateAt(t *testing.T, chain *BlockChain, address string, unix int64) *Block {
+	t.Helper()
+	if err := SetMockTime(unix); err != nil {
+		t.Fatal(err)
+	}
+	return chain.GenerateBlock(address)
+}
@@ -0,0 +1,201 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// ErrInsufficientFunds error for candidates that cannot cover the target amount
+var ErrInsufficientFunds = errors.New("not enough funds")
+
+// UnspentOutput structure for an output that has not been spent by any transaction yet
+type UnspentOutput struct {
+	ID     []byte
+	Out    int
+	Output TxOutput
+}
+
+// Selection structure for the unspent outputs chosen to fund an amount
+type Selection struct {
+	Inputs []UnspentOutput
+	Total  int
+	Amount int
+	Change int
+}
+
+// CoinSelector interface for strategies choosing which unspent outputs fund a payment
+type CoinSelector interface {
+	Select(candidates []UnspentOutput, target int) ([]UnspentOutput, error)
+}
+
+// CoinSelectorNames lists the strategies understood by NewCoinSelector
+var CoinSelectorNames = []string{"bnb", "largest", "smallest", "random", "chain"}
+
+// NewCoinSelector to get a CoinSelector by name
+func NewCoinSelector(name string) (CoinSelector, error) {
+	switch name {
+	case "bnb", "":
+		return BranchAndBound{Fallback: LargestFirst{}}, nil
+	case "largest":
+		return LargestFirst{}, nil
+	case "smallest":
+		return SmallestFirst{}, nil
+	case "random":
+		return RandomImprove{}, nil
+	case "chain":
+		return ChainOrder{}, nil
+	}
+	return nil, fmt.Errorf("unknown coin selector %q", name)
+}
+
+// sumOutputs to add up the values of the unspent outputs
+func sumOutputs(outputs []UnspentOutput) int {
+	total := 0
+	for _, output := range outputs {
+		total += output.Output.Value
+	}
+	return total
+}
+
+// takeUntil to take outputs in the given order until the target is reached
+func takeUntil(ordered []UnspentOutput, target int) ([]UnspentOutput, error) {
+	var selected []UnspentOutput
+	accumulated := 0
+	for _, candidate := range ordered {
+		if accumulated >= target {
+			break
+		}
+		selected = append(selected, candidate)
+		accumulated += candidate.Output.Value
+	}
+	if accumulated < target {
+		return nil, ErrInsufficientFunds
+	}
+	return selected, nil
+}
+
+// ChainOrder selector taking outputs in the order they were found on the chain
+type ChainOrder struct{}
+
+// Select to take outputs in chain order until the target is reached
+func (ChainOrder) Select(candidates []UnspentOutput, target int) ([]UnspentOutput, error) {
+	return takeUntil(candidates, target)
+}
+
+// LargestFirst selector spending the biggest outputs first, keeping the input count low
+type LargestFirst struct{}
+
+// Select to take outputs from the largest to the smallest until the target is reached
+func (LargestFirst) Select(candidates []UnspentOutput, target int) ([]UnspentOutput, error) {
+	ordered := append([]UnspentOutput{}, candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Output.Value > ordered[j].Output.Value
+	})
+	return takeUntil(ordered, target)
+}
+
+// SmallestFirst selector spending the smallest outputs first, consolidating dust
+type SmallestFirst struct{}
+
+// Select to take outputs from the smallest to the largest until the target is reached
+func (SmallestFirst) Select(candidates []UnspentOutput, target int) ([]UnspentOutput, error) {
+	ordered := append([]UnspentOutput{}, candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Output.Value < ordered[j].Output.Value
+	})
+	return takeUntil(ordered, target)
+}
+
+// defaultBranchAndBoundTries bounds the search when BranchAndBound.Tries is not set
+const defaultBranchAndBoundTries = 100000
+
+// BranchAndBound selector searching for a set of outputs that matches the target exactly,
+// so that no change output is needed, and using Fallback when there is no such set
+type BranchAndBound struct {
+	Tries    int
+	Fallback CoinSelector
+}
+
+// Select to search depth first for outputs summing exactly to the target
+func (selector BranchAndBound) Select(candidates []UnspentOutput, target int) ([]UnspentOutput, error) {
+	tries := selector.Tries
+	if tries <= 0 {
+		tries = defaultBranchAndBoundTries
+	}
+	ordered := append([]UnspentOutput{}, candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Output.Value > ordered[j].Output.Value
+	})
+	// remaining[i] is the value still available from ordered[i:]
+	remaining := make([]int, len(ordered)+1)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + ordered[i].Output.Value
+	}
+	if remaining[0] < target {
+		return nil, ErrInsufficientFunds
+	}
+	var chosen []int
+	var search func(index, accumulated int) bool
+	search = func(index, accumulated int) bool {
+		if accumulated == target {
+			return true
+		}
+		tries--
+		if tries < 0 || index == len(ordered) || accumulated+remaining[index] < target {
+			return false
+		}
+		if value := ordered[index].Output.Value; accumulated+value <= target {
+			chosen = append(chosen, index)
+			if search(index+1, accumulated+value) {
+				return true
+			}
+			chosen = chosen[:len(chosen)-1]
+		}
+		return search(index+1, accumulated)
+	}
+	if search(0, 0) {
+		var selected []UnspentOutput
+		for _, index := range chosen {
+			selected = append(selected, ordered[index])
+		}
+		return selected, nil
+	}
+	if selector.Fallback == nil {
+		return nil, errors.New("no exact match for the amount")
+	}
+	return selector.Fallback.Select(candidates, target)
+}
+
+// RandomImprove selector picking outputs at random and then adding more while that brings
+// the change closer to the amount itself, leaving change outputs useful for later payments
+type RandomImprove struct{}
+
+// Select to pick random outputs until the target is reached and improve towards twice the target
+func (RandomImprove) Select(candidates []UnspentOutput, target int) ([]UnspentOutput, error) {
+	pool := append([]UnspentOutput{}, candidates...)
+	rand.Shuffle(len(pool), func(i, j int) {
+		pool[i], pool[j] = pool[j], pool[i]
+	})
+	selected, err := takeUntil(pool, target)
+	if err != nil {
+		return nil, err
+	}
+	accumulated := sumOutputs(selected)
+	ideal, limit := 2*target, 3*target
+	distance := func(total int) int {
+		if total > ideal {
+			return total - ideal
+		}
+		return ideal - total
+	}
+	for _, candidate := range pool[len(selected):] {
+		improved := accumulated + candidate.Output.Value
+		if improved <= limit && distance(improved) < distance(accumulated) {
+			selected = append(selected, candidate)
+			accumulated = improved
+		}
+	}
+	return selected, nil
+}
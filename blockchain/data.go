@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/the-code-innovator/go-blockchain/script"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// MaxDataSize is the most bytes a data carrier output can hold
+const MaxDataSize = 80
+
+// NewDataOutput to create a zero value output carrying the data, it can never be spent
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	if len(data) == 0 || len(data) > MaxDataSize {
+		return nil, fmt.Errorf("data must be between 1 and %d bytes", MaxDataSize)
+	}
+	return &TxOutput{Value: 0, ScriptPubKey: script.NullData(data)}, nil
+}
+
+// IsDataCarrier to check whether the output is provably unspendable, so it never joins the unspent outputs
+func (out *TxOutput) IsDataCarrier() bool {
+	return out.ScriptPubKey.IsUnspendable()
+}
+
+// Data to get the data carried by a data carrier output
+func (out *TxOutput) Data() ([]byte, bool) {
+	return out.ScriptPubKey.NullData()
+}
+
+// checkDataOutputs to check that data carrier outputs carry no value and at most MaxDataSize bytes
+func (tx *Transaction) checkDataOutputs() error {
+	for outID, out := range tx.Outputs {
+		if !out.IsDataCarrier() {
+			continue
+		}
+		data, ok := out.Data()
+		if out.Value != 0 || !ok || len(data) > MaxDataSize {
+			return fmt.Errorf("output %d is not a valid data carrier", outID)
+		}
+	}
+	return nil
+}
+
+// NewDataTransaction for creating a Transaction carrying the data, funded from the source addresses,
+// the inputs only pay for the Transaction to exist and come back whole as change
+func NewDataTransaction(sources []string, data []byte, options TxOptions, blockchain *BlockChain) (*Transaction, error) {
+	output, err := NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := wallet.CreateWallets()
+	if err != nil {
+		return nil, err
+	}
+	selector := options.Selector
+	if selector == nil {
+		selector, _ = NewCoinSelector("smallest")
+	}
+	selection, err := blockchain.SelectCoins(SourcePublicKeyHashes(wallets, sources), 1, selector)
+	if err != nil {
+		return nil, err
+	}
+	selection.Change, selection.Amount = selection.Total, 0
+	return signedTransaction(wallets, selection, nil, []TxOutput{*output}, options, blockchain), nil
+}
+
+// DataLocation structure for where data carried by an output sits in the BlockChain
+type DataLocation struct {
+	Block         *Block
+	Transaction   *Transaction
+	Output        int
+	Confirmations int
+}
+
+// FindData to find the earliest output carrying the data
+func (chain *BlockChain) FindData(data []byte) (DataLocation, bool) {
+	blocks := chain.Blocks()
+	for height, block := range blocks {
+		for _, tx := range block.Transactions {
+			for outID, out := range tx.Outputs {
+				if carried, ok := out.Data(); ok && bytes.Equal(carried, data) {
+					return DataLocation{block, tx, outID, len(blocks) - height}, true
+				}
+			}
+		}
+	}
+	return DataLocation{}, false
+}
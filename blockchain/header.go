@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// locatorDenseHashes is how many of the last hashes a block locator lists before its step starts doubling
+const locatorDenseHashes = 10
+
+// BlockHeader structure for the fields of a Block its proof of work commits to, its transactions standing
+// in by their hash, so that a chain of blocks can be checked before their transactions are downloaded
+type BlockHeader struct {
+	Hash             []byte
+	PreviousHash     []byte
+	TransactionsHash []byte
+	Nonce            int
+	Height           int
+	Timestamp        int64
+}
+
+// Header to get the BlockHeader of the Block
+func (block *Block) Header() BlockHeader {
+	return BlockHeader{block.Hash, block.PreviousHash, block.HashTransactions(), block.Nonce, block.Height, block.Timestamp}
+}
+
+// CheckProofOfWork to check that the hash of the header is the hash of its fields and meets the difficulty
+func (header *BlockHeader) CheckProofOfWork() bool {
+	var intHash big.Int
+	hash := sha256.Sum256(proofData(header.PreviousHash, header.TransactionsHash, header.Height, header.Timestamp, header.Nonce))
+	intHash.SetBytes(hash[:])
+	return bytes.Equal(hash[:], header.Hash) && intHash.Cmp(proofTarget()) == -1
+}
+
+// CheckHeaders to check that the headers form a chain following the previous header, nil for headers starting
+// at the genesis Block: each links to the one before at the next height and meets the proof of work
+func CheckHeaders(previous *BlockHeader, headers []BlockHeader) error {
+	for i := range headers {
+		header := &headers[i]
+		if previous == nil {
+			if len(header.PreviousHash) != 0 || header.Height != 0 {
+				return fmt.Errorf("header %x does not start a chain", header.Hash)
+			}
+		} else if !bytes.Equal(header.PreviousHash, previous.Hash) || header.Height != previous.Height+1 {
+			return fmt.Errorf("header %x does not follow header %x", header.Hash, previous.Hash)
+		}
+		if !header.CheckProofOfWork() {
+			return fmt.Errorf("header %x does not meet the proof of work", header.Hash)
+		}
+		previous = header
+	}
+	return nil
+}
+
+// BlockHashes to list the hashes of the Blocks from the last Block back to the genesis Block
+func (chain *BlockChain) BlockHashes() [][]byte {
+	if chain.LastHash == nil {
+		return nil
+	}
+	var hashes [][]byte
+	for height := chain.BestHeight(); height >= 0; height-- {
+		entry, _ := chain.heightEntry(height)
+		hashes = append(hashes, entry.Hash)
+	}
+	return hashes
+}
+
+// Locator to pick the hashes of a block locator out of hashes ordered from the last back to the first: the
+// last few, then ever sparser ones back to the first, so that a peer finds the last hash it shares in a few dozen
+func Locator(hashes [][]byte) [][]byte {
+	var locator [][]byte
+	step := 1
+	for i := 0; i < len(hashes); i += step {
+		locator = append(locator, hashes[i])
+		if len(locator) >= locatorDenseHashes {
+			step *= 2
+		}
+	}
+	if len(hashes) > 0 && !bytes.Equal(locator[len(locator)-1], hashes[len(hashes)-1]) {
+		locator = append(locator, hashes[len(hashes)-1])
+	}
+	return locator
+}
+
+// HeadersAfter to list, oldest first, the headers of at most max Blocks following the first Block of the
+// locator in the BlockChain, from the genesis Block on when the locator shares none with it
+func (chain *BlockChain) HeadersAfter(locator [][]byte, max int) []BlockHeader {
+	if chain.LastHash == nil {
+		return nil
+	}
+	// the locator runs from the last hash of the peer back, the first one in the BlockChain is the last shared
+	start := 0
+	for _, hash := range locator {
+		if block, err := chain.GetBlock(hash); err == nil {
+			if entry, ok := chain.heightEntry(block.Height); ok && bytes.Equal(entry.Hash, hash) {
+				start = block.Height + 1
+				break
+			}
+		}
+	}
+	var headers []BlockHeader
+	for height := start; len(headers) < max; height++ {
+		entry, ok := chain.heightEntry(height)
+		if !ok {
+			break
+		}
+		block, err := chain.GetBlock(entry.Hash)
+		PanicHandle(err)
+		headers = append(headers, block.Header())
+	}
+	return headers
+}
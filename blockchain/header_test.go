@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLocator(t *testing.T) {
+	var hashes [][]byte
+	for i := 0; i < 100; i++ {
+		hashes = append(hashes, []byte{byte(i)})
+	}
+	// ten dense hashes, then a step doubling from two, and the first hash last
+	want := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 11, 15, 23, 39, 71, 99}
+	locator := Locator(hashes)
+	if len(locator) != len(want) {
+		t.Fatalf("locator has %d hashes, want %d", len(locator), len(want))
+	}
+	for i, hash := range locator {
+		if hash[0] != want[i] {
+			t.Errorf("locator hash %d is %d, want %d", i, hash[0], want[i])
+		}
+	}
+	if locator := Locator(hashes[:5]); len(locator) != 5 {
+		t.Errorf("locator of 5 hashes has %d", len(locator))
+	}
+	if locator := Locator(nil); len(locator) != 0 {
+		t.Errorf("locator of no hashes has %d", len(locator))
+	}
+}
+
+func TestHeadersAfterRoundTrip(t *testing.T) {
+	chain, _, address := newTestChain(t)
+	for i := 0; i < 30; i++ {
+		chain.GenerateBlock(address)
+	}
+	hashes := chain.BlockHashes()
+	if len(hashes) != 31 || !bytes.Equal(hashes[0], chain.LastHash) {
+		t.Fatalf("block hashes do not run from the last block back to the genesis block")
+	}
+	for _, peerHeight := range []int{0, 9, 20, 29} {
+		// a peer holding the blocks up to its height sends the locator of their hashes
+		locator := Locator(hashes[30-peerHeight:])
+		headers := chain.HeadersAfter(locator, maxTestHeaders)
+		if len(headers) != 30-peerHeight {
+			t.Fatalf("peer at height %d gets %d headers, want %d", peerHeight, len(headers), 30-peerHeight)
+		}
+		shared, err := chain.GetBlock(hashes[30-peerHeight])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckHeaders(headerOf(shared), headers); err != nil {
+			t.Errorf("headers for the peer at height %d: %v", peerHeight, err)
+		}
+		if !bytes.Equal(headers[len(headers)-1].Hash, chain.LastHash) {
+			t.Errorf("headers for the peer at height %d do not reach the last block", peerHeight)
+		}
+	}
+	if headers := chain.HeadersAfter(Locator(hashes), maxTestHeaders); len(headers) != 0 {
+		t.Errorf("a peer at the last block gets %d headers", len(headers))
+	}
+	// a locator sharing no block gets the headers from the genesis block on
+	headers := chain.HeadersAfter([][]byte{bytes.Repeat([]byte{1}, 32)}, maxTestHeaders)
+	if len(headers) != 31 {
+		t.Fatalf("a peer sharing no block gets %d headers, want 31", len(headers))
+	}
+	if err := CheckHeaders(nil, headers); err != nil {
+		t.Error(err)
+	}
+	if headers := chain.HeadersAfter(nil, 5); len(headers) != 5 || headers[4].Height != 4 {
+		t.Errorf("headers are not cut at the maximum")
+	}
+}
+
+func TestCheckHeadersRejects(t *testing.T) {
+	chain, _, address := newTestChain(t)
+	for i := 0; i < 3; i++ {
+		chain.GenerateBlock(address)
+	}
+	headers := chain.HeadersAfter(nil, maxTestHeaders)
+	if err := CheckHeaders(nil, headers); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		tamper func(headers []BlockHeader) []BlockHeader
+	}{
+		{"missing header", func(headers []BlockHeader) []BlockHeader {
+			return append(headers[:2:2], headers[3:]...)
+		}},
+		{"wrong previous hash", func(headers []BlockHeader) []BlockHeader {
+			headers[2].PreviousHash = headers[0].Hash
+			return headers
+		}},
+		{"wrong height", func(headers []BlockHeader) []BlockHeader {
+			headers[2].Height++
+			return headers
+		}},
+		{"tampered timestamp", func(headers []BlockHeader) []BlockHeader {
+			headers[2].Timestamp++
+			return headers
+		}},
+		{"tampered transactions", func(headers []BlockHeader) []BlockHeader {
+			headers[2].TransactionsHash = headers[1].TransactionsHash
+			return headers
+		}},
+		{"not from the genesis block", func(headers []BlockHeader) []BlockHeader {
+			return headers[1:]
+		}},
+	}
+	for _, test := range tests {
+		tampered := test.tamper(append([]BlockHeader(nil), headers...))
+		if err := CheckHeaders(nil, tampered); err == nil {
+			t.Errorf("%s: headers are accepted", test.name)
+		}
+	}
+}
+
+// maxTestHeaders is more headers than the test chains hold
+const maxTestHeaders = 2000
+
+// headerOf to get the header of the Block
+func headerOf(block *Block) *BlockHeader {
+	header := block.Header()
+	return &header
+}
@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"sort"
+)
+
+// directions of a HistoryEntry as seen from the owner of the public key hashes
+const (
+	Incoming = "incoming"
+	Outgoing = "outgoing"
+	Self     = "self"
+)
+
+// HistoryEntry structure for a Transaction that touches a set of owned public key hashes
+type HistoryEntry struct {
+	TransactionID  string   `json:"txid"`
+	Height         int      `json:"height"`
+	Confirmations  int      `json:"confirmations"`
+	Direction      string   `json:"direction"`
+	Received       int      `json:"received"`
+	Sent           int      `json:"sent"`
+	Net            int      `json:"net"`
+	Fee            int      `json:"fee"`
+	Counterparties []string `json:"counterparties"`
+}
+
+// History to list every Transaction paying to or spending from the public key hashes, oldest first
+func (chain *BlockChain) History(publicKeyHashes [][]byte) []HistoryEntry {
+	var history []HistoryEntry
+	owned := make(map[string]bool)
+	for _, publicKeyHash := range publicKeyHashes {
+		owned[hex.EncodeToString(publicKeyHash)] = true
+	}
+	// outputs of every transaction seen so far, to value the inputs that spend them
+	outputs := make(map[string][]TxOutput)
+	blocks := chain.Blocks()
+	for height, block := range blocks {
+		for _, tx := range block.Transactions {
+			txID := hex.EncodeToString(tx.ID)
+			outputs[txID] = tx.Outputs
+			entry := HistoryEntry{
+				TransactionID: txID,
+				Height:        height,
+				Confirmations: len(blocks) - height,
+			}
+			counterparties := make(map[string]bool)
+			funded := true
+			inputTotal := 0
+			if tx.IsCoinBase() {
+				counterparties["coinbase"] = true
+			} else {
+				for _, in := range tx.Inputs {
+					previous := outputs[hex.EncodeToString(in.ID)]
+					value := 0
+					if in.Out >= 0 && in.Out < len(previous) {
+						value = previous[in.Out].Value
+					}
+					inputTotal += value
+					if owned[hex.EncodeToString(in.LockingHash())] {
+						entry.Sent += value
+					} else {
+						funded = false
+						counterparties[in.Address()] = true
+					}
+				}
+			}
+			outputTotal := 0
+			for _, out := range tx.Outputs {
+				outputTotal += out.Value
+				if owned[hex.EncodeToString(out.LockedHash())] {
+					entry.Received += out.Value
+				} else if entry.Sent > 0 && !out.IsDataCarrier() {
+					counterparties[out.Address()] = true
+				}
+			}
+			if entry.Sent == 0 && entry.Received == 0 {
+				continue
+			}
+			entry.Net = entry.Received - entry.Sent
+			if !tx.IsCoinBase() && funded {
+				entry.Fee = inputTotal - outputTotal
+			}
+			switch {
+			case entry.Sent == 0:
+				entry.Direction = Incoming
+			case entry.Net+entry.Fee == 0:
+				entry.Direction = Self
+			default:
+				entry.Direction = Outgoing
+			}
+			entry.Counterparties = []string{}
+			for counterparty := range counterparties {
+				entry.Counterparties = append(entry.Counterparties, counterparty)
+			}
+			sort.Strings(entry.Counterparties)
+			history = append(history, entry)
+		}
+	}
+	return history
+}
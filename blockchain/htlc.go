@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/the-code-innovator/go-blockchain/script"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// ErrContractNotFunded is returned when a contract has no unspent outputs to redeem or refund
+var ErrContractNotFunded = errors.New("contract has no unspent outputs")
+
+// NewRedeemTransaction to pay every unspent output of the contract to its recipient, the ScriptSig
+// reveals the preimage of the secret hash and takes the first branch of the contract
+func NewRedeemTransaction(contract *wallet.HTLC, preimage []byte, wallets *wallet.Wallets, chain *BlockChain) (*Transaction, error) {
+	if !contract.Matches(preimage) {
+		return nil, errors.New("preimage does not match the secret hash")
+	}
+	branch := script.Script{}.AddData(preimage).AddInt(1)
+	return contractTransaction(contract, contract.RecipientAddress(), 0, branch, wallets, chain)
+}
+
+// NewRefundTransaction to pay every unspent output of the contract back to its sender, the
+// Transaction takes the second branch of the contract and cannot be mined before its lock time
+func NewRefundTransaction(contract *wallet.HTLC, wallets *wallet.Wallets, chain *BlockChain) (*Transaction, error) {
+	branch := script.Script{}.AddInt(0)
+	return contractTransaction(contract, contract.SenderAddress(), contract.LockTime, branch, wallets, chain)
+}
+
+// contractTransaction to spend the unspent outputs of the contract to the address, every ScriptSig pushes
+// the signature and public key of the address, then the branch and the redeem script
+func contractTransaction(contract *wallet.HTLC, to string, lockTime int64, branch script.Script, wallets *wallet.Wallets, chain *BlockChain) (*Transaction, error) {
+	w, publicKey, ok := wallets.KeyFor(to)
+	if !ok {
+		return nil, fmt.Errorf("address %s is not in the wallet", to)
+	}
+	unSpent := chain.FindUnspentOutputs([][]byte{contract.ScriptHash()})
+	if len(unSpent) == 0 {
+		return nil, ErrContractNotFunded
+	}
+	total := sumOutputs(unSpent)
+	selection := Selection{unSpent, total, total, 0}
+	tx := unsignedTransaction(selection, []Payment{{to, total}}, "", TxOptions{LockTime: lockTime})
+	tx.SetID()
+	redeem := contract.Redeem()
+	for inID, input := range unSpent {
+		signature := signHash(w.PrivateKey, tx.SignatureHash(inID, input.Output))
+		scriptSig := script.Script{}.AddData(signature).AddData(publicKey)
+		tx.Inputs[inID].ScriptSig = append(scriptSig, branch...).AddData(redeem)
+	}
+	return &tx, nil
+}
+
+// FindPreimage to find the preimage the recipient revealed in the BlockChain when redeeming the contract
+func (chain *BlockChain) FindPreimage(contract *wallet.HTLC) ([]byte, bool) {
+	redeem := contract.Redeem()
+	iterator := chain.Iterator()
+	for {
+		block := iterator.Next()
+		for _, tx := range block.Transactions {
+			for _, in := range tx.Inputs {
+				pushed, err := in.ScriptSig.PushedData()
+				if err != nil || len(pushed) != 5 || !bytes.Equal(pushed[4], redeem) {
+					continue
+				}
+				if contract.Matches(pushed[2]) {
+					return pushed[2], true
+				}
+			}
+		}
+		if len(block.PreviousHash) == 0 {
+			break
+		}
+	}
+	return nil, false
+}
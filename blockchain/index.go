@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+)
+
+var (
+	// heightPrefix indexes the hash and times of every Block of the BlockChain by its height
+	heightPrefix = []byte("height-")
+	// txHeightPrefix indexes the height of the Block of every Transaction by its ID
+	txHeightPrefix = []byte("txheight-")
+)
+
+// heightEntry structure for the indexed hash of a Block, its Timestamp and the median time of the
+// blocks up to it, so that lock times are checked without reading the blocks
+type heightEntry struct {
+	Hash       []byte
+	Timestamp  int64
+	MedianTime int64
+}
+
+// heightKey to build the index key of a height
+func heightKey(height int) []byte {
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, uint32(height))
+	return append(append([]byte{}, heightPrefix...), index...)
+}
+
+// txHeightKey to build the index key of a Transaction ID
+func txHeightKey(txID []byte) []byte {
+	return append(append([]byte{}, txHeightPrefix...), txID...)
+}
+
+// serialize to serialize the heightEntry for badger.DB
+func (entry *heightEntry) serialize() []byte {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(entry)
+	PanicHandle(err)
+	return buffer.Bytes()
+}
+
+// deserializeHeightEntry to deserialize the heightEntry from badger.DB
+func deserializeHeightEntry(data []byte) heightEntry {
+	var entry heightEntry
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry)
+	PanicHandle(err)
+	return entry
+}
+
+// getHeightEntry to look up the entry of a height in the badger transaction, false when none is indexed
+func getHeightEntry(txn *badger.Txn, height int) (heightEntry, bool, error) {
+	item, err := txn.Get(heightKey(height))
+	if err == badger.ErrKeyNotFound {
+		return heightEntry{}, false, nil
+	}
+	if err != nil {
+		return heightEntry{}, false, err
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return heightEntry{}, false, err
+	}
+	return deserializeHeightEntry(data), true, nil
+}
+
+// indexBlock to index the height of a Block and of its transactions in the badger transaction, the median
+// time is taken over the Block and the blocks before it already indexed
+func indexBlock(txn *badger.Txn, block *Block) error {
+	timestamps := []int64{block.Timestamp}
+	for height := block.Height - 1; height >= 0 && height > block.Height-medianTimeBlocks; height-- {
+		entry, ok, err := getHeightEntry(txn, height)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		timestamps = append(timestamps, entry.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	entry := heightEntry{block.Hash, block.Timestamp, timestamps[len(timestamps)/2]}
+	if err := txn.Set(heightKey(block.Height), entry.serialize()); err != nil {
+		return err
+	}
+	height := make([]byte, 4)
+	binary.BigEndian.PutUint32(height, uint32(block.Height))
+	for _, tx := range block.Transactions {
+		if err := txn.Set(txHeightKey(tx.ID), height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heightEntry to look up the indexed entry of a height of the BlockChain, false above the last Block
+func (chain *BlockChain) heightEntry(height int) (heightEntry, bool) {
+	var entry heightEntry
+	found := false
+	err := chain.DataBase.View(func(txn *badger.Txn) error {
+		var err error
+		entry, found, err = getHeightEntry(txn, height)
+		return err
+	})
+	PanicHandle(err)
+	return entry, found
+}
+
+// txHeight to look up the height of the Block a Transaction was mined in, false when it is not in the BlockChain
+func (chain *BlockChain) txHeight(txID []byte) (int, bool) {
+	height := 0
+	found := false
+	err := chain.DataBase.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(txHeightKey(txID))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		height = int(binary.BigEndian.Uint32(data))
+		found = true
+		return nil
+	})
+	PanicHandle(err)
+	return height, found
+}
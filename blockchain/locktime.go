@@ -0,0 +1,169 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// constants for absolute and relative lock times
+const (
+	// LockTimeThreshold separates lock times by block height, below it, from Unix times
+	LockTimeThreshold = 500000000
+	// MaxSequence marks an input as final, no lock time applies to a Transaction whose inputs are all final
+	MaxSequence = uint32(0xffffffff)
+	// SequenceLockDisabled is set in the Sequence of an input without a relative lock time
+	SequenceLockDisabled = uint32(1 << 31)
+	// SequenceLockByTime is set in the Sequence of an input locked for a time rather than a number of blocks
+	SequenceLockByTime = uint32(1 << 22)
+	// sequenceLockMask selects the blocks or time units of a relative lock time
+	sequenceLockMask = uint32(0xffff)
+	// sequenceGranularity is the shift turning a relative lock time unit into seconds, units of 512 seconds
+	sequenceGranularity = 9
+	// medianTimeBlocks is how many blocks the median time is taken over
+	medianTimeBlocks = 11
+)
+
+// RelativeLockBlocks to get the Sequence locking an input until the output it spends is blocks deep in the BlockChain
+func RelativeLockBlocks(blocks int) (uint32, error) {
+	if blocks < 1 || blocks > int(sequenceLockMask) {
+		return 0, fmt.Errorf("relative lock must be between 1 and %d blocks", sequenceLockMask)
+	}
+	return uint32(blocks), nil
+}
+
+// RelativeLockDuration to get the Sequence locking an input until the duration has passed since the
+// output it spends was mined, the duration is rounded up to units of 512 seconds
+func RelativeLockDuration(duration time.Duration) (uint32, error) {
+	unit := int64(1) << sequenceGranularity
+	units := (int64(duration/time.Second) + unit - 1) / unit
+	if units < 1 || units > int64(sequenceLockMask) {
+		return 0, fmt.Errorf("relative lock must be between %ds and %ds", unit, int64(sequenceLockMask)*unit)
+	}
+	return SequenceLockByTime | uint32(units), nil
+}
+
+// LockTimeString to describe a lock time as a block height or a date
+func LockTimeString(lockTime int64) string {
+	if lockTime < LockTimeThreshold {
+		return fmt.Sprintf("block %d", lockTime)
+	}
+	return time.Unix(lockTime, 0).UTC().Format(time.RFC3339)
+}
+
+// IsFinal to check whether the LockTime of the Transaction lets it be mined in the block at the height,
+// lock times by time are compared with the median time of the blocks before it
+func (tx *Transaction) IsFinal(height int, medianTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+	limit := int64(height)
+	if tx.LockTime >= LockTimeThreshold {
+		limit = medianTime
+	}
+	if tx.LockTime < limit {
+		return true
+	}
+	for _, in := range tx.Inputs {
+		if in.Sequence != MaxSequence {
+			return false
+		}
+	}
+	return true
+}
+
+// lockContext structure for the height of the next Block and the times lock times are checked against,
+// confirmed holds the transactions of the next Block so far, earlier ones are looked up in the height index
+type lockContext struct {
+	chain      *BlockChain
+	height     int
+	medianTime int64
+	confirmed  map[string]int
+}
+
+// lockContext to read the height and the median time of the last Block from the height index
+func (chain *BlockChain) lockContext() lockContext {
+	context := lockContext{chain: chain, confirmed: make(map[string]int)}
+	// the genesis Block of an empty BlockChain has no blocks before it
+	if chain.LastHash == nil {
+		return context
+	}
+	context.height = chain.BestHeight() + 1
+	entry, ok := chain.heightEntry(context.height - 1)
+	if !ok {
+		log.Panic("ERROR: LAST BLOCK IS NOT INDEXED !")
+	}
+	context.medianTime = entry.MedianTime
+	return context
+}
+
+// clone to copy the context, so that a Transaction that turns out invalid can be rolled back
+func (context *lockContext) clone() lockContext {
+	copied := *context
+	copied.confirmed = make(map[string]int)
+	for txID, height := range context.confirmed {
+		copied.confirmed[txID] = height
+	}
+	return copied
+}
+
+// confirmedHeight to find the height a Transaction was mined at, the next Block or an earlier one
+func (context *lockContext) confirmedHeight(txID []byte) (int, bool) {
+	if height, ok := context.confirmed[hex.EncodeToString(txID)]; ok {
+		return height, true
+	}
+	if context.chain == nil || context.chain.LastHash == nil {
+		return 0, false
+	}
+	return context.chain.txHeight(txID)
+}
+
+// minedTime to get the time an output mined at the height counts as mined, the median time of the blocks before it
+func (context *lockContext) minedTime(height int) int64 {
+	if height > 0 {
+		height--
+	}
+	if height >= context.height-1 {
+		return context.medianTime
+	}
+	entry, ok := context.chain.heightEntry(height)
+	if !ok {
+		log.Panicf("ERROR: BLOCK AT HEIGHT %d IS NOT INDEXED !", height)
+	}
+	return entry.MedianTime
+}
+
+// check to check the lock time of the Transaction and the relative lock time of every input
+// against the next Block
+func (context *lockContext) check(tx *Transaction) error {
+	if !tx.IsFinal(context.height, context.medianTime) {
+		return fmt.Errorf("transaction is locked until after %s", LockTimeString(tx.LockTime))
+	}
+	for inID, in := range tx.Inputs {
+		if in.Sequence&SequenceLockDisabled != 0 {
+			continue
+		}
+		coinHeight, ok := context.confirmedHeight(in.ID)
+		if !ok {
+			return fmt.Errorf("input %d spends a transaction that is not in the blockchain", inID)
+		}
+		value := in.Sequence & sequenceLockMask
+		if in.Sequence&SequenceLockByTime == 0 {
+			if context.height-coinHeight < int(value) {
+				return fmt.Errorf("input %d is locked until its output is %d blocks deep", inID, value)
+			}
+			continue
+		}
+		if context.medianTime-context.minedTime(coinHeight) < int64(value)<<sequenceGranularity {
+			return fmt.Errorf("input %d is locked until %ds after its output was mined", inID, int64(value)<<sequenceGranularity)
+		}
+	}
+	return nil
+}
+
+// CheckLocks to check whether the lock times of the Transaction let it be mined in the next Block
+func (chain *BlockChain) CheckLocks(tx *Transaction) error {
+	context := chain.lockContext()
+	return context.check(tx)
+}
@@ -0,0 +1,201 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/script"
+)
+
+func TestIsFinal(t *testing.T) {
+	const height, medianTime = 100, int64(LockTimeThreshold + 5000)
+	tests := []struct {
+		name     string
+		lockTime int64
+		sequence uint32
+		final    bool
+	}{
+		{"no lock time", 0, 0, true},
+		{"height below the block", height - 1, 0, true},
+		{"height of the block", height, 0, false},
+		{"height past the block", height + 1, 0, false},
+		{"height past the block with final inputs", height + 1, MaxSequence, true},
+		{"time below the median time", medianTime - 1, 0, true},
+		{"median time", medianTime, 0, false},
+		{"time past the median time", medianTime + 1, MaxSequence - 1, false},
+		{"time past the median time with final inputs", medianTime + 1, MaxSequence, true},
+	}
+	for _, test := range tests {
+		tx := Transaction{Inputs: []TxInput{{Sequence: test.sequence}}, LockTime: test.lockTime}
+		if final := tx.IsFinal(height, medianTime); final != test.final {
+			t.Errorf("%s: final is %v, want %v", test.name, final, test.final)
+		}
+	}
+}
+
+func TestLockTimeByHeight(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	chain.GenerateBlock(address)
+	chain.GenerateBlock(address)
+	pool := chain.TxPool()
+	next := chain.BestHeight() + 1
+	final := testPayment(t, chain, wallets, address, payee, 10, TxOptions{LockTime: int64(next - 1)})
+	if err := pool.Add(final); err != nil {
+		t.Fatalf("transaction locked until before the next block: %v", err)
+	}
+	locked := testPayment(t, chain, wallets, address, payee, 10, TxOptions{LockTime: int64(next)})
+	if err := pool.Add(locked); err == nil {
+		t.Error("the pool takes a transaction locked until the next block")
+	}
+	if _, err := chain.newTemplate([]*Transaction{locked}); err == nil {
+		t.Error("a block takes a transaction locked until its height")
+	}
+	chain.GenerateBlock(address)
+	if height, ok := chain.txHeight(final.ID); !ok || height != next {
+		t.Fatalf("final transaction is mined at height %d, want %d", height, next)
+	}
+	if err := pool.Add(locked); err != nil {
+		t.Fatalf("transaction locked until the last block: %v", err)
+	}
+	chain.GenerateBlock(address)
+	if height, ok := chain.txHeight(locked.ID); !ok || height != next+1 {
+		t.Errorf("locked transaction is mined at height %d, want %d", height, next+1)
+	}
+}
+
+func TestLockTimeByMedianTimePast(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	base := Now() + 1000
+	for i := int64(1); i <= 10; i++ {
+		generateAt(t, chain, address, base+i*600)
+	}
+	// the median of the genesis block and the ten after it is the fifth of those
+	medianTime := chain.lockContext().medianTime
+	if medianTime != base+5*600 {
+		t.Fatalf("median time is %d, want %d", medianTime, base+5*600)
+	}
+	pool := chain.TxPool()
+	final := testPayment(t, chain, wallets, address, payee, 10, TxOptions{LockTime: medianTime - 1})
+	if err := pool.Add(final); err != nil {
+		t.Fatalf("transaction locked until before the median time: %v", err)
+	}
+	// the last block and the clock are past the lock time, only the median time counts
+	locked := testPayment(t, chain, wallets, address, payee, 10, TxOptions{LockTime: medianTime})
+	if err := pool.Add(locked); err == nil {
+		t.Error("the pool takes a transaction locked until the median time")
+	}
+	if _, err := chain.newTemplate([]*Transaction{locked}); err == nil {
+		t.Error("a block takes a transaction locked until the median time")
+	}
+	generateAt(t, chain, address, base+11*600)
+	if _, ok := chain.txHeight(final.ID); !ok {
+		t.Fatal("final transaction is not mined")
+	}
+	if medianTime := chain.lockContext().medianTime; medianTime != base+6*600 {
+		t.Fatalf("median time is %d, want %d", medianTime, base+6*600)
+	}
+	if err := pool.Add(locked); err != nil {
+		t.Fatalf("transaction locked until before the median time: %v", err)
+	}
+	generateAt(t, chain, address, base+12*600)
+	if _, ok := chain.txHeight(locked.ID); !ok {
+		t.Error("locked transaction is not mined once the median time passes its lock time")
+	}
+}
+
+func TestRelativeLockByBlocks(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	holder := addKey(wallets)
+	pending(t, chain, testPayment(t, chain, wallets, address, holder, 50, TxOptions{}))
+	coinHeight := chain.GenerateBlock(address).Height
+	lock, err := RelativeLockBlocks(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := testPayment(t, chain, wallets, holder, address, 20, TxOptions{RelativeLock: lock})
+	// the pool takes it while locked, blocks leave it out until its output is deep enough
+	pending(t, chain, spend)
+	for height := coinHeight + 1; height < coinHeight+3; height++ {
+		if err := chain.CheckLocks(spend); err == nil {
+			t.Fatalf("spend is unlocked in the block at height %d", height)
+		}
+		chain.GenerateBlock(address)
+		if _, ok := chain.txHeight(spend.ID); ok {
+			t.Fatalf("spend is mined at height %d", height)
+		}
+	}
+	if err := chain.CheckLocks(spend); err != nil {
+		t.Fatalf("spend is still locked 3 blocks after its output: %v", err)
+	}
+	chain.GenerateBlock(address)
+	if height, ok := chain.txHeight(spend.ID); !ok || height != coinHeight+3 {
+		t.Errorf("spend is mined at height %d, want %d", height, coinHeight+3)
+	}
+}
+
+func TestRelativeLockByMedianTimePast(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	holder := addKey(wallets)
+	base := Now() + 1000
+	for i := int64(1); i <= 10; i++ {
+		generateAt(t, chain, address, base+i*600)
+	}
+	pending(t, chain, testPayment(t, chain, wallets, address, holder, 50, TxOptions{}))
+	coinHeight := generateAt(t, chain, address, base+11*600).Height
+	lock, err := RelativeLockDuration(1024 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := testPayment(t, chain, wallets, holder, address, 20, TxOptions{RelativeLock: lock})
+	pending(t, chain, spend)
+	// the output counts as mined at the median time before its block, base+3000, the next block at base+3600
+	if err := chain.CheckLocks(spend); err == nil {
+		t.Fatal("spend is unlocked 600s after its output")
+	}
+	generateAt(t, chain, address, base+12*600)
+	if _, ok := chain.txHeight(spend.ID); ok {
+		t.Fatal("spend is mined while locked")
+	}
+	// only 600s passed since the block of the output, but 1200s between the median times
+	if err := chain.CheckLocks(spend); err != nil {
+		t.Fatalf("spend is still locked: %v", err)
+	}
+	generateAt(t, chain, address, base+13*600)
+	if height, ok := chain.txHeight(spend.ID); !ok || height != coinHeight+2 {
+		t.Errorf("spend is mined at height %d, want %d", height, coinHeight+2)
+	}
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	const height, unix = 100, int64(LockTimeThreshold + 5000)
+	tests := []struct {
+		name       string
+		scriptLock int64
+		txLockTime int64
+		sequence   uint32
+		passes     bool
+	}{
+		{"height reached", height, height, MaxSequence - 1, true},
+		{"height passed", height, height + 1, MaxSequence - 1, true},
+		{"height not reached", height, height - 1, MaxSequence - 1, false},
+		{"time reached", unix, unix, MaxSequence - 1, true},
+		{"time not reached", unix, unix - 1, MaxSequence - 1, false},
+		{"height against a time", height, unix, MaxSequence - 1, false},
+		{"time against a height", unix, height, MaxSequence - 1, false},
+		{"last height against the first time", LockTimeThreshold - 1, LockTimeThreshold, MaxSequence - 1, false},
+		{"final input", height, height, MaxSequence, false},
+	}
+	for _, test := range tests {
+		tx := &Transaction{Inputs: []TxInput{{Sequence: test.sequence}}, LockTime: test.txLockTime}
+		checker := &inputChecker{tx: tx}
+		if passes := checker.CheckLockTime(test.scriptLock); passes != test.passes {
+			t.Errorf("%s: passes is %v, want %v", test.name, passes, test.passes)
+		}
+		lockScript := script.Script{}.AddInt(test.scriptLock).AddOp(script.OpCheckLockTimeVerify)
+		if err := script.NewEngine(checker).Run(lockScript); (err == nil) != test.passes {
+			t.Errorf("%s: script error is %v", test.name, err)
+		}
+	}
+}
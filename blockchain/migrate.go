@@ -0,0 +1,36 @@
+package blockchain
+
+import (
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// SweepLegacy to move the confirmed unspent outputs of the addresses from before the public key migration
+// to the addresses their wallets moved to, returning nil when nothing is left at the old addresses
+func (chain *BlockChain) SweepLegacy(wallets *wallet.Wallets) *Transaction {
+	pending := chain.TxPool().SpentOutputs()
+	var selection Selection
+	var payments []Payment
+	for _, address := range wallets.GetLegacyAddresses() {
+		_, publicKey, _ := wallets.KeyFor(address)
+		swept := 0
+		for _, unSpent := range chain.FindUnspentOutputs([][]byte{wallet.PublicKeyHash(publicKey)}) {
+			if pending[outpoint(unSpent.ID, unSpent.Out)] {
+				continue
+			}
+			selection.Inputs = append(selection.Inputs, unSpent)
+			swept += unSpent.Output.Value
+		}
+		if swept > 0 {
+			payments = append(payments, Payment{wallets.Legacy[address], swept})
+		}
+	}
+	if len(payments) == 0 {
+		return nil
+	}
+	selection.Total = sumOutputs(selection.Inputs)
+	selection.Amount = selection.Total
+	tx := unsignedTransaction(selection, payments, "", TxOptions{})
+	tx.SetID()
+	chain.SignTransactionWithWallets(&tx, wallets)
+	return &tx
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/the-code-innovator/go-blockchain/script"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// SignMuSigInput to take an input spending a MuSig output a round further for every key of the account
+// held by the wallets: while the ScriptSig is incomplete it pushes one public nonce slot and one partial
+// signature slot per public key, empty for the missing ones, followed by the redeem script. The first
+// round fills the nonce slots, the second the partial signature slots once every nonce is in, and the
+// last partial signature turns the ScriptSig into the single aggregated signature and the redeem script
+func (tx *Transaction) SignMuSigInput(inID int, musig *wallet.MuSig, wallets *wallet.Wallets, previousOutput TxOutput) (bool, error) {
+	in := &tx.Inputs[inID]
+	redeem := musig.Redeem()
+	count := len(musig.PublicKeys)
+	if pushed, err := in.ScriptSig.PushedData(); err == nil && len(pushed) == 2 && bytes.Equal(pushed[1], redeem) {
+		return false, nil
+	}
+	nonces, partials := muSigSlots(in.ScriptSig, redeem, count)
+	hash := tx.SignatureHash(inID, previousOutput)
+	signed := false
+	for keyID, publicKey := range musig.PublicKeys {
+		if _, ok := wallets.GetWalletByPublicKey(publicKey); !ok || len(nonces[keyID]) > 0 {
+			continue
+		}
+		secretNonce, publicNonce, err := wallet.NewNonce(publicKey)
+		if err != nil {
+			return false, err
+		}
+		wallets.AddNonce(hash, publicKey, secretNonce)
+		nonces[keyID] = publicNonce
+		signed = true
+	}
+	if filledSlots(nonces) == count {
+		for keyID, publicKey := range musig.PublicKeys {
+			w, ok := wallets.GetWalletByPublicKey(publicKey)
+			if !ok || len(partials[keyID]) > 0 {
+				continue
+			}
+			secretNonce, ok := wallets.TakeNonce(hash, publicKey)
+			if !ok {
+				return false, fmt.Errorf("the nonce of key %x was not drawn by this wallet", publicKey)
+			}
+			partial, err := musig.PartialSign(w.PrivateKey, secretNonce, nonces, hash)
+			if err != nil {
+				return false, err
+			}
+			partials[keyID] = partial
+			signed = true
+		}
+	}
+	if filledSlots(partials) == count {
+		signature, err := musig.AggregateSignatures(nonces, partials, hash)
+		if err != nil {
+			return false, err
+		}
+		in.ScriptSig = script.Script{}.AddData(signature).AddData(redeem)
+		return signed, nil
+	}
+	scriptSig := script.Script{}
+	for _, slots := range [][][]byte{nonces, partials} {
+		for _, slot := range slots {
+			scriptSig = scriptSig.AddData(slot)
+		}
+	}
+	in.ScriptSig = scriptSig.AddData(redeem)
+	return signed, nil
+}
+
+// muSigSlots to get the public nonce and partial signature slots of an incomplete MuSig ScriptSig,
+// all empty when the ScriptSig does not yet unlock the redeem script
+func muSigSlots(scriptSig script.Script, redeem []byte, count int) ([][]byte, [][]byte) {
+	pushed, err := scriptSig.PushedData()
+	if err != nil || len(pushed) != 2*count+1 || !bytes.Equal(pushed[2*count], redeem) {
+		return make([][]byte, count), make([][]byte, count)
+	}
+	return pushed[:count], pushed[count : 2*count]
+}
+
+// filledSlots to count the slots that are not empty
+func filledSlots(slots [][]byte) int {
+	filled := 0
+	for _, slot := range slots {
+		if len(slot) > 0 {
+			filled++
+		}
+	}
+	return filled
+}
@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"encoding/hex"
+)
+
+// MaxBlockSize is the most bytes of transactions block assembly puts in a Block
+const MaxBlockSize = 100000
+
+// Package structure for a pending Transaction together with its pending ancestors, which have to be
+// mined before it or with it, a child paying a high fee thus pulls its low fee parents into a Block
+type Package struct {
+	Entry     *PoolEntry
+	Ancestors []*PoolEntry
+	Fee       int
+	Size      int
+}
+
+// FeeRate to get the fee the whole Package pays per 1000 bytes
+func (pkg *Package) FeeRate() float64 {
+	return FeeRate(pkg.Fee, pkg.Size)
+}
+
+// Transactions to get the transactions of the Package in the order they are mined, parents first
+func (pkg *Package) Transactions() []*Transaction {
+	var transactions []*Transaction
+	for _, ancestor := range pkg.Ancestors {
+		transactions = append(transactions, ancestor.Transaction)
+	}
+	return append(transactions, pkg.Entry.Transaction)
+}
+
+// Packages to get the Package of every pending Transaction, in the order of Entries
+func (pool *TxPool) Packages() []*Package {
+	entries := pool.Entries()
+	pending := byID(entries)
+	var packages []*Package
+	for _, entry := range entries {
+		packages = append(packages, newPackage(entry, pending, nil))
+	}
+	return packages
+}
+
+// byID to index pending transactions by their hex ID
+func byID(entries []*PoolEntry) map[string]*PoolEntry {
+	indexed := make(map[string]*PoolEntry)
+	for _, entry := range entries {
+		indexed[hex.EncodeToString(entry.Transaction.ID)] = entry
+	}
+	return indexed
+}
+
+// newPackage to gather the pending ancestors of the entry that are not yet mined, parents before children
+func newPackage(entry *PoolEntry, pending map[string]*PoolEntry, mined map[string]bool) *Package {
+	pkg := &Package{Entry: entry, Fee: entry.Fee, Size: entry.Size}
+	visited := make(map[string]bool)
+	var visit func(tx *Transaction)
+	visit = func(tx *Transaction) {
+		for _, in := range tx.Inputs {
+			parentID := hex.EncodeToString(in.ID)
+			parent, ok := pending[parentID]
+			if !ok || visited[parentID] || mined[parentID] {
+				continue
+			}
+			visited[parentID] = true
+			visit(parent.Transaction)
+			pkg.Ancestors = append(pkg.Ancestors, parent)
+			pkg.Fee += parent.Fee
+			pkg.Size += parent.Size
+		}
+	}
+	visit(entry.Transaction)
+	return pkg
+}
+
+// BlockTransactions to choose the pending transactions for the next Block: the Package with the highest
+// fee rate goes in first, parents before children, then the packages are scored again without the
+// transactions already chosen, a Package failing the checks of AddBlock is left out with its descendants
+// and one that would take the Block over maxSize bytes waits for a later Block
+func (pool *TxPool) BlockTransactions(maxSize int) ([]*Transaction, int) {
+	context := pool.blockchain.lockContext()
+	view := newBlockView()
+	entries := pool.Entries()
+	pending := byID(entries)
+	mined := make(map[string]bool)
+	failed := make(map[string]bool)
+	var transactions []*Transaction
+	fees, size := 0, 0
+	for {
+		var best *Package
+		for _, entry := range entries {
+			txID := hex.EncodeToString(entry.Transaction.ID)
+			if mined[txID] || failed[txID] {
+				continue
+			}
+			pkg := newPackage(entry, pending, mined)
+			if pkg.hasAny(failed) {
+				failed[txID] = true
+				continue
+			}
+			if size+pkg.Size > maxSize {
+				continue
+			}
+			if best == nil || higherFeeRate(pkg.Fee, pkg.Size, best.Fee, best.Size) {
+				best = pkg
+			}
+		}
+		if best == nil {
+			break
+		}
+		trialContext, trialView := context.clone(), view.clone()
+		valid := true
+		for _, tx := range best.Transactions() {
+			if _, err := pool.blockchain.validateTransaction(tx, &trialContext, trialView); err != nil {
+				failed[hex.EncodeToString(tx.ID)] = true
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		context, view = trialContext, trialView
+		for _, tx := range best.Transactions() {
+			mined[hex.EncodeToString(tx.ID)] = true
+			transactions = append(transactions, tx)
+		}
+		fees += best.Fee
+		size += best.Size
+	}
+	return transactions, fees
+}
+
+// hasAny to check whether the Package holds one of the transactions
+func (pkg *Package) hasAny(txIDs map[string]bool) bool {
+	for _, tx := range pkg.Transactions() {
+		if txIDs[hex.EncodeToString(tx.ID)] {
+			return true
+		}
+	}
+	return false
+}
@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+
+	"github.com/the-code-innovator/go-blockchain/params"
 )
 
-// Difficulty constant representing the diffuculty of finding the nonce
-const Difficulty = 18
+// Difficulty to get the diffuculty of finding the nonce on the active network, in leading zero bits
+func Difficulty() int {
+	return params.Active().Difficulty
+}
 
 // ProofOfWork structure for the proof of work in mining
 type ProofOfWork struct {
@@ -20,12 +24,20 @@ type ProofOfWork struct {
 
 // InitData to initialize the data in the Block
 func (proofOfWork *ProofOfWork) InitData(nonce int) []byte {
+	block := proofOfWork.Block
+	return proofData(block.PreviousHash, block.HashTransactions(), block.Height, block.Timestamp, nonce)
+}
+
+// proofData to lay out the fields of a Block its proof of work commits to
+func proofData(previousHash, transactionsHash []byte, height int, timestamp int64, nonce int) []byte {
 	data := bytes.Join(
 		[][]byte{
-			proofOfWork.Block.PreviousHash,
-			proofOfWork.Block.HashTransactions(),
+			previousHash,
+			transactionsHash,
+			ToHex(int64(height)),
+			ToHex(timestamp),
 			ToHex(int64(nonce)),
-			ToHex(int64(Difficulty)),
+			ToHex(int64(Difficulty())),
 		},
 		[]byte{},
 	)
@@ -40,7 +52,9 @@ func (proofOfWork *ProofOfWork) Run() (int, []byte) {
 	for nonce < math.MaxInt64 {
 		data := proofOfWork.InitData(nonce)
 		hash = sha256.Sum256(data)
-		fmt.Printf("\r%x", hash)
+		if !params.Active().OnDemand {
+			fmt.Printf("\r%x", hash)
+		}
 		intHash.SetBytes(hash[:])
 		if intHash.Cmp(proofOfWork.Target) == -1 {
 			break
@@ -48,7 +62,9 @@ func (proofOfWork *ProofOfWork) Run() (int, []byte) {
 			nonce++
 		}
 	}
-	fmt.Println()
+	if !params.Active().OnDemand {
+		fmt.Println()
+	}
 	return nonce, hash[:]
 }
 
@@ -63,12 +79,16 @@ func (proofOfWork *ProofOfWork) Validate() bool {
 
 // NewProof to create a new ProofOfWork to mine the Block
 func NewProof(block *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-Difficulty))
-	proofOfWork := &ProofOfWork{block, target}
+	proofOfWork := &ProofOfWork{block, proofTarget()}
 	return proofOfWork
 }
 
+// proofTarget to get the number the hash of a Block must stay below on the active network
+func proofTarget() *big.Int {
+	target := big.NewInt(1)
+	return target.Lsh(target, uint(256-Difficulty()))
+}
+
 // ToHex to convert an integer to Hex
 func ToHex(number int64) []byte {
 	buffer := new(bytes.Buffer)
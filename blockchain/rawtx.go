@@ -0,0 +1,220 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// RawTransaction structure for a Transaction passed between machines before it is broadcast,
+// carrying the outputs its inputs spend so that it can be signed without the BlockChain
+type RawTransaction struct {
+	Transaction     Transaction
+	PreviousOutputs []TxOutput
+}
+
+// CreateRawTransaction for creating an unsigned Transaction paying the payments from the address,
+// the change goes to changeAddress which defaults to the address itself
+func CreateRawTransaction(from string, payments []Payment, changeAddress string, options TxOptions, blockchain *BlockChain) (*RawTransaction, error) {
+	if changeAddress == "" {
+		changeAddress = from
+	}
+	publicKeyHash, _, err := wallet.DecodeAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	selection, err := fundPayments([][]byte{publicKeyHash}, payments, options, blockchain)
+	if err != nil {
+		return nil, err
+	}
+	raw := RawTransaction{Transaction: unsignedTransaction(selection, payments, changeAddress, options)}
+	for _, unSpent := range selection.Inputs {
+		raw.PreviousOutputs = append(raw.PreviousOutputs, unSpent.Output)
+	}
+	return &raw, nil
+}
+
+// RawTransactionOf to wrap a Transaction with the outputs it spends, so that it can be broadcast later
+func (chain *BlockChain) RawTransactionOf(tx *Transaction) (*RawTransaction, error) {
+	raw := RawTransaction{Transaction: *tx}
+	for inID, in := range tx.Inputs {
+		previousTX, err := chain.FindTransaction(in.ID)
+		if err != nil {
+			return nil, err
+		}
+		if in.Out < 0 || in.Out >= len(previousTX.Outputs) {
+			return nil, fmt.Errorf("input %d spends an output that does not exist", inID)
+		}
+		raw.PreviousOutputs = append(raw.PreviousOutputs, previousTX.Outputs[in.Out])
+	}
+	return &raw, nil
+}
+
+// Sign to sign every input spending an output owned by one of the wallets, returning how many were signed,
+// inputs of MuSig accounts are taken a round further
+func (raw *RawTransaction) Sign(wallets *wallet.Wallets) (int, error) {
+	tx := &raw.Transaction
+	signed := 0
+	for inID, previousOutput := range raw.PreviousOutputs {
+		address := previousOutput.Address()
+		if musig, ok := wallets.MuSigs[address]; ok {
+			progressed, err := tx.SignMuSigInput(inID, musig, wallets, previousOutput)
+			if err != nil {
+				return signed, err
+			}
+			if progressed {
+				signed++
+			}
+			continue
+		}
+		if _, ok := previousOutput.ScriptPubKey.ScriptHash(); ok {
+			multisig, ok := wallets.Multisigs[address]
+			if !ok {
+				continue
+			}
+			for _, publicKey := range multisig.PublicKeys {
+				if w, ok := wallets.GetWalletByPublicKey(publicKey); ok {
+					tx.SignMultisigInput(inID, multisig, w, previousOutput)
+					signed++
+				}
+			}
+			continue
+		}
+		w, publicKey, ok := wallets.KeyFor(address)
+		if !ok {
+			continue
+		}
+		tx.SignInput(inID, w.PrivateKey, publicKey, previousOutput)
+		signed++
+	}
+	tx.SetID()
+	return signed, nil
+}
+
+// IsComplete to check whether every input carries a valid signature
+func (raw *RawTransaction) IsComplete() bool {
+	if len(raw.PreviousOutputs) != len(raw.Transaction.Inputs) {
+		return false
+	}
+	for inID, previousOutput := range raw.PreviousOutputs {
+		if !raw.Transaction.VerifyInput(inID, previousOutput) {
+			return false
+		}
+	}
+	return true
+}
+
+// String to output the raw transaction with the values of the outputs it spends
+func (raw RawTransaction) String() string {
+	var lines []string
+	total := 0
+	for inID, previousOutput := range raw.PreviousOutputs {
+		in := raw.Transaction.Inputs[inID]
+		status := "unsigned"
+		pushed, err := in.ScriptSig.PushedData()
+		if err == nil && len(pushed) > 0 {
+			status = "signed"
+			if multisig, err := wallet.ParseRedeem(pushed[len(pushed)-1]); err == nil {
+				signatures := 0
+				for _, signature := range pushed[:len(pushed)-1] {
+					if len(signature) > 0 {
+						signatures++
+					}
+				}
+				status = fmt.Sprintf("%d of %d required signatures", signatures, multisig.Required)
+			}
+			if count := (len(pushed) - 1) / 2; len(pushed) > 2 && wallet.IsPublicNonce(pushed[0]) {
+				status = fmt.Sprintf("musig with %d of %d nonces, %d of %d partial signatures",
+					filledSlots(pushed[:count]), count, filledSlots(pushed[count:2*count]), count)
+			}
+		}
+		lines = append(lines, fmt.Sprintf(" • Spends %x:%d %d from %s (%s)", in.ID, in.Out, previousOutput.Value, previousOutput.Address(), status))
+		total += previousOutput.Value
+	}
+	for _, out := range raw.Transaction.Outputs {
+		if data, ok := out.Data(); ok {
+			lines = append(lines, fmt.Sprintf(" • Carries data %x", data))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(" • Pays %d to %s", out.Value, out.Address()))
+		total -= out.Value
+	}
+	lines = append(lines, fmt.Sprintf(" • Fee %d", total))
+	if raw.Transaction.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf(" • Locked until after %s", LockTimeString(raw.Transaction.LockTime)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Encode to encode the raw transaction as hex for moving it between machines
+func (raw *RawTransaction) Encode() string {
+	var encoded bytes.Buffer
+	encoder := gob.NewEncoder(&encoded)
+	err := encoder.Encode(raw)
+	PanicHandle(err)
+	return hex.EncodeToString(encoded.Bytes())
+}
+
+// DecodeRawTransaction to decode a raw transaction encoded by Encode
+func DecodeRawTransaction(encoded string) (*RawTransaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, err
+	}
+	var raw RawTransaction
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if len(raw.PreviousOutputs) != len(raw.Transaction.Inputs) {
+		return nil, errors.New("raw transaction does not carry an output for every input")
+	}
+	return &raw, nil
+}
+
+// SendRawTransaction to check a fully signed raw transaction against the BlockChain and mine it
+func (chain *BlockChain) SendRawTransaction(raw *RawTransaction) (*Transaction, error) {
+	tx := raw.Transaction
+	if len(raw.PreviousOutputs) != len(tx.Inputs) {
+		return nil, errors.New("raw transaction does not carry an output for every input")
+	}
+	utxo := UTXO{chain}
+	inputTotal := 0
+	for inID, in := range tx.Inputs {
+		previousOutput, ok := utxo.Get(in.ID, in.Out)
+		if !ok {
+			return nil, fmt.Errorf("input %d spends an output that is missing or already spent", inID)
+		}
+		claimed := raw.PreviousOutputs[inID]
+		if previousOutput.Value != claimed.Value || !bytes.Equal(previousOutput.ScriptPubKey, claimed.ScriptPubKey) {
+			return nil, fmt.Errorf("input %d does not match the output it spends", inID)
+		}
+		var err error
+		if inputTotal, err = addValue(inputTotal, previousOutput.Value); err != nil {
+			return nil, fmt.Errorf("input %d: %v", inID, err)
+		}
+	}
+	outputTotal, err := tx.outputTotal()
+	if err != nil {
+		return nil, err
+	}
+	if outputTotal > inputTotal {
+		return nil, errors.New("transaction pays out more than it spends")
+	}
+	if !raw.IsComplete() || !chain.VerifyTransaction(&tx) {
+		return nil, errors.New("transaction is not fully signed")
+	}
+	if err := tx.checkID(); err != nil {
+		return nil, fmt.Errorf("transaction %v", err)
+	}
+	if err := chain.CheckLocks(&tx); err != nil {
+		return nil, err
+	}
+	chain.AddBlock([]*Transaction{&tx})
+	return &tx, nil
+}
@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+
+	"github.com/dgraph-io/badger"
+)
+
+// undoPrefix keys the outputs every Block spent by its hash, in the order of its transactions and their inputs
+var undoPrefix = []byte("undo-")
+
+// undoKey to build the key of the undo data of a Block
+func undoKey(hash []byte) []byte {
+	return append(append([]byte{}, undoPrefix...), hash...)
+}
+
+// serializeUndo to serialize the outputs spent by the transactions of a Block for badger.DB
+func serializeUndo(undo [][]utxoEntry) []byte {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(undo)
+	PanicHandle(err)
+	return buffer.Bytes()
+}
+
+// deserializeUndo to deserialize the outputs spent by the transactions of a Block from badger.DB
+func deserializeUndo(data []byte) [][]utxoEntry {
+	var undo [][]utxoEntry
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&undo)
+	PanicHandle(err)
+	return undo
+}
+
+// disconnectBlock to take the last Block off the BlockChain, its transactions are undone last first: the outputs
+// they created are removed and those they spent restored from the undo data, it stays stored under its hash
+func (chain *BlockChain) disconnectBlock() *Block {
+	block, err := chain.GetBlock(chain.LastHash)
+	PanicHandle(err)
+	if block.Height == 0 {
+		log.Panic("ERROR: THE GENESIS BLOCK CANNOT BE DISCONNECTED !")
+	}
+	err = chain.DataBase.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(undoKey(block.Hash))
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		undo := deserializeUndo(data)
+		for txIndex := len(block.Transactions) - 1; txIndex >= 0; txIndex-- {
+			tx := block.Transactions[txIndex]
+			for outID, out := range tx.Outputs {
+				if out.IsDataCarrier() {
+					continue
+				}
+				if err := txn.Delete(utxoKey(tx.ID, outID)); err != nil {
+					return err
+				}
+			}
+			for inID, entry := range undo[txIndex] {
+				in := tx.Inputs[inID]
+				if err := txn.Set(utxoKey(in.ID, in.Out), entry.serialize()); err != nil {
+					return err
+				}
+			}
+			if err := txn.Delete(txHeightKey(tx.ID)); err != nil {
+				return err
+			}
+		}
+		for _, key := range [][]byte{heightKey(block.Height), undoKey(block.Hash)} {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		if err := txn.Set([]byte("lh"), block.PreviousHash); err != nil {
+			return err
+		}
+		return txn.Set(utxoTipKey, block.PreviousHash)
+	})
+	PanicHandle(err)
+	chain.LastHash = block.PreviousHash
+	return block
+}
+
+// Reorganize to switch the BlockChain to a branch of blocks mined elsewhere, forking off below the last Block:
+// the blocks past the fork are disconnected and those of the branch accepted in order, returning how many were
+// accepted, when one is not valid the BlockChain is switched back and the error returned along with its index,
+// the transactions of the disconnected blocks the branch does not mine go back to the pool
+func (chain *BlockChain) Reorganize(branch []*Block) (int, error) {
+	if len(branch) == 0 || !chain.HasBlock(branch[0].PreviousHash) {
+		return 0, errors.New("branch does not fork off the blockchain")
+	}
+	var disconnected []*Block
+	for !bytes.Equal(chain.LastHash, branch[0].PreviousHash) {
+		disconnected = append(disconnected, chain.disconnectBlock())
+	}
+	for index, block := range branch {
+		if err := chain.AcceptBlock(block); err != nil {
+			for i := 0; i < index; i++ {
+				chain.disconnectBlock()
+			}
+			for i := len(disconnected) - 1; i >= 0; i-- {
+				if err := chain.AcceptBlock(disconnected[i]); err != nil {
+					log.Panicf("ERROR: BLOCK %x CANNOT BE RECONNECTED: %v !", disconnected[i].Hash, err)
+				}
+			}
+			return index, err
+		}
+	}
+	chain.TxPool().restore(disconnected)
+	return len(branch), nil
+}
+
+// restore to put the transactions of the disconnected blocks, given last first, back in the pool along with
+// the pending ones, those that no longer spend unspent or pending outputs are dropped
+func (pool *TxPool) restore(disconnected []*Block) {
+	var transactions []*Transaction
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		for _, tx := range disconnected[i].Transactions {
+			if !tx.IsCoinBase() {
+				transactions = append(transactions, tx)
+			}
+		}
+	}
+	entries := pool.Entries()
+	err := pool.blockchain.DataBase.Update(func(txn *badger.Txn) error {
+		for _, entry := range entries {
+			if err := txn.Delete(poolKey(entry.Transaction.ID)); err != nil {
+				return err
+			}
+			transactions = append(transactions, entry.Transaction)
+		}
+		return nil
+	})
+	PanicHandle(err)
+	// a child refused before its parent is added is tried again, until a round adds none
+	for len(transactions) > 0 {
+		var refused []*Transaction
+		for _, tx := range transactions {
+			if err := pool.Add(tx); err != nil {
+				refused = append(refused, tx)
+			}
+		}
+		if len(refused) == len(transactions) {
+			break
+		}
+		transactions = refused
+	}
+}
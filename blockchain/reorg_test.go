@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/the-code-innovator/go-blockchain/params"
+)
+
+func TestReorganizeRollsBackInvalidBranch(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	forkPoint := chain.GenerateBlock(address)
+	tx := testPayment(t, chain, wallets, address, payee, 30, TxOptions{})
+	pending(t, chain, tx)
+	last := chain.GenerateBlock(address)
+	before := map[string]int{address: balance(t, chain, address), payee: balance(t, chain, payee)}
+
+	subsidy := params.Active().Subsidy
+	valid := forkBlock(forkPoint, address, subsidy)
+	invalid := forkBlock(valid, address, subsidy+1)
+	branch := []*Block{valid, invalid, forkBlock(invalid, address, subsidy)}
+	accepted, err := chain.Reorganize(branch)
+	if err == nil {
+		t.Fatal("a branch with a coinbase claiming too much is accepted")
+	}
+	if accepted != 1 {
+		t.Errorf("reorganize reports %d blocks accepted, want 1", accepted)
+	}
+	if !bytes.Equal(chain.LastHash, last.Hash) || !chain.HasBlock(last.Hash) || chain.HasBlock(valid.Hash) {
+		t.Fatal("the blockchain is not switched back to its last block")
+	}
+	for owner, want := range before {
+		if got := balance(t, chain, owner); got != want {
+			t.Errorf("balance of %s is %d after the rollback, want %d", owner, got, want)
+		}
+	}
+	if _, err := chain.FindTransaction(tx.ID); err != nil {
+		t.Errorf("the payment is no longer confirmed: %v", err)
+	}
+	if _, ok := chain.TxPool().Get(tx.ID); ok {
+		t.Error("the payment went back to the pool")
+	}
+	// the blockchain goes on from its last block
+	if block := chain.GenerateBlock(address); block.Height != last.Height+1 {
+		t.Errorf("the next block is at height %d, want %d", block.Height, last.Height+1)
+	}
+}
+
+func TestReorganizeRefusesUnknownFork(t *testing.T) {
+	chain, _, address := newTestChain(t)
+	chain.GenerateBlock(address)
+	stranger := &Block{Hash: bytes.Repeat([]byte{1}, 32), Height: 1}
+	if _, err := chain.Reorganize([]*Block{forkBlock(stranger, address, params.Active().Subsidy)}); err == nil {
+		t.Error("a branch forking off an unknown block is accepted")
+	}
+	if _, err := chain.Reorganize(nil); err == nil {
+		t.Error("an empty branch is accepted")
+	}
+}
+
+func TestReorganizeRestoresPool(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	forkPoint := chain.GenerateBlock(address)
+	paid := testPayment(t, chain, wallets, address, payee, 30, TxOptions{Fee: 5})
+	pending(t, chain, paid)
+	replaced := chain.GenerateBlock(address)
+	// a payment of the payee spending the mined one waits in the pool
+	child := testPayment(t, chain, wallets, payee, address, 10, TxOptions{Fee: 2})
+	pending(t, chain, child)
+
+	subsidy := params.Active().Subsidy
+	fork := forkBlock(forkPoint, address, subsidy)
+	branch := []*Block{fork, forkBlock(fork, address, subsidy)}
+	if accepted, err := chain.Reorganize(branch); err != nil || accepted != 2 {
+		t.Fatalf("reorganize accepts %d blocks: %v", accepted, err)
+	}
+	if !bytes.Equal(chain.LastHash, branch[1].Hash) || chain.HasBlock(replaced.Hash) {
+		t.Fatal("the blockchain is not switched to the fork")
+	}
+	// the undo data restores the outputs the payment spent and removes those of the replaced block
+	if _, err := chain.FindTransaction(paid.ID); err == nil {
+		t.Error("the payment of the replaced block is still confirmed")
+	}
+	if got, want := balance(t, chain, address), 4*subsidy; got != want {
+		t.Errorf("balance of the miner is %d, want %d", got, want)
+	}
+	if got := balance(t, chain, payee); got != 0 {
+		t.Errorf("balance of the payee is %d, want 0", got)
+	}
+	if !inPool(chain, paid) || !inPool(chain, child) {
+		t.Fatal("the payment and its child do not go back to the pool")
+	}
+	block := chain.GenerateBlock(address)
+	if _, err := chain.FindTransaction(paid.ID); err != nil || len(block.Transactions) != 3 {
+		t.Error("the restored transactions are not mined on the fork")
+	}
+	if got := balance(t, chain, payee); got != 30-12 {
+		t.Errorf("balance of the payee is %d, want %d", got, 30-12)
+	}
+}
+
+func TestReorganizeDropsConflictingPayments(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	forkPoint := chain.GenerateBlock(address)
+	paid := rawPayment(t, chain, wallets, address, payee, 30, TxOptions{})
+	pending(t, chain, &paid.Transaction)
+	chain.GenerateBlock(address)
+	child := testPayment(t, chain, wallets, payee, address, 10, TxOptions{})
+	pending(t, chain, child)
+
+	// the fork mines a payment of the same coins to someone else
+	other := addKey(wallets)
+	doubleSpend := resign(t, wallets, paid, func(raw *RawTransaction) {
+		raw.Transaction.Outputs[0] = *NewTxOutput(30, other)
+	})
+	subsidy := params.Active().Subsidy
+	fork := forkBlock(forkPoint, address, subsidy, &doubleSpend.Transaction)
+	branch := []*Block{fork, forkBlock(fork, address, subsidy)}
+	if accepted, err := chain.Reorganize(branch); err != nil || accepted != 2 {
+		t.Fatalf("reorganize accepts %d blocks: %v", accepted, err)
+	}
+	if inPool(chain, &paid.Transaction) || inPool(chain, child) {
+		t.Error("payments conflicting with the fork go back to the pool")
+	}
+	if len(chain.TxPool().Entries()) != 0 {
+		t.Error("the pool is not empty")
+	}
+	if got := balance(t, chain, other); got != 30 {
+		t.Errorf("balance of the payee of the fork is %d, want 30", got)
+	}
+	if got := balance(t, chain, payee); got != 0 {
+		t.Errorf("balance of the payee of the replaced block is %d, want 0", got)
+	}
+}
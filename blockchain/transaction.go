@@ -2,94 +2,242 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 
+	"github.com/the-code-innovator/go-blockchain/params"
+	"github.com/the-code-innovator/go-blockchain/script"
 	"github.com/the-code-innovator/go-blockchain/wallet"
 )
 
-// Transaction structure for the Transaction type in the blockchain
+// Transaction structure for the Transaction type in the blockchain, a Transaction
+// cannot be mined before its LockTime
 type Transaction struct {
-	ID      []byte
-	Inputs  []TxInput
-	Outputs []TxOutput
+	ID       []byte
+	Inputs   []TxInput
+	Outputs  []TxOutput
+	LockTime int64
 }
 
-// CoinBaseTx for the coin base transaction
+// CoinBaseTx for the coin base transaction paying the subsidy of the active network
 func CoinBaseTx(to, data string) *Transaction {
+	return coinBase(to, data, params.Active().Subsidy)
+}
+
+// coinBase to create a coin base transaction paying the value to the address
+func coinBase(to, data string, value int) *Transaction {
 	if data == "" {
 		data = fmt.Sprintf("COINS TO %s\n", to)
 	}
-	txin := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTxOutput(100, to)
-	tx := Transaction{nil, []TxInput{txin}, []TxOutput{*txout}}
+	txin := TxInput{ID: []byte{}, Out: -1, ScriptSig: script.Script{}.AddData([]byte(data)), Sequence: MaxSequence}
+	txout := NewTxOutput(value, to)
+	tx := Transaction{nil, []TxInput{txin}, []TxOutput{*txout}, 0}
+	tx.SetID()
+	return &tx
+}
+
+// emptyCoinBase to create the coinbase of a block mined with no address to pay, it claims nothing and the
+// data naming the height keeps its ID apart from those of other blocks
+func emptyCoinBase(height int) *Transaction {
+	txin := TxInput{ID: []byte{}, Out: -1, ScriptSig: script.Script{}.AddData([]byte(fmt.Sprintf("BLOCK AT HEIGHT %d", height))), Sequence: MaxSequence}
+	tx := Transaction{nil, []TxInput{txin}, nil, 0}
 	tx.SetID()
 	return &tx
 }
 
+// TxOptions structure for the choices made while funding a new Transaction, LockTime
+// is a block height or Unix time and RelativeLock a Sequence from RelativeLockBlocks
+// or RelativeLockDuration applied to every input, Fee is left to the miner and a
+// Replaceable Transaction can be replaced by one paying a higher fee while it is pending,
+// SpendPending lets it spend outputs of pending transactions, so it has to wait in the pool too
+type TxOptions struct {
+	Selector     CoinSelector
+	LockTime     int64
+	RelativeLock uint32
+	Fee          int
+	Replaceable  bool
+	SpendPending bool
+}
+
+// sequence to find the Sequence of the inputs, leaving lock times disabled unless asked for,
+// any Sequence below MaxSequence-1 signals that the Transaction is replaceable
+func (options TxOptions) sequence() uint32 {
+	switch {
+	case options.RelativeLock != 0:
+		return options.RelativeLock
+	case options.Replaceable:
+		return MaxSequence - 2
+	case options.LockTime != 0:
+		return MaxSequence - 1
+	}
+	return MaxSequence
+}
+
+// Payment structure for an amount to pay to an address
+type Payment struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
 // NewTransaction for creating a new Transaction in the BlockChain
-func NewTransaction(from, to string, amount int, blockchain *BlockChain) *Transaction {
-	var inputs []TxInput
-	var outputs []TxOutput
+func NewTransaction(from, to string, amount int, options TxOptions, blockchain *BlockChain) *Transaction {
+	return NewMultiOutputTransaction(from, []Payment{{to, amount}}, options, blockchain)
+}
 
+// NewMultiOutputTransaction for creating a new Transaction paying every payment from a single address
+func NewMultiOutputTransaction(from string, payments []Payment, options TxOptions, blockchain *BlockChain) *Transaction {
 	wallets, err := wallet.CreateWallets()
 	PanicHandle(err)
-	w := wallets.GetWallet(from)
-	publicKeyHash := wallet.PublicKeyHash(w.PublicKey)
+	return newTransaction(wallets, []string{from}, payments, options, blockchain)
+}
+
+// NewWalletTransaction for creating a new Transaction funded from any of the addresses in the wallets file
+func NewWalletTransaction(payments []Payment, options TxOptions, blockchain *BlockChain) *Transaction {
+	wallets, err := wallet.CreateWallets()
+	PanicHandle(err)
+	return newTransaction(wallets, wallets.GetAllAddresses(), payments, options, blockchain)
+}
+
+// ValidatePayments to check that the payments can be paid by one transaction
+func ValidatePayments(payments []Payment) error {
+	if len(payments) == 0 {
+		return errors.New("no payments given")
+	}
+	seen := make(map[string]bool)
+	for _, payment := range payments {
+		if !wallet.ValidateAddress(payment.Address) {
+			return fmt.Errorf("address %s is not valid", payment.Address)
+		}
+		if payment.Amount <= 0 {
+			return fmt.Errorf("amount %d to %s is not positive", payment.Amount, payment.Address)
+		}
+		// the Base58 and Bech32 forms of an address lock to the same hash
+		key := wallet.Base58Address(payment.Address)
+		if seen[key] {
+			return fmt.Errorf("address %s is paid more than once", payment.Address)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// PaymentsTotal to add up the amounts of the payments
+func PaymentsTotal(payments []Payment) int {
+	total := 0
+	for _, payment := range payments {
+		total += payment.Amount
+	}
+	return total
+}
 
-	accumulator, validOutputs := blockchain.FindSpendableOutputs(publicKeyHash, amount)
-	if accumulator < amount {
+// SourcePublicKeyHashes to get the public key hashes of the wallets of the source addresses
+func SourcePublicKeyHashes(wallets *wallet.Wallets, sources []string) [][]byte {
+	var publicKeyHashes [][]byte
+	for _, source := range sources {
+		_, publicKey, ok := wallets.KeyFor(source)
+		if !ok {
+			log.Panic("ERROR: ADDRESS IS NOT IN THE WALLET !")
+		}
+		publicKeyHashes = append(publicKeyHashes, wallet.PublicKeyHash(publicKey))
+	}
+	return publicKeyHashes
+}
+
+// newTransaction to fund the payments from the source addresses and sign every input with its own key
+func newTransaction(wallets *wallet.Wallets, sources []string, payments []Payment, options TxOptions, blockchain *BlockChain) *Transaction {
+	selection, err := fundPayments(SourcePublicKeyHashes(wallets, sources), payments, options, blockchain)
+	if err == ErrInsufficientFunds {
 		log.Panic("ERROR: NOT ENOUGH FUNDS !")
 	}
-	for txID, outputs := range validOutputs {
-		txIDString, err := hex.DecodeString(txID)
+	PanicHandle(err)
+	return signedTransaction(wallets, selection, payments, nil, options, blockchain)
+}
+
+// signedTransaction to lay out the selection, the payments, the extra outputs and the change,
+// then sign every input with the key of the output it spends
+func signedTransaction(wallets *wallet.Wallets, selection Selection, payments []Payment, extra []TxOutput, options TxOptions, blockchain *BlockChain) *Transaction {
+	changeAddress := ""
+	if selection.Change > 0 {
+		changeAddress = wallets.ChangeAddress(selection.Inputs[0].Output.Address())
+	}
+	tx := unsignedTransaction(selection, payments, changeAddress, options)
+	tx.Outputs = append(append([]TxOutput{}, extra...), tx.Outputs...)
+	tx.SetID()
+	blockchain.SignTransactionWithWallets(&tx, wallets)
+	if changeAddress != "" {
+		// the change key is kept once the Transaction locking funds to it is signed and verifies, it
+		// derives from the key spending so a backup of that key restores it should the Transaction be mined later
+		previousTXs, err := blockchain.TxPool().previousTransactions(&tx)
 		PanicHandle(err)
-		for _, output := range outputs {
-			input := TxInput{txIDString, output, nil, w.PublicKey}
-			inputs = append(inputs, input)
+		if !tx.Verify(previousTXs) {
+			log.Panic("ERROR: TRANSACTION IS NOT VALID !")
 		}
+		wallets.SaveFile()
 	}
-	outputs = append(outputs, *NewTxOutput(amount, to))
-	if accumulator > amount {
-		outputs = append(outputs, *NewTxOutput(accumulator-amount, from))
-	}
-	tx := Transaction{nil, inputs, outputs}
-	// tx.SetID()
-	tx.ID = tx.Hash()
-	blockchain.SignTransaction(&tx, w.PrivateKey)
 	return &tx
 }
 
-// Sign to sign the transation block to enable chaining
-func (tx *Transaction) Sign(privateKey ecdsa.PrivateKey, previousTXs map[string]Transaction) {
+// fundPayments to select the unspent outputs of the public key hashes that pay for the payments and the fee
+func fundPayments(publicKeyHashes [][]byte, payments []Payment, options TxOptions, blockchain *BlockChain) (Selection, error) {
+	if err := ValidatePayments(payments); err != nil {
+		return Selection{}, err
+	}
+	selector := options.Selector
+	if selector == nil {
+		selector, _ = NewCoinSelector("")
+	}
+	if options.Fee < 0 {
+		return Selection{}, errors.New("fee is negative")
+	}
+	return blockchain.selectCoins(publicKeyHashes, PaymentsTotal(payments)+options.Fee, selector, options.SpendPending)
+}
+
+// unsignedTransaction to lay out the inputs of the selection, one output per payment and the change output
+func unsignedTransaction(selection Selection, payments []Payment, changeAddress string, options TxOptions) Transaction {
+	var inputs []TxInput
+	var outputs []TxOutput
+	for _, unSpent := range selection.Inputs {
+		inputs = append(inputs, TxInput{ID: unSpent.ID, Out: unSpent.Out, Sequence: options.sequence()})
+	}
+	for _, payment := range payments {
+		outputs = append(outputs, *NewTxOutput(payment.Amount, payment.Address))
+	}
+	if selection.Change > 0 {
+		outputs = append(outputs, *NewTxOutput(selection.Change, changeAddress))
+	}
+	return Transaction{nil, inputs, outputs, options.LockTime}
+}
+
+// Sign to sign the transation block to enable chaining, every input is unlocked with
+// the public key of the private key, tagged with its scheme
+func (tx *Transaction) Sign(privateKey wallet.PrivateKey, previousTXs map[string]Transaction) {
 	if tx.IsCoinBase() {
 		return
 	}
-	for _, in := range tx.Inputs {
-		if previousTXs[hex.EncodeToString(in.ID)].ID == nil {
-			log.Panic("ERROR: PREVIOUS TRANSACTION DOES NOT EXIST !")
-		}
+	previousOutputs := previousOutputsOf(tx, previousTXs)
+	publicKey := privateKey.PublicKey()
+	for inID := range tx.Inputs {
+		tx.SignInput(inID, privateKey, publicKey, previousOutputs[inID])
 	}
+}
+
+// SignInput to sign a single input of the transaction with the key owning the output it spends,
+// the ScriptSig pushes the signature and the public key for the pay to public key hash template
+func (tx *Transaction) SignInput(inID int, privateKey wallet.PrivateKey, publicKey []byte, previousOutput TxOutput) {
+	signature := signHash(privateKey, tx.SignatureHash(inID, previousOutput))
+	tx.Inputs[inID].ScriptSig = script.Script{}.AddData(signature).AddData(publicKey)
+}
+
+// SignatureHash to get the hash an input signs, committing to the ScriptPubKey of the output that it spends
+func (tx *Transaction) SignatureHash(inID int, previousOutput TxOutput) []byte {
 	txCopy := tx.TrimmedCopy()
-	for inID, in := range txCopy.Inputs {
-		previousTX := previousTXs[hex.EncodeToString(in.ID)]
-		txCopy.Inputs[inID].Signature = nil
-		txCopy.Inputs[inID].PublicKey = previousTX.Outputs[in.Out].PublicKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[inID].PublicKey = nil
-		r, s, err := ecdsa.Sign(rand.Reader, &privateKey, txCopy.ID)
-		PanicHandle(err)
-		signature := append(r.Bytes(), s.Bytes()...)
-		tx.Inputs[inID].Signature = signature
-	}
+	txCopy.Inputs[inID].ScriptSig = previousOutput.ScriptPubKey
+	return txCopy.Hash()
 }
 
 // Verify to verify the signature of the signed transactions
@@ -97,35 +245,98 @@ func (tx *Transaction) Verify(previousTXs map[string]Transaction) bool {
 	if tx.IsCoinBase() {
 		return true
 	}
-	for _, in := range tx.Inputs {
-		if previousTXs[hex.EncodeToString(in.ID)].ID == nil {
-			log.Panic("ERROR: PREVIOUS TRANSACTION DOES NOT EXIST !")
+	previousOutputs := previousOutputsOf(tx, previousTXs)
+	for inID := range tx.Inputs {
+		if !tx.VerifyInput(inID, previousOutputs[inID]) {
+			return false
 		}
 	}
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-	for inID, in := range tx.Inputs {
+	return true
+}
+
+// VerifyInput to run the ScriptSig of a single input against the ScriptPubKey of the output it spends
+func (tx *Transaction) VerifyInput(inID int, previousOutput TxOutput) bool {
+	checker := &inputChecker{tx, inID, previousOutput}
+	return script.Verify(tx.Inputs[inID].ScriptSig, previousOutput.ScriptPubKey, checker) == nil
+}
+
+// inputChecker structure for the checks the scripts of an input delegate to the Transaction
+type inputChecker struct {
+	tx             *Transaction
+	inID           int
+	previousOutput TxOutput
+}
+
+// CheckSignature to verify the signature over the signature hash of the input
+func (checker *inputChecker) CheckSignature(signature, publicKey []byte) bool {
+	return verifySignature(publicKey, signature, checker.tx.SignatureHash(checker.inID, checker.previousOutput))
+}
+
+// CheckLockTime to check that the LockTime of the Transaction is of the same kind and at
+// least the lock time of the script, so the script cannot be spent before it
+func (checker *inputChecker) CheckLockTime(lockTime int64) bool {
+	txLockTime := checker.tx.LockTime
+	if (lockTime < LockTimeThreshold) != (txLockTime < LockTimeThreshold) || lockTime > txLockTime {
+		return false
+	}
+	// a final input would let the Transaction be mined whatever its LockTime
+	return checker.tx.Inputs[checker.inID].Sequence != MaxSequence
+}
+
+// SignMultisigInput to fill the signature slot of the public key in an input spending a multi-signature output,
+// the ScriptSig pushes one slot per public key, empty for the missing signatures, followed by the redeem script
+func (tx *Transaction) SignMultisigInput(inID int, multisig *wallet.Multisig, w *wallet.Wallet, previousOutput TxOutput) bool {
+	in := &tx.Inputs[inID]
+	redeem := multisig.Redeem()
+	signatures := multisigSignatures(in.ScriptSig, redeem, len(multisig.PublicKeys))
+	signed := false
+	for keyID, publicKey := range multisig.PublicKeys {
+		if wallet.SamePublicKey(publicKey, w.PublicKey) {
+			signatures[keyID] = signHash(w.PrivateKey, tx.SignatureHash(inID, previousOutput))
+			signed = true
+		}
+	}
+	scriptSig := script.Script{}
+	for _, signature := range signatures {
+		scriptSig = scriptSig.AddData(signature)
+	}
+	in.ScriptSig = scriptSig.AddData(redeem)
+	return signed
+}
+
+// multisigSignatures to get the signature slots of a multi-signature ScriptSig, all empty
+// when the ScriptSig does not yet unlock the redeem script
+func multisigSignatures(scriptSig script.Script, redeem []byte, count int) [][]byte {
+	pushed, err := scriptSig.PushedData()
+	if err != nil || len(pushed) != count+1 || !bytes.Equal(pushed[count], redeem) {
+		return make([][]byte, count)
+	}
+	return pushed[:count]
+}
+
+// signHash to sign the hash with the scheme of the private key, always giving the same 64 byte signature
+func signHash(privateKey wallet.PrivateKey, hash []byte) []byte {
+	signature, err := privateKey.Sign(hash)
+	PanicHandle(err)
+	return signature
+}
+
+// verifySignature to verify a signature over the hash with the scheme the public key is tagged with
+func verifySignature(publicKey, signature, hash []byte) bool {
+	return wallet.VerifySignature(publicKey, signature, hash)
+}
+
+// previousOutputsOf to look up the output spent by every input of the transaction
+func previousOutputsOf(tx *Transaction, previousTXs map[string]Transaction) []TxOutput {
+	var previousOutputs []TxOutput
+	for _, in := range tx.Inputs {
 		previousTX := previousTXs[hex.EncodeToString(in.ID)]
-		txCopy.Inputs[inID].Signature = nil
-		txCopy.Inputs[inID].PublicKey = previousTX.Outputs[in.Out].PublicKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[inID].PublicKey = nil
-		r := big.Int{}
-		s := big.Int{}
-		signLength := len(in.Signature)
-		r.SetBytes(in.Signature[:(signLength / 2)])
-		s.SetBytes(in.Signature[(signLength / 2):])
-		x := big.Int{}
-		y := big.Int{}
-		keyLength := len(in.PublicKey)
-		x.SetBytes(in.PublicKey[:(keyLength / 2)])
-		y.SetBytes(in.PublicKey[(keyLength / 2):])
-		rawPublicKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if !ecdsa.Verify(&rawPublicKey, txCopy.ID, &r, &s) {
-			return false
+		if previousTX.ID == nil || in.Out < 0 || in.Out >= len(previousTX.Outputs) {
+			log.Panic("ERROR: PREVIOUS TRANSACTION DOES NOT EXIST !")
 		}
+		previousOutputs = append(previousOutputs, previousTX.Outputs[in.Out])
 	}
-	return true
+	return previousOutputs
 }
 
 // String to output transaction based output
@@ -136,13 +347,18 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("   • Input %d •", i))
 		lines = append(lines, fmt.Sprintf("     • Treansaction ID  : %x", input.ID))
 		lines = append(lines, fmt.Sprintf("     • Out              : %d", input.Out))
-		lines = append(lines, fmt.Sprintf("       • Signature      : %x", input.Signature))
-		lines = append(lines, fmt.Sprintf("       • PublicKey      : %x", input.PublicKey))
+		lines = append(lines, fmt.Sprintf("       • ScriptSig      : %s", input.ScriptSig))
+		if input.Sequence != MaxSequence {
+			lines = append(lines, fmt.Sprintf("       • Sequence       : %#x", input.Sequence))
+		}
 	}
 	for i, output := range tx.Outputs {
 		lines = append(lines, fmt.Sprintf("   • Output %d •", i))
 		lines = append(lines, fmt.Sprintf("     • Value            : %d", output.Value))
-		lines = append(lines, fmt.Sprintf("     • Script           : %x", output.PublicKeyHash))
+		lines = append(lines, fmt.Sprintf("     • Script           : %s", output.ScriptPubKey))
+	}
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("   • Lock Time        : %s", LockTimeString(tx.LockTime)))
 	}
 	return strings.Join(lines, "\n")
 }
@@ -152,12 +368,12 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var inputs []TxInput
 	var outputs []TxOutput
 	for _, in := range tx.Inputs {
-		inputs = append(inputs, TxInput{in.ID, in.Out, nil, nil})
+		inputs = append(inputs, TxInput{ID: in.ID, Out: in.Out, Sequence: in.Sequence})
 	}
 	for _, out := range tx.Outputs {
-		outputs = append(outputs, TxOutput{out.Value, out.PublicKeyHash})
+		outputs = append(outputs, TxOutput{out.Value, out.ScriptPubKey})
 	}
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.LockTime}
 	return txCopy
 }
 
@@ -166,10 +382,42 @@ func (tx *Transaction) Hash() []byte {
 	var hash [32]byte
 	txCopy := *tx
 	txCopy.ID = []byte{}
-	hash = sha256.Sum256(txCopy.Serialize())
+	hash = sha256.Sum256(txCopy.hashData())
 	return hash[:]
 }
 
+// hashData to lay the transaction out byte for byte, unlike gob whose output depends on
+// which types the process happened to encode first and so differs between processes
+func (tx *Transaction) hashData() []byte {
+	buffer := new(bytes.Buffer)
+	writeBytes(buffer, tx.ID)
+	writeInt(buffer, int64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		writeBytes(buffer, in.ID)
+		writeInt(buffer, int64(in.Out))
+		writeBytes(buffer, in.ScriptSig)
+		writeInt(buffer, int64(in.Sequence))
+	}
+	writeInt(buffer, int64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		writeInt(buffer, int64(out.Value))
+		writeBytes(buffer, out.ScriptPubKey)
+	}
+	writeInt(buffer, tx.LockTime)
+	return buffer.Bytes()
+}
+
+// writeInt to append a fixed width integer to the buffer
+func writeInt(buffer *bytes.Buffer, number int64) {
+	buffer.Write(ToHex(number))
+}
+
+// writeBytes to append a length prefixed byte string to the buffer
+func writeBytes(buffer *bytes.Buffer, data []byte) {
+	writeInt(buffer, int64(len(data)))
+	buffer.Write(data)
+}
+
 // IsCoinBase to check for CoinBase Transaction
 func (tx *Transaction) IsCoinBase() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].ID) == 0 && tx.Inputs[0].Out == -1
@@ -177,13 +425,30 @@ func (tx *Transaction) IsCoinBase() bool {
 
 // SetID to set the ID for the Transaction
 func (tx *Transaction) SetID() {
-	var encoded bytes.Buffer
-	var hash [32]byte
-	encoder := gob.NewEncoder(&encoded)
-	err := encoder.Encode(tx)
-	PanicHandle(err)
-	hash = sha256.Sum256(encoded.Bytes())
-	tx.ID = hash[:]
+	tx.ID = tx.ComputeID()
+}
+
+// ComputeID to hash the contents of the Transaction into its ID, leaving the ScriptSigs out so that signing
+// does not change it, the ScriptSig of a coinbase carries data rather than signatures and is kept
+func (tx *Transaction) ComputeID() []byte {
+	if tx.IsCoinBase() {
+		return tx.Hash()
+	}
+	txCopy := *tx
+	txCopy.Inputs = append([]TxInput{}, tx.Inputs...)
+	for inID := range txCopy.Inputs {
+		txCopy.Inputs[inID].ScriptSig = nil
+	}
+	return txCopy.Hash()
+}
+
+// checkID to check that the ID of the Transaction is the hash of its contents, so that no Transaction
+// can pass for another in the unspent outputs or the pool
+func (tx *Transaction) checkID() error {
+	if !bytes.Equal(tx.ID, tx.ComputeID()) {
+		return errors.New("ID does not match the contents")
+	}
+	return nil
 }
 
 // Serialize to serialize the transaction
@@ -4,21 +4,25 @@ import (
 	"bytes"
 	"encoding/gob"
 
+	"github.com/the-code-innovator/go-blockchain/script"
 	"github.com/the-code-innovator/go-blockchain/wallet"
 )
 
-// TxInput structure for Input for the BlockChain
+// TxInput structure for Input for the BlockChain, the ScriptSig pushes what the
+// ScriptPubKey of the spent output needs to succeed and the Sequence can hold a
+// relative lock time
 type TxInput struct {
 	ID        []byte
 	Out       int
-	Signature []byte
-	PublicKey []byte
+	ScriptSig script.Script
+	Sequence  uint32
 }
 
-// TxOutput structure for Output for the BlockChainw
+// TxOutput structure for Output for the BlockChain, the ScriptPubKey is the script
+// an input has to satisfy to spend the output
 type TxOutput struct {
-	Value         int
-	PublicKeyHash []byte
+	Value        int
+	ScriptPubKey script.Script
 }
 
 // TxOutputs structure for Transaction Outputs for the Transaction Listing
@@ -46,26 +50,86 @@ func DeserializeOutputs(data []byte) TxOutputs {
 
 // NewTxOutput to create a new transaction output for the new transaction that is created by every spend
 func NewTxOutput(value int, address string) *TxOutput {
-	txOut := &TxOutput{value, nil}
+	txOut := &TxOutput{Value: value}
 	txOut.Lock([]byte(address))
 	return txOut
 }
 
-// Lock to lock the transaction from spending without authorisation
+// Lock to lock the transaction from spending without authorisation, script hash addresses
+// get the pay to script hash template and other addresses pay to public key hash
 func (out *TxOutput) Lock(address []byte) {
-	publicKeyHash := wallet.Base58Decode(address)
-	publicKeyHash = publicKeyHash[1 : len(publicKeyHash)-4]
-	// out.PublicKey = publicKeyHash
-	out.PublicKeyHash = publicKeyHash
+	publicKeyHash, scriptHash, err := wallet.DecodeAddress(string(address))
+	PanicHandle(err)
+	if scriptHash {
+		out.ScriptPubKey = script.PayToScriptHash(publicKeyHash)
+		return
+	}
+	out.ScriptPubKey = script.PayToPublicKeyHash(publicKeyHash)
+}
+
+// LockedHash to find the public key or script hash a standard ScriptPubKey locks the output to
+func (out *TxOutput) LockedHash() []byte {
+	if publicKeyHash, ok := out.ScriptPubKey.PublicKeyHash(); ok {
+		return publicKeyHash
+	}
+	scriptHash, _ := out.ScriptPubKey.ScriptHash()
+	return scriptHash
+}
+
+// Address to find the address the output is locked to, empty for non standard scripts
+func (out *TxOutput) Address() string {
+	if publicKeyHash, ok := out.ScriptPubKey.PublicKeyHash(); ok {
+		return string(wallet.AddressFromPublicKeyHash(publicKeyHash))
+	}
+	if scriptHash, ok := out.ScriptPubKey.ScriptHash(); ok {
+		return string(wallet.AddressFromScriptHash(scriptHash))
+	}
+	return ""
+}
+
+// unlockingData to find the public key or redeem script the ScriptSig pushes last
+func (in *TxInput) unlockingData() []byte {
+	pushed, err := in.ScriptSig.PushedData()
+	if err != nil || len(pushed) == 0 {
+		return nil
+	}
+	return pushed[len(pushed)-1]
+}
+
+// LockingHash to find the hash of the key or redeem script the input unlocks with
+func (in *TxInput) LockingHash() []byte {
+	return wallet.PublicKeyHash(in.unlockingData())
+}
+
+// Address to find the address of the key, multi-signature account or contract the input unlocks with
+func (in *TxInput) Address() string {
+	if isRedeemScript(in.unlockingData()) {
+		return string(wallet.AddressFromScriptHash(in.LockingHash()))
+	}
+	return string(wallet.AddressFromPublicKeyHash(in.LockingHash()))
+}
+
+// isRedeemScript to check whether the data is a redeem script of a template paid to by script hash,
+// multi-signature, MuSig or contract
+func isRedeemScript(data []byte) bool {
+	redeem := script.Script(data)
+	if _, _, ok := redeem.ExtractMultisig(); ok {
+		return true
+	}
+	if _, ok := redeem.PublicKey(); ok {
+		return true
+	}
+	_, _, _, _, ok := redeem.ExtractHashTimeLock()
+	return ok
 }
 
 // UsesKey to check for unlocking
 func (in *TxInput) UsesKey(publicKeyHash []byte) bool {
-	lockingHash := wallet.PublicKeyHash(in.PublicKey)
+	lockingHash := in.LockingHash()
 	return bytes.Equal(lockingHash, publicKeyHash)
 }
 
 // IsLockedWithKey to verify that the transaction is locked with only the users public key
 func (out *TxOutput) IsLockedWithKey(publicKeyHash []byte) bool {
-	return bytes.Equal(out.PublicKeyHash, publicKeyHash)
+	return bytes.Equal(out.LockedHash(), publicKeyHash)
 }
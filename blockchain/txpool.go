@@ -0,0 +1,502 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/the-code-innovator/go-blockchain/params"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// poolPrefix is the prefix every pending Transaction is kept under, by its ID
+var poolPrefix = []byte("pool-")
+
+// TxPool structure for the transactions waiting to be mined, kept in the badger.DB of the BlockChain
+// so that they survive between commands
+type TxPool struct {
+	blockchain *BlockChain
+}
+
+// PoolEntry structure for a pending Transaction with the fee it pays and its size
+type PoolEntry struct {
+	Transaction *Transaction
+	Fee         int
+	Size        int
+}
+
+// FeeRate to get the fee paid per 1000 bytes of the Transaction
+func (entry *PoolEntry) FeeRate() float64 {
+	return FeeRate(entry.Fee, entry.Size)
+}
+
+// FeeRate to get the fee paid per 1000 bytes
+func FeeRate(fee, size int) float64 {
+	return float64(fee) * 1000 / float64(size)
+}
+
+// higherFeeRate to compare fee rates exactly, true when fee over size pays more than otherFee over otherSize
+func higherFeeRate(fee, size, otherFee, otherSize int) bool {
+	return int64(fee)*int64(otherSize) > int64(otherFee)*int64(size)
+}
+
+// poolKey to build the key of a pending Transaction
+func poolKey(txID []byte) []byte {
+	return append(append([]byte{}, poolPrefix...), txID...)
+}
+
+// TxPool to get the pool of transactions waiting to be mined into the BlockChain
+func (chain *BlockChain) TxPool() *TxPool {
+	return &TxPool{chain}
+}
+
+// Size to get the number of bytes the Transaction takes in its canonical layout
+func (tx *Transaction) Size() int {
+	return len(tx.hashData())
+}
+
+// IsReplaceable to check whether the Transaction opts in to being replaced by one paying a higher fee,
+// signalled by an input whose Sequence is below MaxSequence-1
+func (tx *Transaction) IsReplaceable() bool {
+	for _, in := range tx.Inputs {
+		if in.Sequence < MaxSequence-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// DeserializeTransaction to deserialize a Transaction from badger.DB
+func DeserializeTransaction(data []byte) *Transaction {
+	var tx Transaction
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tx)
+	PanicHandle(err)
+	return &tx
+}
+
+// Entries to get every pending Transaction with its fee, highest fee rate first, loaded in one pass
+// over the pool so that an operation looking at many of them loads them once
+func (pool *TxPool) Entries() []*PoolEntry {
+	var transactions []*Transaction
+	err := pool.blockchain.DataBase.View(func(txn *badger.Txn) error {
+		iterator := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iterator.Close()
+		for iterator.Seek(poolPrefix); iterator.ValidForPrefix(poolPrefix); iterator.Next() {
+			data, err := iterator.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			transactions = append(transactions, DeserializeTransaction(data))
+		}
+		return nil
+	})
+	PanicHandle(err)
+	pending := make(map[string]*Transaction)
+	for _, tx := range transactions {
+		pending[hex.EncodeToString(tx.ID)] = tx
+	}
+	utxo := UTXO{pool.blockchain}
+	var entries []*PoolEntry
+	for _, tx := range transactions {
+		entries = append(entries, pool.newEntry(tx, pending, &utxo))
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return higherFeeRate(entries[i].Fee, entries[i].Size, entries[j].Fee, entries[j].Size)
+	})
+	return entries
+}
+
+// Get to find a pending Transaction by its ID, reading it and the pending parents it spends from by their keys
+func (pool *TxPool) Get(txID []byte) (*PoolEntry, bool) {
+	tx, ok := pool.transaction(txID)
+	if !ok {
+		return nil, false
+	}
+	pending := make(map[string]*Transaction)
+	for _, in := range tx.Inputs {
+		if parent, ok := pool.transaction(in.ID); ok {
+			pending[hex.EncodeToString(in.ID)] = parent
+		}
+	}
+	utxo := UTXO{pool.blockchain}
+	return pool.newEntry(tx, pending, &utxo), true
+}
+
+// transaction to read a pending Transaction by its ID from its key in the pool
+func (pool *TxPool) transaction(txID []byte) (*Transaction, bool) {
+	var tx *Transaction
+	err := pool.blockchain.DataBase.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(poolKey(txID))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		tx = DeserializeTransaction(data)
+		return nil
+	})
+	PanicHandle(err)
+	return tx, tx != nil
+}
+
+// newEntry to work out the fee of a pending Transaction from the outputs it spends
+func (pool *TxPool) newEntry(tx *Transaction, pending map[string]*Transaction, utxo *UTXO) *PoolEntry {
+	fee := 0
+	for _, in := range tx.Inputs {
+		if output, ok := pool.previousOutput(in, pending, utxo); ok {
+			fee += output.Value
+		}
+	}
+	for _, out := range tx.Outputs {
+		fee -= out.Value
+	}
+	return &PoolEntry{tx, fee, tx.Size()}
+}
+
+// previousOutput to find the output an input spends among the unspent outputs or the outputs of pending transactions
+func (pool *TxPool) previousOutput(in TxInput, pending map[string]*Transaction, utxo *UTXO) (TxOutput, bool) {
+	if output, ok := utxo.Get(in.ID, in.Out); ok {
+		return output, true
+	}
+	parent, ok := pending[hex.EncodeToString(in.ID)]
+	if !ok || in.Out < 0 || in.Out >= len(parent.Outputs) {
+		return TxOutput{}, false
+	}
+	return parent.Outputs[in.Out], true
+}
+
+// Add to check a signed Transaction and leave it waiting to be mined, a Transaction spending the same
+// outputs as pending ones replaces them when every one of them is replaceable and it pays a strictly higher
+// fee than they and their descendants together, at a strictly higher fee rate than each of them
+func (pool *TxPool) Add(tx *Transaction) error {
+	return pool.add(tx, pool.Entries())
+}
+
+// add to check the Transaction and put it in the pool against the entries already loaded from it
+func (pool *TxPool) add(tx *Transaction, entries []*PoolEntry) error {
+	if tx.IsCoinBase() {
+		return errors.New("a coinbase transaction cannot wait in the pool")
+	}
+	if err := tx.checkID(); err != nil {
+		return err
+	}
+	if err := tx.checkDataOutputs(); err != nil {
+		return err
+	}
+	// a block would reject the second input spending an output, so the pool does too
+	spends := make(map[string]bool)
+	for inID, in := range tx.Inputs {
+		if spends[outpoint(in.ID, in.Out)] {
+			return fmt.Errorf("input %d spends an output another input already spends", inID)
+		}
+		spends[outpoint(in.ID, in.Out)] = true
+	}
+	pending := make(map[string]*Transaction)
+	spentBy := make(map[string]*PoolEntry)
+	for _, entry := range entries {
+		if bytes.Equal(entry.Transaction.ID, tx.ID) {
+			return errors.New("transaction is already in the pool")
+		}
+		pending[hex.EncodeToString(entry.Transaction.ID)] = entry.Transaction
+		for _, in := range entry.Transaction.Inputs {
+			spentBy[outpoint(in.ID, in.Out)] = entry
+		}
+	}
+	outputTotal, err := tx.outputTotal()
+	if err != nil {
+		return err
+	}
+	utxo := UTXO{pool.blockchain}
+	inputTotal := 0
+	conflicts := make(map[string]*PoolEntry)
+	for inID, in := range tx.Inputs {
+		previousOutput, ok := pool.previousOutput(in, pending, &utxo)
+		if !ok {
+			return fmt.Errorf("input %d spends an output that is missing or already spent", inID)
+		}
+		if !tx.VerifyInput(inID, previousOutput) {
+			return fmt.Errorf("input %d is not signed", inID)
+		}
+		if inputTotal, err = addValue(inputTotal, previousOutput.Value); err != nil {
+			return fmt.Errorf("input %d: %v", inID, err)
+		}
+		if conflict, ok := spentBy[outpoint(in.ID, in.Out)]; ok {
+			conflicts[hex.EncodeToString(conflict.Transaction.ID)] = conflict
+		}
+	}
+	if outputTotal > inputTotal {
+		return errors.New("transaction pays out more than it spends")
+	}
+	fee := inputTotal - outputTotal
+	context := pool.blockchain.lockContext()
+	if !tx.IsFinal(context.height, context.medianTime) {
+		return fmt.Errorf("transaction is locked until after %s", LockTimeString(tx.LockTime))
+	}
+	evicted, err := replacements(tx, fee, conflicts, entries)
+	if err != nil {
+		return err
+	}
+	for _, in := range tx.Inputs {
+		if _, ok := evicted[hex.EncodeToString(in.ID)]; ok {
+			return errors.New("transaction spends an output of a transaction it replaces")
+		}
+	}
+	err = pool.blockchain.DataBase.Update(func(txn *badger.Txn) error {
+		for _, entry := range evicted {
+			if err := txn.Delete(poolKey(entry.Transaction.ID)); err != nil {
+				return err
+			}
+		}
+		return txn.Set(poolKey(tx.ID), tx.Serialize())
+	})
+	PanicHandle(err)
+	return nil
+}
+
+// replacements to check the replace-by-fee rules for a Transaction conflicting with pending ones,
+// returning the pending transactions it evicts: the conflicts and their descendants
+func replacements(tx *Transaction, fee int, conflicts map[string]*PoolEntry, entries []*PoolEntry) (map[string]*PoolEntry, error) {
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	size := tx.Size()
+	for _, conflict := range conflicts {
+		if !conflict.Transaction.IsReplaceable() {
+			return nil, fmt.Errorf("transaction conflicts with %x, which is not replaceable", conflict.Transaction.ID)
+		}
+		if !higherFeeRate(fee, size, conflict.Fee, conflict.Size) {
+			return nil, fmt.Errorf("fee rate %.2f does not beat %.2f of %x", FeeRate(fee, size), conflict.FeeRate(), conflict.Transaction.ID)
+		}
+	}
+	evicted := descendants(conflicts, entries)
+	replacedFee := 0
+	for _, entry := range evicted {
+		replacedFee += entry.Fee
+	}
+	if fee <= replacedFee {
+		return nil, fmt.Errorf("fee %d does not beat the %d paid by the transactions it replaces", fee, replacedFee)
+	}
+	return evicted, nil
+}
+
+// descendants to collect the pending transactions and every pending Transaction spending their outputs
+func descendants(roots map[string]*PoolEntry, entries []*PoolEntry) map[string]*PoolEntry {
+	collected := make(map[string]*PoolEntry)
+	for txID, entry := range roots {
+		collected[txID] = entry
+	}
+	for grown := true; grown; {
+		grown = false
+		for _, entry := range entries {
+			txID := hex.EncodeToString(entry.Transaction.ID)
+			if _, ok := collected[txID]; ok {
+				continue
+			}
+			for _, in := range entry.Transaction.Inputs {
+				if _, ok := collected[hex.EncodeToString(in.ID)]; ok {
+					collected[txID] = entry
+					grown = true
+					break
+				}
+			}
+		}
+	}
+	return collected
+}
+
+// outpoint to name the output of a Transaction
+func outpoint(txID []byte, out int) string {
+	return fmt.Sprintf("%x:%d", txID, out)
+}
+
+// SpentOutputs to get the outputs spent by pending transactions, which the wallet must not select again
+func (pool *TxPool) SpentOutputs() map[string]bool {
+	spent := make(map[string]bool)
+	for _, entry := range pool.Entries() {
+		for _, in := range entry.Transaction.Inputs {
+			spent[outpoint(in.ID, in.Out)] = true
+		}
+	}
+	return spent
+}
+
+// UnspentOutputs to find the outputs of pending transactions locked with one of the public key hashes
+// that no pending Transaction spends yet
+func (pool *TxPool) UnspentOutputs(publicKeyHashes [][]byte) []UnspentOutput {
+	entries := pool.Entries()
+	spent := make(map[string]bool)
+	for _, entry := range entries {
+		for _, in := range entry.Transaction.Inputs {
+			spent[outpoint(in.ID, in.Out)] = true
+		}
+	}
+	var unSpentOutputs []UnspentOutput
+	for _, entry := range entries {
+		tx := entry.Transaction
+		for outID, out := range tx.Outputs {
+			if spent[outpoint(tx.ID, outID)] {
+				continue
+			}
+			for _, publicKeyHash := range publicKeyHashes {
+				if out.IsLockedWithKey(publicKeyHash) {
+					unSpentOutputs = append(unSpentOutputs, UnspentOutput{tx.ID, outID, out})
+					break
+				}
+			}
+		}
+	}
+	return unSpentOutputs
+}
+
+// Update to drop from the pool, in the badger transaction, the transactions of the Block, those spending
+// an output the Block spends and their descendants
+func (pool *TxPool) Update(txn *badger.Txn, block *Block) error {
+	entries := pool.Entries()
+	mined := make(map[string]bool)
+	spent := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		mined[hex.EncodeToString(tx.ID)] = true
+		for _, in := range tx.Inputs {
+			spent[outpoint(in.ID, in.Out)] = true
+		}
+	}
+	stale := make(map[string]*PoolEntry)
+	for _, entry := range entries {
+		txID := hex.EncodeToString(entry.Transaction.ID)
+		if mined[txID] {
+			if err := txn.Delete(poolKey(entry.Transaction.ID)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, in := range entry.Transaction.Inputs {
+			if spent[outpoint(in.ID, in.Out)] {
+				stale[txID] = entry
+			}
+		}
+	}
+	for _, entry := range descendants(stale, entries) {
+		if err := txn.Delete(poolKey(entry.Transaction.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MineBlock to mine the pending transactions into a new Block of at most maxSize bytes of transactions,
+// its coinbase pays the subsidy of the network and the fees they leave to the address
+func (chain *BlockChain) MineBlock(address string, maxSize int) *Block {
+	block, err := chain.BlockTemplate(address, maxSize)
+	if err != nil {
+		log.Panicf("ERROR: BLOCK IS NOT VALID: %v !", err)
+	}
+	return chain.mineTemplate(block)
+}
+
+// BlockTemplate to lay out the Block MineBlock would mine without running its proof of work, so that a
+// caller can mine it without holding the BlockChain and connect it with AcceptBlock
+func (chain *BlockChain) BlockTemplate(address string, maxSize int) (*Block, error) {
+	transactions, fees := chain.TxPool().BlockTransactions(maxSize)
+	height := chain.BestHeight() + 1
+	coinbase := coinBase(address, fmt.Sprintf("BLOCK AT HEIGHT %d TO %s", height, address), params.Active().Subsidy+fees)
+	return chain.newTemplate(append([]*Transaction{coinbase}, transactions...))
+}
+
+// GenerateBlock to mine a block on demand whose coinbase pays the subsidy of the network and the fees of
+// the best paying pending transactions to the address, so that tests can build a chain of any height
+func (chain *BlockChain) GenerateBlock(address string) *Block {
+	return chain.MineBlock(address, MaxBlockSize)
+}
+
+// BumpFee to replace a pending Transaction of the wallet with one paying the fee, taken out of its change,
+// the replacement spends the same inputs and every input is signed again by the wallet owning it, a zero fee
+// outbids the original and its descendants by one
+func (chain *BlockChain) BumpFee(txID []byte, fee int, wallets *wallet.Wallets) (*Transaction, error) {
+	pool := chain.TxPool()
+	entries := pool.Entries()
+	entry, ok := byID(entries)[hex.EncodeToString(txID)]
+	if !ok {
+		return nil, errors.New("transaction is not in the pool")
+	}
+	original := entry.Transaction
+	if !original.IsReplaceable() {
+		return nil, errors.New("transaction is not replaceable")
+	}
+	previousTXs, err := pool.previousTransactions(original)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]bool)
+	for _, previousOutput := range previousOutputsOf(original, previousTXs) {
+		if _, _, ok := wallets.KeyFor(previousOutput.Address()); !ok {
+			return nil, errors.New("no wallet owns an input")
+		}
+		owners[previousOutput.Address()] = true
+	}
+	// the change goes to a change address, or back to the sender under the sender change policy
+	changeID := -1
+	for outID, out := range original.Outputs {
+		if owners[out.Address()] || wallets.IsChange(out.Address()) {
+			changeID = outID
+		}
+	}
+	if changeID < 0 {
+		return nil, errors.New("transaction has no change output to pay the fee from")
+	}
+	// the replacement also evicts the pending transactions spending the original, so it outbids them too
+	replacedFee := 0
+	for _, replaced := range descendants(map[string]*PoolEntry{hex.EncodeToString(txID): entry}, entries) {
+		replacedFee += replaced.Fee
+	}
+	if fee == 0 {
+		fee = replacedFee + 1
+	}
+	if fee <= replacedFee {
+		return nil, fmt.Errorf("fee must be more than the %d already paid", replacedFee)
+	}
+	change := original.Outputs[changeID].Value - (fee - entry.Fee)
+	if change < 0 {
+		return nil, fmt.Errorf("change of %d cannot pay a fee of %d", original.Outputs[changeID].Value, fee)
+	}
+	tx := original.TrimmedCopy()
+	if change == 0 {
+		tx.Outputs = append(tx.Outputs[:changeID], tx.Outputs[changeID+1:]...)
+	} else {
+		tx.Outputs[changeID].Value = change
+	}
+	tx.SetID()
+	if err := tx.signWithWallets(wallets, previousTXs); err != nil {
+		return nil, err
+	}
+	if err := pool.add(&tx, entries); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// previousTransactions to look up the transactions the inputs spend from, in the BlockChain or in the pool
+func (pool *TxPool) previousTransactions(tx *Transaction) (map[string]Transaction, error) {
+	previousTXs := make(map[string]Transaction)
+	for _, in := range tx.Inputs {
+		if parent, ok := pool.transaction(in.ID); ok {
+			previousTXs[hex.EncodeToString(in.ID)] = *parent
+			continue
+		}
+		previousTX, err := pool.blockchain.FindTransaction(in.ID)
+		if err != nil {
+			return nil, err
+		}
+		previousTXs[hex.EncodeToString(in.ID)] = previousTX
+	}
+	return previousTXs, nil
+}
@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/the-code-innovator/go-blockchain/params"
+)
+
+// raiseFee to take the amount off the change output of the raw transaction, the last one, raising its fee
+func raiseFee(amount int) func(raw *RawTransaction) {
+	return func(raw *RawTransaction) {
+		raw.Transaction.Outputs[len(raw.Transaction.Outputs)-1].Value -= amount
+	}
+}
+
+func TestReplaceByFee(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	chain.GenerateBlock(address)
+	pool := chain.TxPool()
+	original := rawPayment(t, chain, wallets, address, payee, 30, TxOptions{Fee: 10, Replaceable: true})
+	pending(t, chain, &original.Transaction)
+
+	sameFee := resign(t, wallets, original, func(raw *RawTransaction) {
+		raw.Transaction.Outputs[0] = *NewTxOutput(30, addKey(wallets))
+	})
+	if err := pool.Add(&sameFee.Transaction); err == nil {
+		t.Error("a replacement paying the same fee is accepted")
+	}
+	replacement := resign(t, wallets, original, raiseFee(5))
+	if err := pool.Add(&replacement.Transaction); err != nil {
+		t.Fatalf("a replacement paying a higher fee is refused: %v", err)
+	}
+	if inPool(chain, &original.Transaction) || !inPool(chain, &replacement.Transaction) {
+		t.Fatal("the replacement does not evict the transaction it replaces")
+	}
+
+	// a child of the replacement adds its fee to those a further replacement has to beat
+	child := spendOutput(t, wallets, &replacement.Transaction, 1, payee, 10, 40)
+	pending(t, chain, &child.Transaction)
+	underpaying := resign(t, wallets, replacement, raiseFee(15))
+	if err := pool.Add(&underpaying.Transaction); err == nil {
+		t.Error("a replacement paying less than the transactions it evicts together is accepted")
+	}
+	outbidding := resign(t, wallets, replacement, raiseFee(45))
+	if err := pool.Add(&outbidding.Transaction); err != nil {
+		t.Fatalf("a replacement paying more than the transactions it evicts is refused: %v", err)
+	}
+	if inPool(chain, &replacement.Transaction) || inPool(chain, &child.Transaction) {
+		t.Fatal("the replacement does not evict the descendants of the transaction it replaces")
+	}
+
+	// a replacement cannot pay itself from the transaction it evicts
+	selfSpending := resign(t, wallets, outbidding, func(raw *RawTransaction) {
+		raw.Transaction.Inputs = append(raw.Transaction.Inputs, TxInput{ID: outbidding.Transaction.ID, Out: 0, Sequence: MaxSequence})
+		raw.PreviousOutputs = append(raw.PreviousOutputs, outbidding.Transaction.Outputs[0])
+		raw.Transaction.Outputs[0].Value += 29
+	})
+	if err := pool.Add(&selfSpending.Transaction); err == nil {
+		t.Error("a replacement spending an output of the transaction it replaces is accepted")
+	}
+
+	final := rawPayment(t, chain, wallets, address, payee, 30, TxOptions{Fee: 10})
+	pending(t, chain, &final.Transaction)
+	if err := pool.Add(&resign(t, wallets, final, raiseFee(50)).Transaction); err == nil {
+		t.Error("a transaction that did not opt in is replaced")
+	}
+	if !inPool(chain, &final.Transaction) || !inPool(chain, &outbidding.Transaction) {
+		t.Error("a refused replacement evicts pending transactions")
+	}
+}
+
+func TestBlockTransactionsSelectsPackages(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	payee := addKey(wallets)
+	chain.GenerateBlock(address)
+	chain.GenerateBlock(address)
+	pool := chain.TxPool()
+	// a child paying a high fee pulls in its parent paying almost none, ahead of a transaction paying
+	// more than the parent alone
+	parent := testPayment(t, chain, wallets, address, payee, 30, TxOptions{Fee: 1})
+	pending(t, chain, parent)
+	child := &spendOutput(t, wallets, parent, 1, payee, 10, 50).Transaction
+	pending(t, chain, child)
+	// the pending parent keeps its coin from being picked again
+	other := testPayment(t, chain, wallets, address, payee, 20, TxOptions{Fee: 20})
+	pending(t, chain, other)
+	entries := pool.Entries()
+	if !bytes.Equal(entries[0].Transaction.ID, child.ID) || !bytes.Equal(entries[2].Transaction.ID, parent.ID) {
+		t.Fatal("entries are not ordered by their own fee rate")
+	}
+
+	transactions, fees := pool.BlockTransactions(MaxBlockSize)
+	want := []*Transaction{parent, child, other}
+	if len(transactions) != len(want) {
+		t.Fatalf("block takes %d transactions, want %d", len(transactions), len(want))
+	}
+	for i, tx := range want {
+		if !bytes.Equal(transactions[i].ID, tx.ID) {
+			t.Errorf("transaction %d of the block is %x, want %x", i, transactions[i].ID, tx.ID)
+		}
+	}
+	if fees != 71 {
+		t.Errorf("block collects %d in fees, want 71", fees)
+	}
+
+	// the package does not fit, so the other transaction goes in first
+	transactions, _ = pool.BlockTransactions(parent.Size() + child.Size() - 1)
+	if len(transactions) == 0 || !bytes.Equal(transactions[0].ID, other.ID) {
+		t.Fatal("block does not start with the transaction that fits")
+	}
+	for _, tx := range transactions {
+		if bytes.Equal(tx.ID, child.ID) {
+			t.Error("block takes the package over its size")
+		}
+	}
+
+	block := chain.GenerateBlock(address)
+	if len(block.Transactions) != 4 || block.Transactions[0].Outputs[0].Value != params.Active().Subsidy+71 {
+		t.Errorf("block does not mine the three transactions, paying their fees to the miner")
+	}
+	if len(pool.Entries()) != 0 {
+		t.Error("mined transactions are still pending")
+	}
+}
+
+func TestBlockTransactionsLeavesOutLockedPackage(t *testing.T) {
+	chain, wallets, address := newTestChain(t)
+	holder := addKey(wallets)
+	pool := chain.TxPool()
+	pending(t, chain, testPayment(t, chain, wallets, address, holder, 50, TxOptions{}))
+	chain.GenerateBlock(address)
+	lock, err := RelativeLockBlocks(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the locked parent cannot be mined yet, nor can its child however much it pays
+	parent := testPayment(t, chain, wallets, holder, address, 20, TxOptions{RelativeLock: lock})
+	pending(t, chain, parent)
+	child := &spendOutput(t, wallets, parent, 1, address, 10, 15).Transaction
+	pending(t, chain, child)
+	other := testPayment(t, chain, wallets, address, holder, 20, TxOptions{Fee: 1})
+	pending(t, chain, other)
+	transactions, fees := pool.BlockTransactions(MaxBlockSize)
+	if len(transactions) != 1 || !bytes.Equal(transactions[0].ID, other.ID) || fees != 1 {
+		t.Fatalf("block takes %d transactions collecting %d in fees, want only the unlocked one", len(transactions), fees)
+	}
+	chain.GenerateBlock(address)
+	if !inPool(chain, parent) || !inPool(chain, child) || inPool(chain, other) {
+		t.Error("the locked package does not wait in the pool")
+	}
+}
@@ -1,21 +1,266 @@
 package blockchain
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
 	"log"
+	"sort"
 
 	"github.com/dgraph-io/badger"
 )
 
 var (
-// utxoPrefix = []byte("utxo-")
-// utxoPrefixLength = len(utxoPrefix)
+	utxoPrefix       = []byte("utxo-")
+	utxoPrefixLength = len(utxoPrefix)
+	// utxoTipKey holds the hash of the last Block applied to the index, like "lh" for the BlockChain
+	utxoTipKey = []byte("ut")
 )
 
-// UTXO struct for blockchain
+// UTXO struct for blockchain, every unspent output is indexed under utxoPrefix by the ID of its
+// Transaction and its index, data carrier outputs can never be spent and are never indexed, the
+// heights of the blocks and of their transactions are indexed along with them
 type UTXO struct {
 	blockchain *BlockChain
 }
 
+// utxoEntry structure for an indexed output and the height of the Block that created it
+type utxoEntry struct {
+	Output TxOutput
+	Height int
+}
+
+// utxoKey to build the index key of an output
+func utxoKey(txID []byte, out int) []byte {
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, uint32(out))
+	return append(append(append([]byte{}, utxoPrefix...), txID...), index...)
+}
+
+// serialize to serialize the utxoEntry for badger.DB
+func (entry *utxoEntry) serialize() []byte {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(entry)
+	PanicHandle(err)
+	return buffer.Bytes()
+}
+
+// deserializeEntry to deserialize the utxoEntry from badger.DB
+func deserializeEntry(data []byte) utxoEntry {
+	var entry utxoEntry
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry)
+	PanicHandle(err)
+	return entry
+}
+
+// Reindex to rebuild the indexes from the blocks of the BlockChain
+func (utx *UTXO) Reindex() {
+	utx.DeleteByPrefix(utxoPrefix)
+	utx.DeleteByPrefix(heightPrefix)
+	utx.DeleteByPrefix(txHeightPrefix)
+	utx.DeleteByPrefix(undoPrefix)
+	for _, block := range utx.blockchain.Blocks() {
+		err := utx.blockchain.DataBase.Update(func(txn *badger.Txn) error {
+			return utx.Update(txn, block)
+		})
+		PanicHandle(err)
+	}
+}
+
+// Update to apply a Block to the indexes in the badger transaction, removing the outputs its
+// inputs spend, adding the outputs it creates and indexing its height
+func (utx *UTXO) Update(txn *badger.Txn, block *Block) error {
+	if err := indexBlock(txn, block); err != nil {
+		return err
+	}
+	// the outputs spent are kept as undo data, so that the Block can be disconnected again
+	var undo [][]utxoEntry
+	for _, tx := range block.Transactions {
+		var spent []utxoEntry
+		if !tx.IsCoinBase() {
+			for _, in := range tx.Inputs {
+				item, err := txn.Get(utxoKey(in.ID, in.Out))
+				if err != nil {
+					return err
+				}
+				data, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				spent = append(spent, deserializeEntry(data))
+				if err := txn.Delete(utxoKey(in.ID, in.Out)); err != nil {
+					return err
+				}
+			}
+		}
+		undo = append(undo, spent)
+		for outID, out := range tx.Outputs {
+			if out.IsDataCarrier() {
+				continue
+			}
+			entry := utxoEntry{out, block.Height}
+			if err := txn.Set(utxoKey(tx.ID, outID), entry.serialize()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := txn.Set(undoKey(block.Hash), serializeUndo(undo)); err != nil {
+		return err
+	}
+	return txn.Set(utxoTipKey, block.Hash)
+}
+
+// IsCurrent to check whether the indexes were last updated with the last Block of the BlockChain,
+// indexes from before the heights and the undo data were kept are not
+func (utx *UTXO) IsCurrent() bool {
+	current := false
+	err := utx.blockchain.DataBase.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(utxoTipKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		tip, err := item.ValueCopy(nil)
+		if err != nil || !bytes.Equal(tip, utx.blockchain.LastHash) {
+			return err
+		}
+		_, err = txn.Get(undoKey(tip))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		current = err == nil
+		return err
+	})
+	PanicHandle(err)
+	if !current {
+		return false
+	}
+	entry, ok := utx.blockchain.heightEntry(utx.blockchain.BestHeight())
+	return ok && bytes.Equal(entry.Hash, utx.blockchain.LastHash)
+}
+
+// Get to look up an unspent output, false when it does not exist or is spent
+func (utx *UTXO) Get(txID []byte, out int) (TxOutput, bool) {
+	var entry utxoEntry
+	found := false
+	err := utx.blockchain.DataBase.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(utxoKey(txID, out))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		entry = deserializeEntry(data)
+		found = true
+		return nil
+	})
+	PanicHandle(err)
+	return entry.Output, found
+}
+
+// FindUnspentOutputs to find every indexed output locked with one of the public key hashes,
+// newest first like a walk back along the BlockChain
+func (utx *UTXO) FindUnspentOutputs(publicKeyHashes [][]byte) []UnspentOutput {
+	var entries []utxoEntry
+	var unSpentOutputs []UnspentOutput
+	err := utx.blockchain.DataBase.View(func(txn *badger.Txn) error {
+		iterator := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iterator.Close()
+		for iterator.Seek(utxoPrefix); iterator.ValidForPrefix(utxoPrefix); iterator.Next() {
+			item := iterator.Item()
+			key := item.KeyCopy(nil)[utxoPrefixLength:]
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			entry := deserializeEntry(data)
+			for _, publicKeyHash := range publicKeyHashes {
+				if entry.Output.IsLockedWithKey(publicKeyHash) {
+					txID, out := key[:len(key)-4], int(binary.BigEndian.Uint32(key[len(key)-4:]))
+					entries = append(entries, entry)
+					unSpentOutputs = append(unSpentOutputs, UnspentOutput{txID, out, entry.Output})
+					break
+				}
+			}
+		}
+		return nil
+	})
+	PanicHandle(err)
+	sort.Stable(byHeight{unSpentOutputs, entries})
+	return unSpentOutputs
+}
+
+// byHeight structure to sort unspent outputs along with their entries, newest first
+type byHeight struct {
+	outputs []UnspentOutput
+	entries []utxoEntry
+}
+
+func (sorted byHeight) Len() int { return len(sorted.outputs) }
+
+func (sorted byHeight) Less(i, j int) bool {
+	return sorted.entries[i].Height > sorted.entries[j].Height
+}
+
+func (sorted byHeight) Swap(i, j int) {
+	sorted.outputs[i], sorted.outputs[j] = sorted.outputs[j], sorted.outputs[i]
+	sorted.entries[i], sorted.entries[j] = sorted.entries[j], sorted.entries[i]
+}
+
+// blockView structure for the outputs a Block being assembled spends and creates, so that a Transaction
+// can spend an output of one before it in the same Block
+type blockView struct {
+	spent   map[string]bool
+	created map[string]TxOutput
+}
+
+// newBlockView to start the view of an empty Block
+func newBlockView() *blockView {
+	return &blockView{make(map[string]bool), make(map[string]TxOutput)}
+}
+
+// clone to copy the view, so that a Transaction that turns out invalid can be rolled back
+func (view *blockView) clone() *blockView {
+	copied := newBlockView()
+	for key := range view.spent {
+		copied.spent[key] = true
+	}
+	for key, output := range view.created {
+		copied.created[key] = output
+	}
+	return copied
+}
+
+// spend to find the output an input spends, among the indexed outputs or those created earlier in the Block,
+// false when it is missing or already spent by an earlier input
+func (view *blockView) spend(utx *UTXO, in TxInput) (TxOutput, bool) {
+	key := outpoint(in.ID, in.Out)
+	if view.spent[key] {
+		return TxOutput{}, false
+	}
+	view.spent[key] = true
+	if output, ok := view.created[key]; ok {
+		return output, true
+	}
+	return utx.Get(in.ID, in.Out)
+}
+
+// add to make the outputs of a Transaction in the Block spendable by the transactions after it
+func (view *blockView) add(tx *Transaction) {
+	for outID, out := range tx.Outputs {
+		if !out.IsDataCarrier() {
+			view.created[outpoint(tx.ID, outID)] = out
+		}
+	}
+}
+
 // DeleteByPrefix to delete persistence by given Prefix
 func (utx *UTXO) DeleteByPrefix(prefix []byte) {
 	deleteKeys := func(keysToDelete [][]byte) error {
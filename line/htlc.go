@@ -0,0 +1,202 @@
+package line
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// RunHTLC to run the subcommands of htlc, which lock funds in hash time locked contracts
+func (inter *Interface) RunHTLC(args []string) {
+	createCommand := flag.NewFlagSet("htlc create", flag.ExitOnError)
+	inspectCommand := flag.NewFlagSet("htlc inspect", flag.ExitOnError)
+	redeemCommand := flag.NewFlagSet("htlc redeem", flag.ExitOnError)
+	refundCommand := flag.NewFlagSet("htlc refund", flag.ExitOnError)
+	createFrom := createCommand.String("from", "", "Address funding the contract and refunded after the lock time")
+	createTo := createCommand.String("to", "", "Address that redeems the contract with the secret")
+	createAmount := createCommand.Int("amount", 0, "Amount locked in the contract")
+	createLockTime := createCommand.String("locktime", "", "Block height, Unix time, date or duration (e.g. 48h) after which the sender can refund")
+	createHash := createCommand.String("hash", "", "Hex SHA256 hash of the secret, a new secret is generated if empty")
+	inspectContract := inspectCommand.String("contract", "", "Hex contract script or contract address in the wallet")
+	redeemContract := redeemCommand.String("contract", "", "Hex contract script or contract address in the wallet")
+	redeemPreimage := redeemCommand.String("preimage", "", "Hex secret whose SHA256 hash the contract commits to, the wallet's secret if empty")
+	refundContract := refundCommand.String("contract", "", "Hex contract script or contract address in the wallet")
+	if len(args) < 1 {
+		inter.PrintUsage()
+		runtime.Goexit()
+	}
+	switch args[0] {
+	case "create":
+		err := createCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+		if *createFrom == "" || *createTo == "" || *createAmount <= 0 || *createLockTime == "" {
+			createCommand.Usage()
+			runtime.Goexit()
+		}
+		lockTime, err := parseLockTime(*createLockTime)
+		if err != nil {
+			log.Panicf("ERROR: LOCK TIME IS NOT VALID: %v !", err)
+		}
+		inter.CreateHTLC(*createFrom, *createTo, *createAmount, lockTime, *createHash)
+	case "inspect":
+		err := inspectCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+		if *inspectContract == "" {
+			inspectCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.InspectHTLC(*inspectContract)
+	case "redeem":
+		err := redeemCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+		if *redeemContract == "" {
+			redeemCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.RedeemHTLC(*redeemContract, *redeemPreimage)
+	case "refund":
+		err := refundCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+		if *refundContract == "" {
+			refundCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.RefundHTLC(*refundContract)
+	default:
+		inter.PrintUsage()
+		runtime.Goexit()
+	}
+}
+
+// CreateHTLC to lock the amount from FROM in a contract that TO redeems with the secret, or FROM refunds
+// after the lock time, the secret is generated and kept in the wallet unless its hash is given
+func (inter *Interface) CreateHTLC(from, to string, amount int, lockTime int64, encodedHash string) {
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	from = wallet.Base58Address(from)
+	if _, ok := wallets.Wallets[from]; !ok {
+		log.Panic("ERROR: ADDRESS IS NOT IN THE WALLET !")
+	}
+	var secret, secretHash []byte
+	if encodedHash == "" {
+		secret, secretHash = wallet.NewSecret()
+		wallets.AddSecret(secret)
+	} else if secretHash, err = hex.DecodeString(encodedHash); err != nil {
+		log.Panic("ERROR: SECRET HASH IS NOT VALID !")
+	}
+	contract, err := wallet.NewHTLC(secretHash, to, from, lockTime)
+	if err != nil {
+		log.Panicf("ERROR: CONTRACT IS NOT VALID: %v !", err)
+	}
+	address := wallets.AddHTLC(contract)
+	wallets.SaveFile()
+	chain := blockchain.ContinueBlockChain(from)
+	defer chain.DataBase.Close()
+	tx := blockchain.NewTransaction(from, address, amount, blockchain.TxOptions{}, chain)
+	chain.AddBlock([]*blockchain.Transaction{tx})
+	fmt.Printf("LOCKED %d IN CONTRACT %s, TRANSACTION %x.\n", amount, address, tx.ID)
+	printHTLC(contract)
+	if secret != nil {
+		fmt.Printf(" • Secret         : %x\n", secret)
+	}
+}
+
+// InspectHTLC to print the terms of a contract, its unspent funds and any secret revealed by redeeming it,
+// the contract is added to the wallet so that later commands can refer to it by address
+func (inter *Interface) InspectHTLC(encoded string) {
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	contract := readHTLC(wallets, encoded)
+	wallets.AddHTLC(contract)
+	wallets.SaveFile()
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	printHTLC(contract)
+	funded := 0
+	for _, unSpent := range chain.FindUnspentOutputs([][]byte{contract.ScriptHash()}) {
+		funded += unSpent.Output.Value
+	}
+	fmt.Printf(" • Unspent        : %d\n", funded)
+	if preimage, ok := chain.FindPreimage(contract); ok {
+		fmt.Printf(" • Secret         : %x\n", preimage)
+	}
+}
+
+// RedeemHTLC to claim every unspent output of a contract for its recipient with the preimage of its secret hash
+func (inter *Interface) RedeemHTLC(encoded, encodedPreimage string) {
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	contract := readHTLC(wallets, encoded)
+	var preimage []byte
+	if encodedPreimage == "" {
+		secret, ok := wallets.GetSecret(contract.SecretHash)
+		if !ok {
+			log.Panic("ERROR: SECRET IS NOT IN THE WALLET, PASS -preimage !")
+		}
+		preimage = secret
+	} else if preimage, err = hex.DecodeString(encodedPreimage); err != nil {
+		log.Panic("ERROR: PREIMAGE IS NOT VALID !")
+	}
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	tx, err := blockchain.NewRedeemTransaction(contract, preimage, wallets, chain)
+	if err == blockchain.ErrContractNotFunded {
+		log.Panic("ERROR: CONTRACT IS NOT FUNDED !")
+	}
+	if err != nil {
+		log.Panicf("ERROR: CONTRACT CANNOT BE REDEEMED: %v !", err)
+	}
+	chain.AddBlock([]*blockchain.Transaction{tx})
+	fmt.Printf("REDEEMED %d TO %s, TRANSACTION %x.\n", tx.Outputs[0].Value, contract.RecipientAddress(), tx.ID)
+}
+
+// RefundHTLC to take back every unspent output of a contract for its sender, printing the refund
+// for sendrawtx when the lock time of the contract has not passed yet
+func (inter *Interface) RefundHTLC(encoded string) {
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	contract := readHTLC(wallets, encoded)
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	tx, err := blockchain.NewRefundTransaction(contract, wallets, chain)
+	if err == blockchain.ErrContractNotFunded {
+		log.Panic("ERROR: CONTRACT IS NOT FUNDED !")
+	}
+	if err != nil {
+		log.Panicf("ERROR: CONTRACT CANNOT BE REFUNDED: %v !", err)
+	}
+	if mineOrPrint(chain, tx) {
+		fmt.Printf("REFUNDED %d TO %s, TRANSACTION %x.\n", tx.Outputs[0].Value, contract.SenderAddress(), tx.ID)
+	}
+}
+
+// readHTLC to find a contract by its address in the wallet or decode it from its hex script
+func readHTLC(wallets *wallet.Wallets, encoded string) *wallet.HTLC {
+	if contract, ok := wallets.Contracts[wallet.Base58Address(encoded)]; ok {
+		return contract
+	}
+	redeem, err := hex.DecodeString(encoded)
+	if err != nil {
+		log.Panic("ERROR: CONTRACT IS NOT IN THE WALLET !")
+	}
+	contract, err := wallet.ParseHTLC(redeem)
+	if err != nil {
+		log.Panicf("ERROR: CONTRACT IS NOT VALID: %v !", err)
+	}
+	return contract
+}
+
+// printHTLC to print the terms of a contract
+func printHTLC(contract *wallet.HTLC) {
+	fmt.Printf(" • Address        : %s\n", contract.Address())
+	fmt.Printf(" • Contract       : %x\n", contract.Redeem())
+	fmt.Printf(" • Secret Hash    : %x\n", contract.SecretHash)
+	fmt.Printf(" • Recipient      : %s\n", contract.RecipientAddress())
+	fmt.Printf(" • Sender         : %s\n", contract.SenderAddress())
+	fmt.Printf(" • Refund After   : %s\n", blockchain.LockTimeString(contract.LockTime))
+}
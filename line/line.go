@@ -1,14 +1,26 @@
 package line
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/the-code-innovator/go-blockchain/blockchain"
+	"github.com/the-code-innovator/go-blockchain/params"
 	"github.com/the-code-innovator/go-blockchain/wallet"
 )
 
@@ -24,7 +36,28 @@ type Interface struct{}
 
 // Run to run the command line interface
 func (inter *Interface) Run() {
-	inter.ValidateArguments()
+	// global options come before the command
+	globalCommand := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	dataDir := globalCommand.String("datadir", "./tmp", "Directory holding the blockchain and the wallets file")
+	network := globalCommand.String("network", params.Mainnet.Name, "Network: "+strings.Join(params.NetworkNames(), ", "))
+	mockTime := globalCommand.String("mocktime", "", "Unix time or date to stamp new blocks with instead of the clock, regtest only")
+	err := globalCommand.Parse(os.Args[1:])
+	blockchain.PanicHandle(err)
+	args := globalCommand.Args()
+	inter.ValidateArguments(args)
+	if err := params.SetNetwork(*network); err != nil {
+		log.Panicf("ERROR: %v !", err)
+	}
+	if *mockTime != "" {
+		unix, err := parseTime(*mockTime)
+		if err != nil {
+			log.Panicf("ERROR: MOCK TIME IS NOT VALID: %v !", err)
+		}
+		if err := blockchain.SetMockTime(unix); err != nil {
+			log.Panicf("ERROR: %v !", err)
+		}
+	}
+	inter.UseDataDir(*dataDir)
 	// argument parsing using flags
 	createWalletCommand := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCommand := flag.NewFlagSet("listaddresses", flag.ExitOnError)
@@ -32,43 +65,218 @@ func (inter *Interface) Run() {
 	sendCommand := flag.NewFlagSet("send", flag.ExitOnError)
 	getBalanceCommand := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	printChainCommand := flag.NewFlagSet("printchain", flag.ExitOnError)
+	sendManyCommand := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	changePolicyCommand := flag.NewFlagSet("changepolicy", flag.ExitOnError)
+	historyCommand := flag.NewFlagSet("history", flag.ExitOnError)
+	signMessageCommand := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	getPubKeyCommand := flag.NewFlagSet("getpubkey", flag.ExitOnError)
+	createMultisigCommand := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	createMuSigCommand := flag.NewFlagSet("createmusig", flag.ExitOnError)
+	createRawTxCommand := flag.NewFlagSet("createrawtx", flag.ExitOnError)
+	signRawTxCommand := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	sendRawTxCommand := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	verifyMessageCommand := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	anchorCommand := flag.NewFlagSet("anchor", flag.ExitOnError)
+	verifyAnchorCommand := flag.NewFlagSet("verifyanchor", flag.ExitOnError)
+	mempoolCommand := flag.NewFlagSet("mempool", flag.ExitOnError)
+	bumpFeeCommand := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	mineCommand := flag.NewFlagSet("mine", flag.ExitOnError)
+	generateCommand := flag.NewFlagSet("generate", flag.ExitOnError)
+	migrateWalletCommand := flag.NewFlagSet("migratewallet", flag.ExitOnError)
+	backupWalletCommand := flag.NewFlagSet("backupwallet", flag.ExitOnError)
+	restoreWalletCommand := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	vanityCommand := flag.NewFlagSet("vanity", flag.ExitOnError)
+	startNodeCommand := flag.NewFlagSet("startnode", flag.ExitOnError)
 	// parameters for the commands
+	createWalletScheme := createWalletCommand.String("scheme", "p256", "Signature Scheme: "+strings.Join(wallet.SchemeNames, ", "))
+	listAddressesFormat := listAddressesCommand.String("format", wallet.FormatBase58, "Address Format: "+wallet.FormatBase58+", "+wallet.FormatBech32)
+	createWalletFormat := createWalletCommand.String("format", wallet.FormatBase58, "Address Format: "+wallet.FormatBase58+", "+wallet.FormatBech32)
 	createBlockChainAddress := createBlockChainCommand.String("address", "", "The Address to send Reward to.")
 	sendFrom := sendCommand.String("from", "", "Source Wallet Address")
+	sendFromWallet := sendCommand.Bool("fromwallet", false, "Draw funds from every address in the wallet")
 	sendTo := sendCommand.String("to", "", "Destination Wallet Address")
 	sendAmount := sendCommand.Int("amount", 0, "Amount To Send")
-	getBalanceAddress := getBalanceCommand.String("address", "", "The Address to find Balance.")
+	sendCoinSelect := sendCommand.String("coinselect", "bnb", "Coin Selection Strategy: "+strings.Join(blockchain.CoinSelectorNames, ", "))
+	sendDryRun := sendCommand.Bool("dryrun", false, "Report the chosen inputs and change without sending")
+	sendLockTime := sendCommand.String("locktime", "", "Block height, Unix time or date the transaction cannot be mined before")
+	sendRelativeLock := sendCommand.String("relativelock", "", "Blocks or duration (e.g. 48h) the spent outputs must age before the transaction can be mined")
+	sendFee := sendCommand.Int("fee", 0, "Fee left to the miner")
+	sendReplaceable := sendCommand.Bool("replaceable", false, "Let the pending transaction be replaced by one paying a higher fee")
+	sendPool := sendCommand.Bool("pool", false, "Leave the transaction waiting in the pool instead of mining it at once")
+	getBalanceAddress := getBalanceCommand.String("address", "", "The Address to find Balance, all wallet addresses if empty.")
+	var sendManyPayments paymentList
+	sendManyFrom := sendManyCommand.String("from", "", "Source Wallet Address")
+	sendManyFromWallet := sendManyCommand.Bool("fromwallet", false, "Draw funds from every address in the wallet")
+	sendManyCommand.Var(&sendManyPayments, "to", "Payment as ADDRESS:AMOUNT, repeatable or comma separated")
+	sendManyFile := sendManyCommand.String("file", "", "CSV (address,amount) or JSON file of payments")
+	sendManyCoinSelect := sendManyCommand.String("coinselect", "bnb", "Coin Selection Strategy: "+strings.Join(blockchain.CoinSelectorNames, ", "))
+	sendManyDryRun := sendManyCommand.Bool("dryrun", false, "Report the chosen inputs and change without sending")
+	sendManyLockTime := sendManyCommand.String("locktime", "", "Block height, Unix time or date the transaction cannot be mined before")
+	sendManyRelativeLock := sendManyCommand.String("relativelock", "", "Blocks or duration (e.g. 48h) the spent outputs must age before the transaction can be mined")
+	sendManyFee := sendManyCommand.Int("fee", 0, "Fee left to the miner")
+	sendManyReplaceable := sendManyCommand.Bool("replaceable", false, "Let the pending transaction be replaced by one paying a higher fee")
+	sendManyPool := sendManyCommand.Bool("pool", false, "Leave the transaction waiting in the pool instead of mining it at once")
+	changePolicyName := changePolicyCommand.String("policy", "", "Where change is sent: fresh or sender.")
+	historyAddress := historyCommand.String("address", "", "The Address to list Transactions for, all wallet addresses if empty.")
+	historyJSON := historyCommand.Bool("json", false, "Print the history as JSON.")
+	var createRawTxPayments paymentList
+	createRawTxFrom := createRawTxCommand.String("from", "", "Source Wallet Address")
+	createRawTxCommand.Var(&createRawTxPayments, "to", "Payment as ADDRESS:AMOUNT, repeatable or comma separated")
+	createRawTxChange := createRawTxCommand.String("change", "", "Address receiving the change, the source address if empty")
+	createRawTxCoinSelect := createRawTxCommand.String("coinselect", "bnb", "Coin Selection Strategy: "+strings.Join(blockchain.CoinSelectorNames, ", "))
+	createRawTxLockTime := createRawTxCommand.String("locktime", "", "Block height, Unix time or date the transaction cannot be mined before")
+	createRawTxRelativeLock := createRawTxCommand.String("relativelock", "", "Blocks or duration (e.g. 48h) the spent outputs must age before the transaction can be mined")
+	createRawTxFee := createRawTxCommand.Int("fee", 0, "Fee left to the miner")
+	createRawTxReplaceable := createRawTxCommand.Bool("replaceable", false, "Let the pending transaction be replaced by one paying a higher fee")
+	signRawTx := signRawTxCommand.String("tx", "", "Hex encoded raw transaction")
+	signRawTxFile := signRawTxCommand.String("file", "", "File holding the hex encoded raw transaction")
+	sendRawTx := sendRawTxCommand.String("tx", "", "Hex encoded raw transaction")
+	sendRawTxFile := sendRawTxCommand.String("file", "", "File holding the hex encoded raw transaction")
+	getPubKeyAddress := getPubKeyCommand.String("address", "", "The Address whose public key to print.")
+	createMultisigRequired := createMultisigCommand.Int("required", 0, "Signatures required to spend.")
+	createMultisigKeys := createMultisigCommand.String("pubkeys", "", "Comma separated hex public keys of the co-signers.")
+	createMuSigKeys := createMuSigCommand.String("pubkeys", "", "Comma separated hex Schnorr public keys of the co-signers.")
+	signMessageAddress := signMessageCommand.String("address", "", "The Address whose key signs the message.")
+	signMessageMessage := signMessageCommand.String("message", "", "The Message to sign.")
+	verifyMessageAddress := verifyMessageCommand.String("address", "", "The Address that signed the message.")
+	verifyMessageSignature := verifyMessageCommand.String("signature", "", "The Base64 Signature to verify.")
+	verifyMessageMessage := verifyMessageCommand.String("message", "", "The Message that was signed.")
+	anchorFile := anchorCommand.String("file", "", "File whose SHA256 hash is anchored")
+	anchorFrom := anchorCommand.String("from", "", "Address paying for the anchor, any wallet address if empty")
+	verifyAnchorFile := verifyAnchorCommand.String("file", "", "File whose SHA256 hash is looked up")
+	verifyAnchorHash := verifyAnchorCommand.String("hash", "", "Hex SHA256 hash to look up in place of a file")
+	bumpFeeTxID := bumpFeeCommand.String("txid", "", "Hex ID of the pending transaction to replace")
+	bumpFeeFee := bumpFeeCommand.Int("fee", 0, "New fee, one more than the fees it replaces if zero")
+	mineAddress := mineCommand.String("address", "", "The Address the fees of the block are paid to")
+	mineMaxSize := mineCommand.Int("maxsize", blockchain.MaxBlockSize, "Most bytes of transactions in the block")
+	generateBlocks := generateCommand.Int("blocks", 1, "Number of blocks to generate.")
+	generateAddress := generateCommand.String("address", "", "The Address the subsidy and fees of the blocks are paid to.")
+	generateInterval := generateCommand.Duration("interval", 0, "Time between the stamps of the blocks in whole seconds (e.g. 10m), 0 stamps them with the clock.")
+	backupWalletAddress := backupWalletCommand.String("address", "", "The Address whose private key to split.")
+	backupWalletShares := backupWalletCommand.Int("shares", 0, "Number of shares to split the key into.")
+	backupWalletThreshold := backupWalletCommand.Int("threshold", 0, "Number of shares that restore the key.")
+	backupWalletFormat := backupWalletCommand.String("format", "mnemonic", "Share Format: mnemonic, base58")
+	restoreWalletShares := restoreWalletCommand.String("shares", "", "Comma separated shares of one backup.")
+	restoreWalletFile := restoreWalletCommand.String("file", "", "File holding one share per line.")
+	restoreWalletChange := restoreWalletCommand.Int("change", 20, "Number of change addresses derived from the key to restore.")
+	vanityPrefix := vanityCommand.String("prefix", "", "The Base58 prefix the address must start with.")
+	vanityScheme := vanityCommand.String("scheme", "p256", "Signature Scheme: "+strings.Join(wallet.SchemeNames, ", "))
+	vanityWorkers := vanityCommand.Int("workers", runtime.NumCPU(), "Number of keys generated in parallel.")
+	vanityTimeout := vanityCommand.Duration("timeout", 0, "Give up after this long (e.g. 10m), 0 searches until interrupted.")
+	startNodePort := startNodeCommand.Int("port", params.Active().Port, "The Port to listen for peers on.")
+	startNodeConnect := startNodeCommand.String("connect", "", "Comma separated HOST:PORT addresses of peers to connect to.")
+	startNodeMiner := startNodeCommand.String("miner", "", "The Address the fees of mined blocks are paid to, no mining if empty.")
 	// switching based on the command parsed
-	switch os.Args[1] {
+	switch args[0] {
 	case "help":
 		inter.Help()
 	case "createwallet":
-		err := createWalletCommand.Parse(os.Args[2:])
+		err := createWalletCommand.Parse(args[1:])
 		blockchain.PanicHandle(err)
 	case "listaddresses":
-		err := listAddressesCommand.Parse(os.Args[2:])
+		err := listAddressesCommand.Parse(args[1:])
 		blockchain.PanicHandle(err)
 	case "createblockchain":
-		err := createBlockChainCommand.Parse(os.Args[2:])
+		err := createBlockChainCommand.Parse(args[1:])
 		blockchain.PanicHandle(err)
 	case "send":
-		err := sendCommand.Parse(os.Args[2:])
+		err := sendCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "sendmany":
+		err := sendManyCommand.Parse(args[1:])
 		blockchain.PanicHandle(err)
 	case "getbalance":
-		err := getBalanceCommand.Parse(os.Args[2:])
+		err := getBalanceCommand.Parse(args[1:])
 		blockchain.PanicHandle(err)
 	case "printchain":
-		err := printChainCommand.Parse(os.Args[2:])
+		err := printChainCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "changepolicy":
+		err := changePolicyCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "history":
+		err := historyCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "createrawtx":
+		err := createRawTxCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "signrawtx":
+		err := signRawTxCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "sendrawtx":
+		err := sendRawTxCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "getpubkey":
+		err := getPubKeyCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "createmultisig":
+		err := createMultisigCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "createmusig":
+		err := createMuSigCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "signmessage":
+		err := signMessageCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "verifymessage":
+		err := verifyMessageCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "anchor":
+		err := anchorCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "verifyanchor":
+		err := verifyAnchorCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "htlc":
+		inter.RunHTLC(args[1:])
+	case "mempool":
+		err := mempoolCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "bumpfee":
+		err := bumpFeeCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "mine":
+		err := mineCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "generate":
+		err := generateCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "migratewallet":
+		err := migrateWalletCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "backupwallet":
+		err := backupWalletCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "restorewallet":
+		err := restoreWalletCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "vanity":
+		err := vanityCommand.Parse(args[1:])
+		blockchain.PanicHandle(err)
+	case "startnode":
+		err := startNodeCommand.Parse(args[1:])
 		blockchain.PanicHandle(err)
 	default:
 		inter.PrintVersionInfo()
 		runtime.Goexit()
 	}
 	if createWalletCommand.Parsed() {
-		inter.CreateWallet()
+		scheme, err := wallet.ParseScheme(*createWalletScheme)
+		if err != nil {
+			log.Panicf("ERROR: %v !", err)
+		}
+		if *createWalletFormat != wallet.FormatBase58 && *createWalletFormat != wallet.FormatBech32 {
+			createWalletCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.CreateWallet(scheme, *createWalletFormat)
 	}
 	if listAddressesCommand.Parsed() {
-		inter.ListAddresses()
+		if *listAddressesFormat != wallet.FormatBase58 && *listAddressesFormat != wallet.FormatBech32 {
+			listAddressesCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.ListAddresses(*listAddressesFormat)
 	}
 	if createBlockChainCommand.Parsed() {
 		if *createBlockChainAddress == "" {
@@ -78,22 +286,200 @@ func (inter *Interface) Run() {
 		inter.CreateBlockChain(*createBlockChainAddress)
 	}
 	if sendCommand.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if (*sendFrom == "") == !*sendFromWallet || *sendTo == "" || *sendAmount <= 0 {
 			sendCommand.Usage()
 			runtime.Goexit()
 		}
-		inter.Send(*sendFrom, *sendTo, *sendAmount)
+		options := txOptions(*sendCoinSelect, *sendLockTime, *sendRelativeLock, *sendFee, *sendReplaceable)
+		if *sendFromWallet {
+			inter.SendFromWallet(*sendTo, *sendAmount, options, *sendDryRun, *sendPool)
+		} else {
+			inter.Send(*sendFrom, *sendTo, *sendAmount, options, *sendDryRun, *sendPool)
+		}
+	}
+	if sendManyCommand.Parsed() {
+		payments := []blockchain.Payment(sendManyPayments)
+		if *sendManyFile != "" {
+			filePayments, err := readPaymentsFile(*sendManyFile)
+			if err != nil {
+				log.Panicf("ERROR: PAYMENTS FILE IS NOT VALID: %v !", err)
+			}
+			payments = append(payments, filePayments...)
+		}
+		if (*sendManyFrom == "") == !*sendManyFromWallet || len(payments) == 0 {
+			sendManyCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.SendMany(*sendManyFrom, payments, txOptions(*sendManyCoinSelect, *sendManyLockTime, *sendManyRelativeLock, *sendManyFee, *sendManyReplaceable), *sendManyDryRun, *sendManyPool)
 	}
 	if getBalanceCommand.Parsed() {
 		if *getBalanceAddress == "" {
-			getBalanceCommand.Usage()
-			runtime.Goexit()
+			inter.GetWalletBalance()
+		} else {
+			inter.GetBalance(*getBalanceAddress)
 		}
-		inter.GetBalance(*getBalanceAddress)
 	}
 	if printChainCommand.Parsed() {
 		inter.PrintChain()
 	}
+	if changePolicyCommand.Parsed() {
+		inter.ChangePolicy(*changePolicyName)
+	}
+	if historyCommand.Parsed() {
+		inter.History(*historyAddress, *historyJSON)
+	}
+	if createRawTxCommand.Parsed() {
+		if *createRawTxFrom == "" || len(createRawTxPayments) == 0 {
+			createRawTxCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.CreateRawTx(*createRawTxFrom, createRawTxPayments, *createRawTxChange, txOptions(*createRawTxCoinSelect, *createRawTxLockTime, *createRawTxRelativeLock, *createRawTxFee, *createRawTxReplaceable))
+	}
+	if signRawTxCommand.Parsed() {
+		if *signRawTx == "" && *signRawTxFile == "" {
+			signRawTxCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.SignRawTx(readRawTx(*signRawTx, *signRawTxFile))
+	}
+	if sendRawTxCommand.Parsed() {
+		if *sendRawTx == "" && *sendRawTxFile == "" {
+			sendRawTxCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.SendRawTx(readRawTx(*sendRawTx, *sendRawTxFile))
+	}
+	if getPubKeyCommand.Parsed() {
+		if *getPubKeyAddress == "" {
+			getPubKeyCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.GetPubKey(*getPubKeyAddress)
+	}
+	if createMultisigCommand.Parsed() {
+		if *createMultisigRequired <= 0 || *createMultisigKeys == "" {
+			createMultisigCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.CreateMultisig(*createMultisigRequired, strings.Split(*createMultisigKeys, ","))
+	}
+	if createMuSigCommand.Parsed() {
+		if *createMuSigKeys == "" {
+			createMuSigCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.CreateMuSig(strings.Split(*createMuSigKeys, ","))
+	}
+	if signMessageCommand.Parsed() {
+		if *signMessageAddress == "" {
+			signMessageCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.SignMessage(*signMessageAddress, *signMessageMessage)
+	}
+	if verifyMessageCommand.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageSignature == "" {
+			verifyMessageCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.VerifyMessage(*verifyMessageAddress, *verifyMessageSignature, *verifyMessageMessage)
+	}
+	if anchorCommand.Parsed() {
+		if *anchorFile == "" {
+			anchorCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.Anchor(*anchorFile, *anchorFrom)
+	}
+	if verifyAnchorCommand.Parsed() {
+		if (*verifyAnchorFile == "") == (*verifyAnchorHash == "") {
+			verifyAnchorCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.VerifyAnchor(*verifyAnchorFile, *verifyAnchorHash)
+	}
+	if mempoolCommand.Parsed() {
+		inter.Mempool()
+	}
+	if bumpFeeCommand.Parsed() {
+		if *bumpFeeTxID == "" || *bumpFeeFee < 0 {
+			bumpFeeCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.BumpFee(*bumpFeeTxID, *bumpFeeFee)
+	}
+	if mineCommand.Parsed() {
+		if *mineAddress == "" || *mineMaxSize <= 0 {
+			mineCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.Mine(*mineAddress, *mineMaxSize)
+	}
+	if generateCommand.Parsed() {
+		if *generateAddress == "" || *generateBlocks <= 0 || *generateInterval < 0 {
+			generateCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.Generate(*generateBlocks, *generateAddress, *generateInterval)
+	}
+	if migrateWalletCommand.Parsed() {
+		inter.MigrateWallet()
+	}
+	if backupWalletCommand.Parsed() {
+		if *backupWalletAddress == "" || *backupWalletShares <= 0 || *backupWalletThreshold <= 0 {
+			backupWalletCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.BackupWallet(*backupWalletAddress, *backupWalletShares, *backupWalletThreshold, *backupWalletFormat)
+	}
+	if restoreWalletCommand.Parsed() {
+		var encodedShares []string
+		if *restoreWalletFile != "" {
+			content, err := os.ReadFile(*restoreWalletFile)
+			blockchain.PanicHandle(err)
+			encodedShares = strings.Split(string(content), "\n")
+		} else if *restoreWalletShares != "" {
+			encodedShares = strings.Split(*restoreWalletShares, ",")
+		} else {
+			restoreWalletCommand.Usage()
+			runtime.Goexit()
+		}
+		inter.RestoreWallet(encodedShares, *restoreWalletChange)
+	}
+	if vanityCommand.Parsed() {
+		if *vanityPrefix == "" || *vanityWorkers <= 0 {
+			vanityCommand.Usage()
+			runtime.Goexit()
+		}
+		scheme, err := wallet.ParseScheme(*vanityScheme)
+		if err != nil {
+			log.Panicf("ERROR: %v !", err)
+		}
+		inter.Vanity(*vanityPrefix, scheme, *vanityWorkers, *vanityTimeout)
+	}
+	if startNodeCommand.Parsed() {
+		if *startNodePort <= 0 || *startNodePort > 65535 {
+			startNodeCommand.Usage()
+			runtime.Goexit()
+		}
+		var peers []string
+		if *startNodeConnect != "" {
+			peers = strings.Split(*startNodeConnect, ",")
+		}
+		inter.StartNode(*startNodePort, peers, *startNodeMiner)
+	}
+}
+
+// UseDataDir to keep the blockchain and the wallets file in the data directory, the networks other than
+// mainnet in a directory of their name inside it so that their chains and wallets never mix
+func (inter *Interface) UseDataDir(dir string) {
+	if network := params.Active(); network != &params.Mainnet {
+		dir = filepath.Join(dir, network.Name)
+	}
+	err := os.MkdirAll(dir, 0755)
+	blockchain.PanicHandle(err)
+	blockchain.SetDataDir(dir)
+	wallet.SetDataDir(dir)
 }
 
 // Help to print help information for the CommandInterface
@@ -102,21 +488,204 @@ func (inter *Interface) Help() {
 	runtime.Goexit()
 }
 
-// CreateWallet to create a wallet in the addressbook
-func (inter *Interface) CreateWallet() {
+// CreateWallet to create a wallet signing with the scheme in the addressbook, showing its address in the format
+func (inter *Interface) CreateWallet(scheme wallet.SignatureScheme, format string) {
 	wallets, _ := wallet.CreateWallets()
-	address := wallets.AddWallet()
+	address := wallets.AddWallet(scheme)
 	wallets.SaveFile()
-	fmt.Printf("NEW ADDRESS: %s\n", address)
+	formatted, err := wallet.FormatAddress(address, format)
+	blockchain.PanicHandle(err)
+	fmt.Printf("NEW ADDRESS: %s\n", formatted)
 }
 
-// ListAddresses to list all addresses in the addressbook
-func (inter *Interface) ListAddresses() {
+// ListAddresses to list all addresses in the addressbook in the format
+func (inter *Interface) ListAddresses(format string) {
 	wallets, _ := wallet.CreateWallets()
+	show := func(address string) string {
+		formatted, err := wallet.FormatAddress(address, format)
+		blockchain.PanicHandle(err)
+		return formatted
+	}
 	addresses := wallets.GetAllAddresses()
 	for _, address := range addresses {
-		fmt.Println(address)
+		var notes []string
+		if wallets.IsChange(address) {
+			notes = append(notes, "change")
+		}
+		if scheme := wallets.Wallets[address].PrivateKey.Scheme; scheme != wallet.P256 {
+			notes = append(notes, scheme.Name())
+		}
+		if len(notes) > 0 {
+			fmt.Printf("%s (%s)\n", show(address), strings.Join(notes, ", "))
+		} else {
+			fmt.Println(show(address))
+		}
+	}
+	for _, address := range wallets.GetMultisigAddresses() {
+		multisig := wallets.Multisigs[address]
+		fmt.Printf("%s (multisig %d-of-%d)\n", show(address), multisig.Required, len(multisig.PublicKeys))
+	}
+	for _, address := range wallets.GetMuSigAddresses() {
+		count := len(wallets.MuSigs[address].PublicKeys)
+		fmt.Printf("%s (musig %d-of-%d)\n", show(address), count, count)
+	}
+	for _, address := range wallets.GetContractAddresses() {
+		fmt.Printf("%s (contract until %s)\n", show(address), blockchain.LockTimeString(wallets.Contracts[address].LockTime))
+	}
+	for _, address := range wallets.GetLegacyAddresses() {
+		fmt.Printf("%s (legacy, migrated to %s)\n", show(address), show(wallets.Legacy[address]))
+	}
+}
+
+// MigrateWallet to re-encode the X||Y public keys of the wallets file in SEC1 compressed form and
+// sweep whatever is left at the old addresses to the new ones when a blockchain exists
+func (inter *Interface) MigrateWallet() {
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	migrated := wallets.MigrateKeys()
+	wallets.SaveFile()
+	var addresses []string
+	for address := range migrated {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		fmt.Printf("MIGRATED %s TO %s.\n", address, migrated[address])
+	}
+	fmt.Printf("MIGRATED %d KEYS.\n", len(migrated))
+	if !blockchain.Exists() {
+		return
+	}
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	tx := chain.SweepLegacy(wallets)
+	if tx == nil {
+		return
+	}
+	chain.AddBlock([]*blockchain.Transaction{tx})
+	for _, out := range tx.Outputs {
+		fmt.Printf("SWEPT %d TO %s.\n", out.Value, out.Address())
+	}
+	fmt.Printf("TRANSACTION %x.\n", tx.ID)
+}
+
+// BackupWallet to split the private key of an address in the wallet into Shamir shares, any threshold of which restore it
+func (inter *Interface) BackupWallet(address string, n, threshold int, format string) {
+	if format != "mnemonic" && format != "base58" {
+		log.Panic("ERROR: SHARE FORMAT MUST BE mnemonic OR base58 !")
+	}
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	shares, err := wallets.BackupShares(address, n, threshold)
+	if err != nil {
+		log.Panicf("ERROR: BACKUP FAILED: %v !", err)
+	}
+	fmt.Printf("SPLIT THE KEY OF %s INTO %d SHARES, %d RESTORE IT.\n", address, n, threshold)
+	for _, share := range shares {
+		encoded := share.Mnemonic()
+		if format == "base58" {
+			encoded = share.Base58()
+		}
+		fmt.Printf("SHARE %d: %s\n", share.Index, encoded)
+	}
+}
+
+// RestoreWallet to recombine Shamir shares into the private key they were split from and add it to the wallet,
+// together with the first change addresses derived from it
+func (inter *Interface) RestoreWallet(encodedShares []string, change int) {
+	if change < 0 {
+		log.Panic("ERROR: CHANGE COUNT MUST NOT BE NEGATIVE !")
+	}
+	var shares []wallet.Share
+	for _, encoded := range encodedShares {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		share, err := wallet.ParseShare(encoded)
+		if err != nil {
+			log.Panicf("ERROR: SHARE IS NOT VALID: %v !", err)
+		}
+		shares = append(shares, share)
+	}
+	w, err := wallet.RestoreShares(shares)
+	if err != nil {
+		log.Panicf("ERROR: RESTORE FAILED: %v !", err)
+	}
+	wallets, _ := wallet.CreateWallets()
+	address := string(w.Address())
+	if _, ok := wallets.Wallets[address]; ok {
+		fmt.Printf("ADDRESS %s IS ALREADY IN THE WALLET.\n", address)
+	} else {
+		wallets.Wallets[address] = w
+		fmt.Printf("RESTORED ADDRESS: %s\n", address)
+	}
+	for _, changeAddress := range wallets.RestoreChange(w, change) {
+		fmt.Printf("RESTORED CHANGE ADDRESS: %s\n", changeAddress)
 	}
+	wallets.SaveFile()
+}
+
+// Vanity to search keys of the scheme on the workers for an address starting with the prefix, reporting
+// progress until one is found and stored in the wallet, interrupted or out of time
+func (inter *Interface) Vanity(prefix string, scheme wallet.SignatureScheme, workers int, timeout time.Duration) {
+	if err := wallet.ValidateVanityPrefix(prefix); err != nil {
+		log.Panicf("ERROR: PREFIX IS NOT VALID: %v !", err)
+	}
+	difficulty := wallet.VanityDifficulty(prefix)
+	fmt.Printf("SEARCHING FOR %s ON %d WORKERS, DIFFICULTY %.0f KEYS ON AVERAGE.\n", prefix, workers, difficulty)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var tried uint64
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				count := atomic.LoadUint64(&tried)
+				rate := float64(count) / time.Since(start).Seconds()
+				fmt.Fprintf(os.Stderr, "\rTRIED %d KEYS AT %.0f KEYS/S, %.1f%% CHANCE SO FAR, %s PER EXPECTED MATCH ",
+					count, rate, 100*wallet.VanityChance(difficulty, count), time.Duration(difficulty/rate*float64(time.Second)).Round(time.Second))
+			}
+		}
+	}()
+	w, err := wallet.FindVanity(ctx, scheme, prefix, workers, &tried)
+	close(done)
+	fmt.Fprintln(os.Stderr)
+	if err == context.DeadlineExceeded {
+		log.Panicf("ERROR: SEARCH TIMED OUT AFTER %d KEYS !", atomic.LoadUint64(&tried))
+	} else if err != nil {
+		log.Panicf("ERROR: SEARCH INTERRUPTED AFTER %d KEYS !", atomic.LoadUint64(&tried))
+	}
+	wallets, _ := wallet.CreateWallets()
+	address := string(w.Address())
+	wallets.Wallets[address] = w
+	wallets.SaveFile()
+	fmt.Printf("FOUND AFTER %d KEYS IN %s.\n", atomic.LoadUint64(&tried), time.Since(start).Round(time.Millisecond))
+	fmt.Printf("NEW ADDRESS: %s\n", address)
+}
+
+// ChangePolicy to show or set where the wallet sends the change of a spend
+func (inter *Interface) ChangePolicy(name string) {
+	wallets, _ := wallet.CreateWallets()
+	if name != "" {
+		policy, err := wallet.ParseChangePolicy(name)
+		if err != nil {
+			log.Panic("ERROR: CHANGE POLICY IS NOT VALID !")
+		}
+		wallets.ChangePolicy = policy
+		wallets.SaveFile()
+	}
+	fmt.Printf("CHANGE POLICY: %s\n", wallets.ChangePolicy)
 }
 
 // CreateBlockChain to create a blockchain with the address as the genesis.
@@ -130,18 +699,96 @@ func (inter *Interface) CreateBlockChain(address string) {
 }
 
 // Send to send the amount from FROM to TO
-func (inter *Interface) Send(from, to string, amount int) {
-	if !wallet.ValidateAddress(from) {
+func (inter *Interface) Send(from, to string, amount int, options blockchain.TxOptions, dryRun, pool bool) {
+	inter.SendMany(from, []blockchain.Payment{{Address: to, Amount: amount}}, options, dryRun, pool)
+}
+
+// SendFromWallet to send the amount to TO drawing from every address in the wallet
+func (inter *Interface) SendFromWallet(to string, amount int, options blockchain.TxOptions, dryRun, pool bool) {
+	inter.SendMany("", []blockchain.Payment{{Address: to, Amount: amount}}, options, dryRun, pool)
+}
+
+// SendMany to pay every payment in one transaction from FROM, or from every address in the wallet when FROM is empty,
+// a transaction whose lock times have not passed is printed signed instead of mined, for sendrawtx later, and with
+// pool the transaction waits in the pool to be mined
+func (inter *Interface) SendMany(from string, payments []blockchain.Payment, options blockchain.TxOptions, dryRun, pool bool) {
+	if from != "" && !wallet.ValidateAddress(from) {
 		log.Panic("ERROR: ADDRESS IS NOT VALID !")
 	}
-	if !wallet.ValidateAddress(to) {
-		log.Panic("ERROR: ADDRESS IS NOT VALID !")
+	if err := blockchain.ValidatePayments(payments); err != nil {
+		log.Panicf("ERROR: PAYMENTS ARE NOT VALID: %v !", err)
 	}
 	chain := blockchain.ContinueBlockChain(from)
 	defer chain.DataBase.Close()
-	tx := blockchain.NewTransaction(from, to, amount, chain)
+	if dryRun {
+		sources := []string{from}
+		if from == "" {
+			wallets, _ := wallet.CreateWallets()
+			sources = wallets.GetAllAddresses()
+		}
+		inter.PrintSelection(chain, sources, blockchain.PaymentsTotal(payments), options.Fee, options.Selector)
+		return
+	}
+	// a transaction waiting in the pool may spend outputs of others still waiting, e.g. a child paying for its parent
+	options.SpendPending = pool
+	var tx *blockchain.Transaction
+	if from == "" {
+		tx = blockchain.NewWalletTransaction(payments, options, chain)
+	} else {
+		tx = blockchain.NewMultiOutputTransaction(from, payments, options, chain)
+	}
+	if pool {
+		if err := chain.TxPool().Add(tx); err != nil {
+			log.Panicf("ERROR: TRANSACTION IS NOT VALID: %v !", err)
+		}
+		fmt.Printf("PENDING, TRANSACTION %x.\n", tx.ID)
+		return
+	}
+	if mineOrPrint(chain, tx) {
+		fmt.Println("SUCCESS.")
+	}
+}
+
+// mineOrPrint to mine the transaction, or to print it signed for sendrawtx when its lock times have not passed
+func mineOrPrint(chain *blockchain.BlockChain, tx *blockchain.Transaction) bool {
+	if err := chain.CheckLocks(tx); err != nil {
+		raw, rawErr := chain.RawTransactionOf(tx)
+		blockchain.PanicHandle(rawErr)
+		fmt.Fprintln(os.Stderr, raw)
+		fmt.Fprintf(os.Stderr, "NOT MINED, %v: SEND IT WITH sendrawtx ONCE THE LOCK HAS PASSED.\n", err)
+		fmt.Println(raw.Encode())
+		return false
+	}
 	chain.AddBlock([]*blockchain.Transaction{tx})
-	fmt.Println("SUCCESS.")
+	return true
+}
+
+// PrintSelection to print the inputs the selector would spend from the sources and the change left
+func (inter *Interface) PrintSelection(chain *blockchain.BlockChain, sources []string, amount, fee int, selector blockchain.CoinSelector) {
+	wallets, _ := wallet.CreateWallets()
+	selection, err := chain.SelectCoins(blockchain.SourcePublicKeyHashes(wallets, sources), amount+fee, selector)
+	if err == blockchain.ErrInsufficientFunds {
+		log.Panic("ERROR: NOT ENOUGH FUNDS !")
+	}
+	blockchain.PanicHandle(err)
+	fmt.Println("DRY RUN, NOTHING SENT.")
+	for i, input := range selection.Inputs {
+		address := input.Output.Address()
+		fmt.Printf(" • Input %d        : %x:%d %d from %s\n", i, input.ID, input.Out, input.Output.Value, address)
+	}
+	fmt.Printf(" • Total          : %d\n", selection.Total)
+	fmt.Printf(" • Amount         : %d\n", amount)
+	fmt.Printf(" • Fee            : %d\n", fee)
+	fmt.Printf(" • Change         : %d\n", selection.Change)
+}
+
+// coinSelector to get the coin selection strategy by name
+func coinSelector(name string) blockchain.CoinSelector {
+	selector, err := blockchain.NewCoinSelector(name)
+	if err != nil {
+		log.Panic("ERROR: COIN SELECTOR IS NOT VALID !")
+	}
+	return selector
 }
 
 // GetBalance to get the balance from the address
@@ -151,14 +798,295 @@ func (inter *Interface) GetBalance(address string) {
 	}
 	chain := blockchain.ContinueBlockChain(address)
 	defer chain.DataBase.Close()
+	balance := addressBalance(chain, address)
+	fmt.Printf("Balance of %s: %d\n", address, balance)
+}
+
+// GetWalletBalance to get the balance held across every address of the wallet
+func (inter *Interface) GetWalletBalance() {
+	wallets, _ := wallet.CreateWallets()
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	total := 0
+	for _, address := range wallets.GetAllAddresses() {
+		balance := addressBalance(chain, address)
+		if balance == 0 {
+			continue
+		}
+		if wallets.IsChange(address) {
+			fmt.Printf("Balance of %s (change): %d\n", address, balance)
+		} else {
+			fmt.Printf("Balance of %s: %d\n", address, balance)
+		}
+		total += balance
+	}
+	fmt.Printf("Balance of wallet: %d\n", total)
+	for _, address := range wallets.GetMultisigAddresses() {
+		multisig := wallets.Multisigs[address]
+		fmt.Printf("Balance of %s (multisig %d-of-%d, not counted): %d\n", address, multisig.Required, len(multisig.PublicKeys), addressBalance(chain, address))
+	}
+	for _, address := range wallets.GetMuSigAddresses() {
+		count := len(wallets.MuSigs[address].PublicKeys)
+		fmt.Printf("Balance of %s (musig %d-of-%d, not counted): %d\n", address, count, count, addressBalance(chain, address))
+	}
+}
+
+// History to list the Transactions of the address, or of every wallet address
+func (inter *Interface) History(address string, asJSON bool) {
+	var publicKeyHashes [][]byte
+	if address != "" {
+		if !wallet.ValidateAddress(address) {
+			log.Panic("ERROR: ADDRESS IS NOT VALID !")
+		}
+		publicKeyHashes = append(publicKeyHashes, addressPublicKeyHash(address))
+	} else {
+		wallets, _ := wallet.CreateWallets()
+		for _, owned := range wallets.GetAllAddresses() {
+			publicKeyHashes = append(publicKeyHashes, addressPublicKeyHash(owned))
+		}
+	}
+	chain := blockchain.ContinueBlockChain(address)
+	defer chain.DataBase.Close()
+	history := chain.History(publicKeyHashes)
+	if asJSON {
+		if history == nil {
+			history = []blockchain.HistoryEntry{}
+		}
+		encoded, err := json.MarshalIndent(history, "", "  ")
+		blockchain.PanicHandle(err)
+		fmt.Println(string(encoded))
+		return
+	}
+	for _, entry := range history {
+		fmt.Printf(" • Transaction %s:\n", entry.TransactionID)
+		fmt.Printf("   • Height           : %d (%d confirmations)\n", entry.Height, entry.Confirmations)
+		fmt.Printf("   • Direction        : %s\n", entry.Direction)
+		fmt.Printf("   • Net Amount       : %+d\n", entry.Net)
+		fmt.Printf("   • Fee              : %d\n", entry.Fee)
+		for _, counterparty := range entry.Counterparties {
+			fmt.Printf("   • Counterparty     : %s\n", counterparty)
+		}
+	}
+}
+
+// CreateRawTx to print an unsigned raw transaction paying the payments from FROM, needing no private keys
+func (inter *Interface) CreateRawTx(from string, payments []blockchain.Payment, change string, options blockchain.TxOptions) {
+	if !wallet.ValidateAddress(from) || (change != "" && !wallet.ValidateAddress(change)) {
+		log.Panic("ERROR: ADDRESS IS NOT VALID !")
+	}
+	chain := blockchain.ContinueBlockChain(from)
+	defer chain.DataBase.Close()
+	raw, err := blockchain.CreateRawTransaction(from, payments, change, options, chain)
+	if err == blockchain.ErrInsufficientFunds {
+		log.Panic("ERROR: NOT ENOUGH FUNDS !")
+	}
+	blockchain.PanicHandle(err)
+	fmt.Fprintln(os.Stderr, raw)
+	fmt.Println(raw.Encode())
+}
+
+// SignRawTx to sign the inputs of a raw transaction owned by the wallet, needing no blockchain,
+// the secret nonces drawn for MuSig inputs are kept in the wallets file until the next round
+func (inter *Interface) SignRawTx(raw *blockchain.RawTransaction) {
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	signed, err := raw.Sign(wallets)
+	if err != nil {
+		log.Panicf("ERROR: MUSIG SIGNING FAILED: %v !", err)
+	}
+	wallets.SaveFile()
+	fmt.Fprintln(os.Stderr, raw)
+	if raw.IsComplete() {
+		fmt.Fprintf(os.Stderr, "SIGNED %d INPUTS, TRANSACTION IS COMPLETE.\n", signed)
+	} else {
+		fmt.Fprintf(os.Stderr, "SIGNED %d INPUTS, TRANSACTION NEEDS MORE SIGNATURES.\n", signed)
+	}
+	fmt.Println(raw.Encode())
+}
+
+// SendRawTx to check a signed raw transaction against the blockchain and mine it
+func (inter *Interface) SendRawTx(raw *blockchain.RawTransaction) {
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	tx, err := chain.SendRawTransaction(raw)
+	if err != nil {
+		log.Panicf("ERROR: TRANSACTION IS NOT VALID: %v !", err)
+	}
+	fmt.Printf("SUCCESS, TRANSACTION %x.\n", tx.ID)
+}
+
+// readRawTx to decode a raw transaction given inline or in a file
+func readRawTx(encoded, file string) *blockchain.RawTransaction {
+	if file != "" {
+		content, err := os.ReadFile(file)
+		blockchain.PanicHandle(err)
+		encoded = string(content)
+	}
+	raw, err := blockchain.DecodeRawTransaction(encoded)
+	if err != nil {
+		log.Panicf("ERROR: RAW TRANSACTION IS NOT VALID: %v !", err)
+	}
+	return raw
+}
+
+// GetPubKey to print the public key of an address in the wallet for sharing with co-signers
+func (inter *Interface) GetPubKey(address string) {
+	wallets, _ := wallet.CreateWallets()
+	w, ok := wallets.Wallets[wallet.Base58Address(address)]
+	if !ok {
+		log.Panic("ERROR: ADDRESS IS NOT IN THE WALLET !")
+	}
+	fmt.Printf("%x\n", w.PublicKey)
+}
+
+// CreateMultisig to create an m-of-n multi-signature address and remember it in the wallet
+func (inter *Interface) CreateMultisig(required int, encodedKeys []string) {
+	var publicKeys [][]byte
+	for _, encodedKey := range encodedKeys {
+		publicKey, err := hex.DecodeString(strings.TrimSpace(encodedKey))
+		if err != nil {
+			log.Panic("ERROR: PUBLIC KEY IS NOT VALID !")
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	multisig, err := wallet.NewMultisig(required, publicKeys)
+	if err != nil {
+		log.Panicf("ERROR: MULTISIG IS NOT VALID: %v !", err)
+	}
+	wallets, _ := wallet.CreateWallets()
+	address := wallets.AddMultisig(multisig)
+	wallets.SaveFile()
+	fmt.Printf("NEW %d-OF-%d ADDRESS: %s\n", multisig.Required, len(multisig.PublicKeys), address)
+}
+
+// CreateMuSig to create an n-of-n MuSig address of Schnorr keys and remember it in the wallet
+func (inter *Interface) CreateMuSig(encodedKeys []string) {
+	var publicKeys [][]byte
+	for _, encodedKey := range encodedKeys {
+		publicKey, err := hex.DecodeString(strings.TrimSpace(encodedKey))
+		if err != nil {
+			log.Panic("ERROR: PUBLIC KEY IS NOT VALID !")
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	musig, err := wallet.NewMuSig(publicKeys)
+	if err != nil {
+		log.Panicf("ERROR: MUSIG IS NOT VALID: %v !", err)
+	}
+	wallets, _ := wallet.CreateWallets()
+	address := wallets.AddMuSig(musig)
+	wallets.SaveFile()
+	fmt.Printf("NEW %d-OF-%d MUSIG ADDRESS: %s\n", len(musig.PublicKeys), len(musig.PublicKeys), address)
+	fmt.Printf("AGGREGATE KEY: %x\n", musig.AggregateKey())
+}
+
+// SignMessage to sign a message with the key of an address in the wallet
+func (inter *Interface) SignMessage(address, message string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("ERROR: ADDRESS IS NOT VALID !")
+	}
+	wallets, _ := wallet.CreateWallets()
+	w, ok := wallets.Wallets[wallet.Base58Address(address)]
+	if !ok {
+		log.Panic("ERROR: ADDRESS IS NOT IN THE WALLET !")
+	}
+	signature, err := w.SignMessage(message)
+	blockchain.PanicHandle(err)
+	fmt.Println(signature)
+}
+
+// VerifyMessage to check that a message was signed by the key of an address
+func (inter *Interface) VerifyMessage(address, signature, message string) {
+	valid, err := wallet.VerifyMessage(address, signature, message)
+	if err != nil {
+		log.Panicf("ERROR: SIGNATURE IS NOT VALID: %v !", err)
+	}
+	if !valid {
+		fmt.Println("SIGNATURE DOES NOT MATCH.")
+		os.Exit(1)
+	}
+	fmt.Println("SIGNATURE MATCHES.")
+}
+
+// Anchor to embed the SHA256 hash of the file in a data carrier output, paid for by FROM or by any wallet address
+func (inter *Interface) Anchor(file, from string) {
+	hash, err := fileHash(file)
+	if err != nil {
+		log.Panicf("ERROR: FILE CANNOT BE READ: %v !", err)
+	}
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	sources := wallets.GetAllAddresses()
+	if from != "" {
+		from = wallet.Base58Address(from)
+		if _, ok := wallets.Wallets[from]; !ok {
+			log.Panic("ERROR: ADDRESS IS NOT IN THE WALLET !")
+		}
+		sources = []string{from}
+	}
+	chain := blockchain.ContinueBlockChain(from)
+	defer chain.DataBase.Close()
+	tx, err := blockchain.NewDataTransaction(sources, hash, blockchain.TxOptions{}, chain)
+	if err == blockchain.ErrInsufficientFunds {
+		log.Panic("ERROR: NOT ENOUGH FUNDS !")
+	}
+	blockchain.PanicHandle(err)
+	chain.AddBlock([]*blockchain.Transaction{tx})
+	fmt.Printf("ANCHORED SHA256 %x IN TRANSACTION %x.\n", hash, tx.ID)
+}
+
+// VerifyAnchor to find the block anchoring the SHA256 hash of the file, or the hex hash, exits with status 1 when none does
+func (inter *Interface) VerifyAnchor(file, encodedHash string) {
+	var hash []byte
+	var err error
+	if file != "" {
+		hash, err = fileHash(file)
+	} else {
+		hash, err = hex.DecodeString(encodedHash)
+	}
+	if err != nil {
+		log.Panicf("ERROR: HASH CANNOT BE READ: %v !", err)
+	}
+	chain := blockchain.ContinueBlockChain("")
+	location, found := chain.FindData(hash)
+	chain.DataBase.Close()
+	if !found {
+		fmt.Printf("SHA256 %x IS NOT ANCHORED.\n", hash)
+		os.Exit(1)
+	}
+	fmt.Printf("SHA256 %x IS ANCHORED:\n", hash)
+	fmt.Printf(" • Block          : %x\n", location.Block.Hash)
+	fmt.Printf(" • Height         : %d\n", location.Block.Height)
+	fmt.Printf(" • Time           : %s\n", time.Unix(location.Block.Timestamp, 0).UTC().Format(time.RFC3339))
+	fmt.Printf(" • Transaction    : %x\n", location.Transaction.ID)
+	fmt.Printf(" • Confirmations  : %d\n", location.Confirmations)
+}
+
+// fileHash to hash the contents of the file with SHA256
+func fileHash(file string) ([]byte, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(content)
+	return hash[:], nil
+}
+
+// addressPublicKeyHash to extract the public key hash from the address
+func addressPublicKeyHash(address string) []byte {
+	publicKeyHash, _, err := wallet.DecodeAddress(address)
+	blockchain.PanicHandle(err)
+	return publicKeyHash
+}
+
+// addressBalance to sum the unspent outputs locked to the address
+func addressBalance(chain *blockchain.BlockChain, address string) int {
 	balance := 0
-	publicKeyHash := wallet.Base58Decode([]byte(address))
-	publicKeyHash = publicKeyHash[1 : len(publicKeyHash)-4]
-	unSpentTransactionOutputs := chain.FindUnspentTransactionsOutputs(publicKeyHash)
-	for _, output := range unSpentTransactionOutputs {
-		balance += output.Value
+	publicKeyHash := addressPublicKeyHash(address)
+	for _, unSpent := range chain.FindUnspentOutputs([][]byte{publicKeyHash}) {
+		balance += unSpent.Output.Value
 	}
-	fmt.Printf("Balance of %s: %d\n", address, balance)
+	return balance
 }
 
 // PrintChain to print the Blocks in the BlockChain from inter
@@ -170,6 +1098,8 @@ func (inter *Interface) PrintChain() {
 		block := iterator.Next()
 		fmt.Printf("PREVIOUS HASH: %x\n", block.PreviousHash)
 		fmt.Printf("MAIN HASH: %x\n", block.Hash)
+		fmt.Printf("HEIGHT: %d\n", block.Height)
+		fmt.Printf("TIME: %s\n", time.Unix(block.Timestamp, 0).UTC().Format(time.RFC3339))
 		proofOfWork := blockchain.NewProof(block)
 		fmt.Printf("PROOF OF WORK: %s\n", strconv.FormatBool(proofOfWork.Validate()))
 		for _, tx := range block.Transactions {
@@ -185,14 +1115,45 @@ func (inter *Interface) PrintChain() {
 // PrintUsage for printing usage instructions
 func (inter *Interface) PrintUsage() {
 	inter.PrintVersionInfo()
-	fmt.Println("USAGE:")
+	fmt.Println("USAGE: [-datadir DIR] [-network mainnet|testnet|regtest] [-mocktime TIME] COMMAND ...")
 	fmt.Println(" • help                                  - prints the usage for the blockchain utility.")
-	fmt.Println(" • createwallet                          - creates a new wallet.")
-	fmt.Println(" • listaddresses                         - lists the addresses in our wallet file.")
+	fmt.Println(" • createwallet [-scheme p256|secp256k1|ed25519|schnorr] [-format base58|bech32] - creates a new wallet signing with the scheme.")
+	fmt.Println(" • listaddresses [-format base58|bech32] - lists the addresses in our wallet file.")
 	fmt.Println(" • createblockchain -address ADDRESS     - creates a blockchain.")
 	fmt.Println(" • send -from FROM -to TO -amount AMOUNT - send amount from an address to an address.")
+	fmt.Println(" • send -fromwallet -to TO -amount AMOUNT - send amount from the wallet's addresses to an address.")
+	fmt.Println("     [-coinselect bnb|largest|smallest|random|chain] [-dryrun] - choose how inputs are picked, or only report them.")
+	fmt.Println("     [-locktime HEIGHT|TIME|DATE] [-relativelock BLOCKS|DURATION] - pre-sign a transaction that cannot be mined yet.")
+	fmt.Println("     [-fee FEE] [-replaceable] [-pool] - pay a fee, allow replacing it by fee, or leave it waiting in the pool.")
+	fmt.Println(" • sendmany -from FROM|-fromwallet -to ADDRESS:AMOUNT ... [-file PAYMENTS] - pays many addresses in one transaction.")
 	fmt.Println(" • getbalance -address ADDRESS           - get balance for address.")
+	fmt.Println(" • getbalance                            - get balance across all addresses in our wallet file.")
 	fmt.Println(" • printchain                            - prints the blocks in the blockchain.")
+	fmt.Println(" • createrawtx -from FROM -to ADDRESS:AMOUNT ... [-change ADDRESS] - prints an unsigned raw transaction.")
+	fmt.Println(" • signrawtx -tx RAW|-file FILE           - signs the inputs of a raw transaction owned by the wallet.")
+	fmt.Println(" • sendrawtx -tx RAW|-file FILE           - verifies a signed raw transaction and mines it.")
+	fmt.Println(" • getpubkey -address ADDRESS            - prints the public key of an address for co-signers.")
+	fmt.Println(" • createmultisig -required M -pubkeys KEY,KEY,... - creates an M-of-N multi-signature address.")
+	fmt.Println(" • createmusig -pubkeys KEY,KEY,...     - creates an N-of-N address of Schnorr keys spent with one aggregated signature.")
+	fmt.Println(" • signmessage -address ADDRESS -message MESSAGE - signs a message with the key of an address.")
+	fmt.Println(" • verifymessage -address ADDRESS -signature SIGNATURE -message MESSAGE - verifies a signed message.")
+	fmt.Println(" • anchor -file FILE [-from ADDRESS]     - timestamps the hash of a file in the blockchain.")
+	fmt.Println(" • verifyanchor -file FILE|-hash HASH    - finds the block anchoring the hash of a file.")
+	fmt.Println(" • htlc create -from FROM -to TO -amount AMOUNT -locktime HEIGHT|TIME|DATE|DURATION [-hash HASH] - locks funds for TO until the lock time.")
+	fmt.Println(" • htlc inspect -contract CONTRACT       - checks the terms and funding of a contract and adds it to the wallet.")
+	fmt.Println(" • htlc redeem -contract CONTRACT [-preimage SECRET] - claims a contract with the preimage of its hash.")
+	fmt.Println(" • htlc refund -contract CONTRACT        - takes back the funds of a contract once its lock time has passed.")
+	fmt.Println(" • mempool                               - lists the transactions waiting in the pool with their package fee rates.")
+	fmt.Println(" • bumpfee -txid TXID [-fee FEE]        - replaces a pending replaceable transaction with one paying a higher fee.")
+	fmt.Println(" • mine -address ADDRESS [-maxsize BYTES] - mines the best paying pending transactions, paying the subsidy and their fees to the address.")
+	fmt.Println(" • generate -blocks N -address ADDRESS [-interval DURATION] - mines N blocks at once on regtest, paying the subsidy and fees to the address.")
+	fmt.Println(" • backupwallet -address ADDRESS -shares N -threshold K [-format mnemonic|base58] - splits the key of an address into K-of-N Shamir shares.")
+	fmt.Println(" • restorewallet -shares SHARE,SHARE,...|-file FILE [-change N] - recombines Shamir shares into their key and adds it to the wallet with its first N change addresses.")
+	fmt.Println(" • vanity -prefix PREFIX [-scheme SCHEME] [-workers N] [-timeout DURATION] - searches for a wallet whose address starts with the prefix.")
+	fmt.Println(" • startnode [-port PORT] [-connect HOST:PORT,...] [-miner ADDRESS] - runs a node relaying blocks and transactions with its peers.")
+	fmt.Println(" • migratewallet                         - re-encodes old wallet keys in SEC1 form and sweeps their funds to the new addresses.")
+	fmt.Println(" • changepolicy -policy fresh|sender     - sets where the change of a send goes.")
+	fmt.Println(" • history -address ADDRESS [-json]      - lists transactions of an address, or of the wallet.")
 }
 
 // PrintVersionInfo to print version information of the system
@@ -201,8 +1162,8 @@ func (inter *Interface) PrintVersionInfo() {
 }
 
 // ValidateArguments to validate the arguments for the CommandInterface
-func (inter *Interface) ValidateArguments() {
-	if len(os.Args) < 2 {
+func (inter *Interface) ValidateArguments(args []string) {
+	if len(args) < 1 {
 		inter.PrintUsage()
 		runtime.Goexit()
 	}
@@ -0,0 +1,94 @@
+package line
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+	"github.com/the-code-innovator/go-blockchain/params"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// Mempool to list the transactions waiting in the pool, highest fee rate first, with the package
+// each forms with its pending ancestors, which block assembly scores them by
+func (inter *Interface) Mempool() {
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	packages := chain.TxPool().Packages()
+	fmt.Printf("%d PENDING TRANSACTIONS.\n", len(packages))
+	for _, pkg := range packages {
+		entry := pkg.Entry
+		fmt.Printf(" • Transaction %x:\n", entry.Transaction.ID)
+		fmt.Printf("   • Fee              : %d\n", entry.Fee)
+		fmt.Printf("   • Size             : %d\n", entry.Size)
+		fmt.Printf("   • Fee Rate         : %.2f/kB\n", entry.FeeRate())
+		fmt.Printf("   • Replaceable      : %t\n", entry.Transaction.IsReplaceable())
+		for _, ancestor := range pkg.Ancestors {
+			fmt.Printf("   • Ancestor         : %x\n", ancestor.Transaction.ID)
+		}
+		fmt.Printf("   • Package Fee      : %d\n", pkg.Fee)
+		fmt.Printf("   • Package Size     : %d\n", pkg.Size)
+		fmt.Printf("   • Package Fee Rate : %.2f/kB\n", pkg.FeeRate())
+	}
+}
+
+// BumpFee to replace a pending transaction of the wallet with one paying a higher fee out of its change
+func (inter *Interface) BumpFee(encodedID string, fee int) {
+	txID, err := hex.DecodeString(encodedID)
+	if err != nil {
+		log.Panic("ERROR: TRANSACTION ID IS NOT VALID !")
+	}
+	wallets, err := wallet.CreateWallets()
+	blockchain.PanicHandle(err)
+	chain := blockchain.ContinueBlockChain("")
+	defer chain.DataBase.Close()
+	tx, err := chain.BumpFee(txID, fee, wallets)
+	if err != nil {
+		log.Panicf("ERROR: FEE CANNOT BE BUMPED: %v !", err)
+	}
+	entry, _ := chain.TxPool().Get(tx.ID)
+	fmt.Printf("REPLACED BY TRANSACTION %x, FEE %d.\n", tx.ID, entry.Fee)
+}
+
+// Mine to mine the transactions waiting in the pool into a block of at most maxSize bytes of transactions,
+// paying the subsidy and their fees to the address
+func (inter *Interface) Mine(address string, maxSize int) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("ERROR: ADDRESS IS NOT VALID !")
+	}
+	chain := blockchain.ContinueBlockChain(address)
+	defer chain.DataBase.Close()
+	block := chain.MineBlock(address, maxSize)
+	fmt.Printf("MINED BLOCK %x AT HEIGHT %d WITH %d TRANSACTIONS.\n", block.Hash, block.Height, len(block.Transactions))
+}
+
+// Generate to mine blocks on demand paying the subsidy and the fees of pending transactions to the address,
+// stamped the interval apart from the clock or the mock time when an interval is given, regtest only
+func (inter *Interface) Generate(blocks int, address string, interval time.Duration) {
+	if !params.Active().OnDemand {
+		log.Panicf("ERROR: BLOCKS CAN ONLY BE GENERATED ON %s !", strings.ToUpper(params.Regtest.Name))
+	}
+	if !wallet.ValidateAddress(address) {
+		log.Panic("ERROR: ADDRESS IS NOT VALID !")
+	}
+	// block times are stamped in whole seconds, a shorter or fractional interval would be cut down
+	if interval < 0 || interval%time.Second != 0 {
+		log.Panicf("ERROR: INTERVAL %s IS NOT A WHOLE NUMBER OF SECONDS !", interval)
+	}
+	chain := blockchain.ContinueBlockChain(address)
+	defer chain.DataBase.Close()
+	start := blockchain.Now()
+	var block *blockchain.Block
+	for generated := 0; generated < blocks; generated++ {
+		if interval > 0 {
+			blockchain.PanicHandle(blockchain.SetMockTime(start + int64(generated)*int64(interval/time.Second)))
+		}
+		block = chain.GenerateBlock(address)
+		fmt.Printf("%x\n", block.Hash)
+	}
+	fmt.Fprintf(os.Stderr, "GENERATED %d BLOCKS, HEIGHT %d.\n", blocks, block.Height)
+}
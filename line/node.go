@@ -0,0 +1,28 @@
+package line
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+	"github.com/the-code-innovator/go-blockchain/node"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// StartNode to run a node of the network on the port connected to the peers until interrupted, mining
+// the transactions it receives into blocks paying the subsidy and their fees to the miner address when one is given, a
+// data directory without a blockchain downloads that of its peers
+func (inter *Interface) StartNode(port int, peers []string, miner string) {
+	if miner != "" && !wallet.ValidateAddress(miner) {
+		log.Panic("ERROR: MINER ADDRESS IS NOT VALID !")
+	}
+	chain := blockchain.OpenBlockChain()
+	defer chain.DataBase.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := node.NewNode(chain, miner).Run(ctx, port, peers); err != nil {
+		log.Panicf("ERROR: NODE FAILED: %v !", err)
+	}
+}
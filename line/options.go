@@ -0,0 +1,83 @@
+package line
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+)
+
+// txOptions to build the funding choices of a transaction from the command line flags
+func txOptions(coinSelect, lockTime, relativeLock string, fee int, replaceable bool) blockchain.TxOptions {
+	if fee < 0 {
+		log.Panic("ERROR: FEE IS NOT VALID !")
+	}
+	options := blockchain.TxOptions{Selector: coinSelector(coinSelect), Fee: fee, Replaceable: replaceable}
+	var err error
+	if options.LockTime, err = parseLockTime(lockTime); err != nil {
+		log.Panicf("ERROR: LOCK TIME IS NOT VALID: %v !", err)
+	}
+	if options.RelativeLock, err = parseRelativeLock(relativeLock); err != nil {
+		log.Panicf("ERROR: RELATIVE LOCK IS NOT VALID: %v !", err)
+	}
+	return options
+}
+
+// parseLockTime to parse a block height, a Unix time, a date as YYYY-MM-DD or RFC3339,
+// or a duration such as 48h from now
+func parseLockTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if number < 0 {
+			return 0, errors.New("lock time is negative")
+		}
+		return number, nil
+	}
+	if date, err := parseDate(value); err == nil {
+		if date < blockchain.LockTimeThreshold {
+			return 0, errors.New("date is too early")
+		}
+		return date, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return blockchain.Now() + int64(duration/time.Second), nil
+	}
+	return 0, errors.New("expected a block height, a Unix time, a date or a duration")
+}
+
+// parseDate to parse a date as YYYY-MM-DD or RFC3339 into a Unix time
+func parseDate(value string) (int64, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Unix(), nil
+		}
+	}
+	return 0, errors.New("expected a date as YYYY-MM-DD or RFC3339")
+}
+
+// parseTime to parse a Unix time or a date
+func parseTime(value string) (int64, error) {
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil && number > 0 {
+		return number, nil
+	}
+	return parseDate(value)
+}
+
+// parseRelativeLock to parse a relative lock time as a number of blocks or a duration such as 48h
+func parseRelativeLock(value string) (uint32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if blocks, err := strconv.Atoi(value); err == nil {
+		return blockchain.RelativeLockBlocks(blocks)
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New("expected a number of blocks or a duration")
+	}
+	return blockchain.RelativeLockDuration(duration)
+}
@@ -0,0 +1,87 @@
+package line
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+)
+
+// paymentList flag value collecting repeated ADDRESS:AMOUNT arguments
+type paymentList []blockchain.Payment
+
+// String to output the payments as they are given on the command line
+func (payments *paymentList) String() string {
+	var parts []string
+	for _, payment := range *payments {
+		parts = append(parts, fmt.Sprintf("%s:%d", payment.Address, payment.Amount))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set to parse one or more comma separated ADDRESS:AMOUNT arguments
+func (payments *paymentList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		fields := strings.Split(part, ":")
+		if len(fields) != 2 {
+			return fmt.Errorf("payment %q is not ADDRESS:AMOUNT", part)
+		}
+		payment, err := parsePayment(fields[0], fields[1])
+		if err != nil {
+			return err
+		}
+		*payments = append(*payments, payment)
+	}
+	return nil
+}
+
+// parsePayment to build a payment from its address and amount fields
+func parsePayment(address, amount string) (blockchain.Payment, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(amount))
+	if err != nil {
+		return blockchain.Payment{}, fmt.Errorf("amount %q is not a number", amount)
+	}
+	return blockchain.Payment{Address: strings.TrimSpace(address), Amount: value}, nil
+}
+
+// readPaymentsFile to read payments from a JSON array of {"address", "amount"} objects
+// or from CSV lines of address,amount with an optional header line
+func readPaymentsFile(path string) ([]blockchain.Payment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var payments []blockchain.Payment
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewDecoder(file).Decode(&payments)
+		return payments, err
+	}
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "address") {
+			continue
+		}
+		payment, err := parsePayment(record[0], record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
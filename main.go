@@ -11,5 +11,6 @@ func main() {
 	defer os.Exit(0)
 	inter := line.Interface{}
 	inter.Run()
-	fmt.Println("exitting ....")
+	// kept off stdout so that machine readable output stays parseable
+	fmt.Fprintln(os.Stderr, "exitting ....")
 }
@@ -0,0 +1,505 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+)
+
+// constants of the download of the blocks, which are asked of every peer that has them, at most maxBlocksInFlight
+// at a time, within a window of downloadWindow blocks past the last one connected
+const (
+	maxBlocksInFlight = 16
+	downloadWindow    = 1024
+	maxFailures       = 3
+	progressInterval  = 5 * time.Second
+)
+
+// timeouts of the download, variables so that tests can shorten them: a block not received within blockTimeout
+// is asked of another peer, and a peer letting maxFailures requests time out is dropped, as is one holding back
+// the full window for longer than stallTimeout, the requests are checked every watchInterval
+var (
+	blockTimeout  = 20 * time.Second
+	stallTimeout  = 5 * time.Second
+	watchInterval = time.Second
+)
+
+// blockDownload structure for the headers checked past the last block of the BlockChain, whose blocks are
+// downloaded from the peers in parallel and connected in order
+type blockDownload struct {
+	// headers are oldest first and pending holds their hashes
+	headers  []blockchain.BlockHeader
+	pending  map[string]bool
+	inFlight map[string]*blockRequest
+	// received holds the blocks that arrived before those they follow
+	received map[string]*receivedBlock
+	// startHeight and started are the height and time the download started from, reported when progress was last printed
+	startHeight int
+	started     time.Time
+	reported    time.Time
+}
+
+// blockRequest structure for a block asked of a peer
+type blockRequest struct {
+	peer *peer
+	sent time.Time
+}
+
+// receivedBlock structure for a block waiting for those it follows, and the peer that sent it
+type receivedBlock struct {
+	block *blockchain.Block
+	peer  *peer
+}
+
+// newBlockDownload to create an empty blockDownload
+func newBlockDownload() *blockDownload {
+	return &blockDownload{
+		pending:  make(map[string]bool),
+		inFlight: make(map[string]*blockRequest),
+		received: make(map[string]*receivedBlock),
+	}
+}
+
+// add to append the checked headers, starting the download when there were none
+func (download *blockDownload) add(headers []blockchain.BlockHeader) {
+	if len(download.headers) == 0 {
+		download.startHeight = headers[0].Height - 1
+		download.started = time.Now()
+		download.reported = download.started
+	}
+	for _, header := range headers {
+		download.headers = append(download.headers, header)
+		download.pending[string(header.Hash)] = true
+	}
+}
+
+// cancel to forget the request of the block of the hash
+func (download *blockDownload) cancel(hash string) {
+	if request, ok := download.inFlight[hash]; ok {
+		request.peer.inFlight--
+		delete(download.inFlight, hash)
+	}
+}
+
+// release to forget the requests sent to the peer, so that they are asked of others
+func (download *blockDownload) release(p *peer) {
+	for hash, request := range download.inFlight {
+		if request.peer == p {
+			download.cancel(hash)
+		}
+	}
+}
+
+// reset to drop the headers along with the blocks requested and received for them
+func (download *blockDownload) reset() {
+	for hash := range download.inFlight {
+		download.cancel(hash)
+	}
+	*download = *newBlockDownload()
+}
+
+// truncate to drop the headers past the first count, along with the blocks requested and received for them
+func (download *blockDownload) truncate(count int) {
+	for _, header := range download.headers[count:] {
+		hash := string(header.Hash)
+		download.cancel(hash)
+		delete(download.pending, hash)
+		delete(download.received, hash)
+	}
+	download.headers = download.headers[:count]
+}
+
+// receivedBranch to list the received blocks of the first headers, up to the first block still missing
+func (download *blockDownload) receivedBranch() []*receivedBlock {
+	var branch []*receivedBlock
+	for _, header := range download.headers {
+		received, ok := download.received[string(header.Hash)]
+		if !ok {
+			break
+		}
+		branch = append(branch, received)
+	}
+	return branch
+}
+
+// windowFull to check whether every block of the window is requested or received while headers past it wait
+func (download *blockDownload) windowFull() bool {
+	if len(download.headers) <= downloadWindow {
+		return false
+	}
+	for _, header := range download.headers[:downloadWindow] {
+		hash := string(header.Hash)
+		if download.inFlight[hash] == nil && download.received[hash] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// headerTip to get the last header of the header chain, that of the last block when no headers are pending
+// and nil before the genesis block, the download lock must be held
+func (node *Node) headerTip() *blockchain.BlockHeader {
+	if count := len(node.download.headers); count > 0 {
+		return &node.download.headers[count-1]
+	}
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	if node.chain.LastHash == nil {
+		return nil
+	}
+	block, err := node.chain.GetBlock(node.chain.LastHash)
+	blockchain.PanicHandle(err)
+	header := block.Header()
+	return &header
+}
+
+// forkPoint to find the header a fork of the header chain branches off, among the pending headers or the blocks
+// of the BlockChain, along with how many pending headers it keeps, nil when the hash is in neither, the download
+// lock must be held
+func (node *Node) forkPoint(hash []byte) (*blockchain.BlockHeader, int) {
+	for i := range node.download.headers {
+		if bytes.Equal(node.download.headers[i].Hash, hash) {
+			return &node.download.headers[i], i + 1
+		}
+	}
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	if !node.chain.HasBlock(hash) {
+		return nil, 0
+	}
+	block, err := node.chain.GetBlock(hash)
+	blockchain.PanicHandle(err)
+	header := block.Header()
+	return &header, 0
+}
+
+// tipHeight to get the height of the header, -1 for none
+func tipHeight(header *blockchain.BlockHeader) int {
+	if header == nil {
+		return -1
+	}
+	return header.Height
+}
+
+// requestHeaders to ask the peer for the headers following the header chain, unless it owes some already
+func (node *Node) requestHeaders(p *peer) error {
+	node.downloadLock.Lock()
+	if p.headersRequested {
+		node.downloadLock.Unlock()
+		return nil
+	}
+	p.headersRequested = true
+	var hashes [][]byte
+	for i := len(node.download.headers) - 1; i >= 0; i-- {
+		hashes = append(hashes, node.download.headers[i].Hash)
+	}
+	node.chainLock.Lock()
+	hashes = append(hashes, node.chain.BlockHashes()...)
+	node.chainLock.Unlock()
+	node.downloadLock.Unlock()
+	return p.send(commandGetHeaders, getHeadersMessage{blockchain.Locator(hashes)})
+}
+
+// handleGetHeaders to send the headers following the last block the locator of the peer shares with the BlockChain
+func (node *Node) handleGetHeaders(p *peer, payload []byte) error {
+	var request getHeadersMessage
+	if err := decodePayload(payload, &request); err != nil {
+		return err
+	}
+	if len(request.Locator) > maxLocatorHashes {
+		return fmt.Errorf("locator of %d hashes is too long", len(request.Locator))
+	}
+	node.chainLock.Lock()
+	headers := node.chain.HeadersAfter(request.Locator, maxHeaders)
+	node.chainLock.Unlock()
+	// the answer is sent even when empty, so that the peer knows it is not owed any
+	return p.send(commandHeaders, headersMessage{headers})
+}
+
+// handleHeaders to check the headers of the peer and append those extending the header chain, asking for more
+// after a full batch, headers of a fork replace those past the fork point when they reach higher: every block of
+// a network is mined at the same difficulty, so the highest chain is the one with the most work
+func (node *Node) handleHeaders(p *peer, payload []byte) error {
+	var message headersMessage
+	if err := decodePayload(payload, &message); err != nil {
+		return err
+	}
+	if len(message.Headers) > maxHeaders {
+		return fmt.Errorf("headers of %d items are too long", len(message.Headers))
+	}
+	node.downloadLock.Lock()
+	p.headersRequested = false
+	headers := message.Headers
+	// another peer may have sent the first headers already
+	for len(headers) > 0 && (node.download.pending[string(headers[0].Hash)] || node.hasBlock(headers[0].Hash)) {
+		headers = headers[1:]
+	}
+	if len(headers) > 0 {
+		tip := node.headerTip()
+		kept := len(node.download.headers)
+		switched := false
+		if tip != nil && !bytes.Equal(headers[0].PreviousHash, tip.Hash) {
+			fork, count := node.forkPoint(headers[0].PreviousHash)
+			if fork == nil {
+				node.downloadLock.Unlock()
+				fmt.Printf("HEADERS FROM %s DO NOT EXTEND THE CHAIN.\n", p.address)
+				return nil
+			}
+			// of forks at the same height the one seen first is kept
+			if headers[len(headers)-1].Height <= tip.Height {
+				node.downloadLock.Unlock()
+				fmt.Printf("HEADERS FROM %s BELONG TO A FORK NO HIGHER THAN THE CHAIN.\n", p.address)
+				return nil
+			}
+			tip, kept, switched = fork, count, true
+		}
+		if err := blockchain.CheckHeaders(tip, headers); err != nil {
+			node.downloadLock.Unlock()
+			return err
+		}
+		if switched {
+			fmt.Printf("SWITCHING TO THE FORK OF %s AT HEIGHT %d.\n", p.address, tip.Height)
+		}
+		node.download.truncate(kept)
+		node.download.add(headers)
+		fmt.Printf("HEADERS %d TO %d FROM %s.\n", headers[0].Height, headers[len(headers)-1].Height, p.address)
+	}
+	if count := len(message.Headers); count > 0 && message.Headers[count-1].Height > p.height {
+		p.height = message.Headers[count-1].Height
+	}
+	requests := node.scheduleDownloads()
+	node.downloadLock.Unlock()
+	node.sendRequests(requests)
+	if len(message.Headers) == maxHeaders {
+		return node.requestHeaders(p)
+	}
+	return nil
+}
+
+// scheduleDownloads to assign the blocks of the window neither requested nor received to the peers that have
+// them, those with the fewest requests first, returning the hashes to ask of each, the download lock must be held
+func (node *Node) scheduleDownloads() map[*peer][][]byte {
+	peers := node.readyPeers()
+	requests := make(map[*peer][][]byte)
+	now := time.Now()
+	for i, header := range node.download.headers {
+		if i == downloadWindow {
+			break
+		}
+		hash := string(header.Hash)
+		if node.download.inFlight[hash] != nil || node.download.received[hash] != nil {
+			continue
+		}
+		var best *peer
+		for _, p := range peers {
+			if p.height >= header.Height && p.inFlight < maxBlocksInFlight && p.failures < maxFailures &&
+				(best == nil || p.inFlight < best.inFlight) {
+				best = p
+			}
+		}
+		if best == nil {
+			break
+		}
+		node.download.inFlight[hash] = &blockRequest{best, now}
+		best.inFlight++
+		requests[best] = append(requests[best], header.Hash)
+	}
+	return requests
+}
+
+// sendRequests to ask the peers for the blocks scheduled, a failed write closes the connection whose goroutine
+// then releases its requests
+func (node *Node) sendRequests(requests map[*peer][][]byte) {
+	for p, hashes := range requests {
+		if err := p.send(commandGetData, invMessage{invBlock, hashes}); err != nil {
+			p.conn.Close()
+		}
+	}
+}
+
+// handleBlock to take a block of the header chain from the peer and connect those following the last block
+// in order, blocks not asked for are ignored
+func (node *Node) handleBlock(p *peer, payload []byte) error {
+	var block blockchain.Block
+	if err := decodePayload(payload, &block); err != nil {
+		return err
+	}
+	node.downloadLock.Lock()
+	hash := string(block.Hash)
+	if !node.download.pending[hash] || node.download.received[hash] != nil {
+		node.downloadLock.Unlock()
+		return nil
+	}
+	header := block.Header()
+	if !header.CheckProofOfWork() {
+		node.downloadLock.Unlock()
+		return fmt.Errorf("block %x does not match its header", block.Hash)
+	}
+	node.download.cancel(hash)
+	node.download.received[hash] = &receivedBlock{&block, p}
+	if block.Height > p.height {
+		p.height = block.Height
+	}
+	startHeight, started := node.download.startHeight, node.download.started
+	connected, invalid, err := node.connectBlocks()
+	var tip *blockchain.Block
+	var wantMemPool []*peer
+	if connected != nil && len(node.download.headers) == 0 {
+		tip = connected
+		for _, other := range node.readyPeers() {
+			if other.wantsMemPool {
+				other.wantsMemPool = false
+				wantMemPool = append(wantMemPool, other)
+			}
+		}
+	}
+	requests := node.scheduleDownloads()
+	node.downloadLock.Unlock()
+	node.sendRequests(requests)
+	if invalid != nil {
+		fmt.Printf("DROPPING PEER %s: %v.\n", invalid.address, err)
+		invalid.conn.Close()
+		// the headers are asked for again, from the peers that remain
+		for _, other := range node.readyPeers() {
+			if other != invalid {
+				node.requestHeaders(other)
+			}
+		}
+		return nil
+	}
+	if tip == nil {
+		return nil
+	}
+	if count := tip.Height - startHeight; count > 1 {
+		fmt.Printf("SYNCED %d BLOCKS TO HEIGHT %d IN %s.\n", count, tip.Height, time.Since(started).Round(time.Millisecond))
+	} else {
+		fmt.Printf("BLOCK %x AT HEIGHT %d FROM %s.\n", tip.Hash, tip.Height, p.address)
+	}
+	node.relay(nil, invMessage{invBlock, [][]byte{tip.Hash}})
+	for _, other := range wantMemPool {
+		if err := other.send(commandMemPool, nil); err != nil {
+			other.conn.Close()
+		}
+	}
+	node.wakeMiner()
+	return nil
+}
+
+// connectBlocks to connect the received blocks following the last block, returning the last one connected, or the
+// peer that sent a block that is not valid, upon which the download is reset, the download lock must be held,
+// the blocks of a fork are switched to once they reach past the last block or fill the window
+func (node *Node) connectBlocks() (*blockchain.Block, *peer, error) {
+	var connected *blockchain.Block
+	for len(node.download.headers) > 0 {
+		first, ok := node.download.received[string(node.download.headers[0].Hash)]
+		if !ok {
+			break
+		}
+		branch := []*receivedBlock{first}
+		var count int
+		var err error
+		node.chainLock.Lock()
+		if bytes.Equal(first.block.PreviousHash, node.chain.LastHash) {
+			if err = node.chain.AcceptBlock(first.block); err == nil {
+				count = 1
+				if first.block.Height == 0 {
+					node.genesis = first.block.Hash
+				}
+			}
+		} else {
+			branch = node.download.receivedBranch()
+			height := node.chain.BestHeight()
+			if branch[len(branch)-1].block.Height <= height && len(branch) < downloadWindow {
+				node.chainLock.Unlock()
+				break
+			}
+			var blocks []*blockchain.Block
+			for _, received := range branch {
+				blocks = append(blocks, received.block)
+			}
+			count, err = node.chain.Reorganize(blocks)
+			if err == nil {
+				fmt.Printf("SWITCHED TO THE FORK AT HEIGHT %d, REPLACING %d BLOCKS.\n", first.block.Height-1, height-first.block.Height+1)
+			}
+		}
+		node.chainLock.Unlock()
+		for _, received := range branch[:count] {
+			hash := string(received.block.Hash)
+			delete(node.download.received, hash)
+			delete(node.download.pending, hash)
+			node.download.headers = node.download.headers[1:]
+			connected = received.block
+		}
+		if err != nil {
+			invalid := branch[count]
+			node.download.reset()
+			return connected, invalid.peer, fmt.Errorf("block %x is not valid: %v", invalid.block.Hash, err)
+		}
+	}
+	return connected, nil, nil
+}
+
+// watchDownload to check the download every watchInterval until the context is cancelled: requests that time out are
+// asked of other peers, a peer stalling the download is dropped and progress is reported
+func (node *Node) watchDownload(ctx context.Context) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			node.checkDownload()
+		}
+	}
+}
+
+// checkDownload to retry the requests that timed out, drop the peers failing or stalling and report progress
+func (node *Node) checkDownload() {
+	node.downloadLock.Lock()
+	download := node.download
+	now := time.Now()
+	var dropped []*peer
+	for hash, request := range download.inFlight {
+		if now.Sub(request.sent) < blockTimeout {
+			continue
+		}
+		download.cancel(hash)
+		request.peer.failures++
+		fmt.Printf("BLOCK %x TIMED OUT FROM %s, ASKING ANOTHER PEER.\n", hash, request.peer.address)
+		if request.peer.failures == maxFailures {
+			dropped = append(dropped, request.peer)
+		}
+	}
+	// the next block to connect holds back the download when every other block of the window is requested or received
+	if len(download.headers) > 0 {
+		next := download.headers[0]
+		if request, ok := download.inFlight[string(next.Hash)]; ok && now.Sub(request.sent) >= stallTimeout && download.windowFull() {
+			fmt.Printf("PEER %s IS STALLING THE DOWNLOAD AT HEIGHT %d.\n", request.peer.address, next.Height)
+			request.peer.failures = maxFailures
+			download.release(request.peer)
+			dropped = append(dropped, request.peer)
+		}
+	}
+	requests := node.scheduleDownloads()
+	if len(download.headers) > 0 && now.Sub(download.reported) >= progressInterval {
+		download.reported = now
+		height := download.headers[0].Height - 1
+		target := download.headers[len(download.headers)-1].Height
+		peers := make(map[*peer]bool)
+		for _, request := range download.inFlight {
+			peers[request.peer] = true
+		}
+		fmt.Printf("SYNCING HEIGHT %d OF %d (%.1f%%), %d BLOCKS IN FLIGHT FROM %d PEERS, %.1f BLOCKS/S.\n",
+			height, target, 100*float64(height+1)/float64(target+1), len(download.inFlight), len(peers),
+			float64(height-download.startHeight)/now.Sub(download.started).Seconds())
+	}
+	node.downloadLock.Unlock()
+	for _, p := range dropped {
+		p.conn.Close()
+	}
+	node.sendRequests(requests)
+}
@@ -0,0 +1,328 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+)
+
+// closeRecorder structure for a connection noting whether it was closed
+type closeRecorder struct {
+	net.Conn
+	lock   sync.Mutex
+	closed bool
+}
+
+// Close to note the connection closed before closing it
+func (conn *closeRecorder) Close() error {
+	conn.lock.Lock()
+	conn.closed = true
+	conn.lock.Unlock()
+	return conn.Conn.Close()
+}
+
+// isClosed to check whether the connection was closed
+func (conn *closeRecorder) isClosed() bool {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return conn.closed
+}
+
+// testPeer to create a peer over one end of a pipe whose closing is recorded
+func testPeer(t *testing.T, address string) (*peer, *closeRecorder) {
+	t.Helper()
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+	conn := &closeRecorder{Conn: local}
+	return &peer{conn: conn, address: address}, conn
+}
+
+// testHeaders to make count headers of fake blocks following one another from the height, only their hashes and
+// heights matter to the download
+func testHeaders(height, count int) []blockchain.BlockHeader {
+	var headers []blockchain.BlockHeader
+	var previous []byte
+	for i := height; i < height+count; i++ {
+		index := make([]byte, 8)
+		binary.BigEndian.PutUint64(index, uint64(i))
+		hash := sha256.Sum256(index)
+		headers = append(headers, blockchain.BlockHeader{Hash: hash[:], PreviousHash: previous, Height: i})
+		previous = hash[:]
+	}
+	return headers
+}
+
+// request to note the block of the header as asked of the peer at the time
+func request(download *blockDownload, header blockchain.BlockHeader, p *peer, sent time.Time) {
+	download.inFlight[string(header.Hash)] = &blockRequest{p, sent}
+	p.inFlight++
+}
+
+// testNode to create a Node of a new BlockChain that is not running, so that its download is driven by hand
+func testNode(t *testing.T) *Node {
+	t.Helper()
+	_, address := testWallets(t)
+	return NewNode(newChain(t, address), "")
+}
+
+func TestTruncateDropsForkedHeaders(t *testing.T) {
+	download := newBlockDownload()
+	headers := testHeaders(1, 6)
+	download.add(headers)
+	p, _ := testPeer(t, "peer")
+	request(download, headers[1], p, time.Now())
+	request(download, headers[4], p, time.Now())
+	download.received[string(headers[2].Hash)] = &receivedBlock{&blockchain.Block{Hash: headers[2].Hash}, p}
+	download.received[string(headers[5].Hash)] = &receivedBlock{&blockchain.Block{Hash: headers[5].Hash}, p}
+
+	// a fork branching off the third header keeps the first three
+	download.truncate(3)
+	if len(download.headers) != 3 || !bytes.Equal(download.headers[2].Hash, headers[2].Hash) {
+		t.Fatalf("truncate keeps %d headers, want the first 3", len(download.headers))
+	}
+	for i, header := range headers {
+		hash := string(header.Hash)
+		if kept := i < 3; download.pending[hash] != kept {
+			t.Errorf("header %d is pending %v, want %v", i, download.pending[hash], kept)
+		}
+	}
+	if download.inFlight[string(headers[1].Hash)] == nil || download.inFlight[string(headers[4].Hash)] != nil {
+		t.Error("truncate does not cancel only the requests past the fork point")
+	}
+	if download.received[string(headers[2].Hash)] == nil || download.received[string(headers[5].Hash)] != nil {
+		t.Error("truncate does not drop only the blocks received past the fork point")
+	}
+	if p.inFlight != 1 {
+		t.Errorf("peer has %d blocks in flight, want 1", p.inFlight)
+	}
+	// the fork is appended after the headers kept
+	download.add(testHeaders(4, 2))
+	if len(download.headers) != 5 || download.startHeight != 0 {
+		t.Errorf("download holds %d headers from height %d, want 5 from 0", len(download.headers), download.startHeight)
+	}
+	download.truncate(0)
+	if len(download.headers) != 0 || len(download.pending) != 0 || len(download.inFlight) != 0 || p.inFlight != 0 {
+		t.Error("truncate to no headers leaves some behind")
+	}
+}
+
+func TestWindowFull(t *testing.T) {
+	download := newBlockDownload()
+	headers := testHeaders(1, downloadWindow+1)
+	download.add(headers[:downloadWindow])
+	p, _ := testPeer(t, "peer")
+	for _, header := range headers[:downloadWindow-1] {
+		request(download, header, p, time.Now())
+	}
+	last := headers[downloadWindow-1]
+	download.received[string(last.Hash)] = &receivedBlock{&blockchain.Block{Hash: last.Hash}, p}
+	if download.windowFull() {
+		t.Error("the window is full while no headers wait past it")
+	}
+	download.add(headers[downloadWindow:])
+	if !download.windowFull() {
+		t.Error("the window is not full with every block requested or received and a header past it")
+	}
+	download.cancel(string(headers[downloadWindow/2].Hash))
+	if download.windowFull() {
+		t.Error("the window is full with a block neither requested nor received")
+	}
+}
+
+func TestCheckDownloadDropsFailingPeer(t *testing.T) {
+	node := testNode(t)
+	headers := testHeaders(1, 3)
+	node.download.add(headers)
+	failing, failingConn := testPeer(t, "failing")
+	failing.failures = maxFailures - 1
+	slow, slowConn := testPeer(t, "slow")
+	timedOut := time.Now().Add(-blockTimeout)
+	request(node.download, headers[0], failing, timedOut)
+	request(node.download, headers[1], slow, timedOut)
+	request(node.download, headers[2], slow, time.Now())
+
+	node.checkDownload()
+	if node.download.inFlight[string(headers[0].Hash)] != nil || node.download.inFlight[string(headers[1].Hash)] != nil {
+		t.Error("the requests that timed out are not cancelled")
+	}
+	if node.download.inFlight[string(headers[2].Hash)] == nil {
+		t.Error("the request that did not time out is cancelled")
+	}
+	if failing.failures != maxFailures || !failingConn.isClosed() {
+		t.Errorf("peer letting %d requests time out is not dropped", failing.failures)
+	}
+	if slow.failures != 1 || slow.inFlight != 1 || slowConn.isClosed() {
+		t.Errorf("peer letting one request time out has %d failures and %d blocks in flight, closed %v",
+			slow.failures, slow.inFlight, slowConn.isClosed())
+	}
+}
+
+func TestCheckDownloadDropsStallingPeer(t *testing.T) {
+	node := testNode(t)
+	headers := testHeaders(1, downloadWindow+1)
+	node.download.add(headers)
+	staller, stallerConn := testPeer(t, "staller")
+	other, otherConn := testPeer(t, "other")
+	// the next block was asked of the staller a while ago, the rest of the window just now
+	request(node.download, headers[0], staller, time.Now().Add(-stallTimeout/2))
+	request(node.download, headers[1], staller, time.Now())
+	for _, header := range headers[2:downloadWindow] {
+		request(node.download, header, other, time.Now())
+	}
+
+	node.checkDownload()
+	if stallerConn.isClosed() {
+		t.Fatal("peer is dropped before stallTimeout passes")
+	}
+	node.download.inFlight[string(headers[0].Hash)].sent = time.Now().Add(-stallTimeout)
+	node.checkDownload()
+	if !stallerConn.isClosed() || staller.failures != maxFailures {
+		t.Fatal("peer holding back the full window is not dropped")
+	}
+	if staller.inFlight != 0 || node.download.inFlight[string(headers[0].Hash)] != nil || node.download.inFlight[string(headers[1].Hash)] != nil {
+		t.Error("the requests of the stalling peer are not released")
+	}
+	if otherConn.isClosed() || other.inFlight != downloadWindow-2 {
+		t.Error("the requests of the other peer are touched")
+	}
+}
+
+func TestCheckDownloadKeepsPeerWhenWindowIsNotFull(t *testing.T) {
+	node := testNode(t)
+	headers := testHeaders(1, 10)
+	node.download.add(headers)
+	p, conn := testPeer(t, "peer")
+	request(node.download, headers[0], p, time.Now().Add(-stallTimeout))
+	node.checkDownload()
+	if conn.isClosed() || node.download.inFlight[string(headers[0].Hash)] == nil {
+		t.Error("peer is dropped for a late block while the window has room")
+	}
+}
+
+// withholdingPeer structure for a fake peer serving the headers and blocks of a BlockChain except one block,
+// which it never sends
+type withholdingPeer struct {
+	headers  []blockchain.BlockHeader
+	blocks   map[string]*blockchain.Block
+	withheld []byte
+	lock     sync.Mutex
+	asked    bool
+}
+
+// wasAsked to check whether the withheld block was asked of the peer
+func (fake *withholdingPeer) wasAsked() bool {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.asked
+}
+
+// listen to serve the connections to a free port until the test ends, returning the port
+func (fake *withholdingPeer) listen(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns sync.WaitGroup
+	t.Cleanup(func() {
+		listener.Close()
+		conns.Wait()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				defer conns.Done()
+				defer conn.Close()
+				fake.serve(conn)
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// serve to answer the version, getheaders and getdata messages of the connection until it closes
+func (fake *withholdingPeer) serve(conn net.Conn) {
+	for {
+		command, payload, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		switch command {
+		case commandVersion:
+			tip := fake.headers[len(fake.headers)-1]
+			version := versionMessage{protocolVersion, tip.Height, fake.headers[0].Hash, 1}
+			if writeMessage(conn, commandVersion, version) != nil || writeMessage(conn, commandVerack, nil) != nil {
+				return
+			}
+		case commandGetHeaders:
+			// the node asking starts from no blocks or the genesis block, the fake peer sends them all
+			if writeMessage(conn, commandHeaders, headersMessage{fake.headers}) != nil {
+				return
+			}
+		case commandGetData:
+			var request invMessage
+			if decodePayload(payload, &request) != nil || request.Kind != invBlock {
+				continue
+			}
+			for _, hash := range request.Items {
+				if bytes.Equal(hash, fake.withheld) {
+					fake.lock.Lock()
+					fake.asked = true
+					fake.lock.Unlock()
+					continue
+				}
+				if block, ok := fake.blocks[string(hash)]; ok && writeMessage(conn, commandBlock, block) != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestSyncFromTwoPeersWithOneWithholdingABlock(t *testing.T) {
+	// the timeouts are restored after the nodes stop, the cleanups running in reverse
+	savedTimeout, savedInterval := blockTimeout, watchInterval
+	t.Cleanup(func() { blockTimeout, watchInterval = savedTimeout, savedInterval })
+	blockTimeout, watchInterval = 300*time.Millisecond, 50*time.Millisecond
+
+	_, address := testWallets(t)
+	source := newChain(t, address)
+	const height = 40
+	for i := 0; i < height; i++ {
+		source.GenerateBlock(address)
+	}
+	fake := &withholdingPeer{headers: source.HeadersAfter(nil, maxHeaders), blocks: make(map[string]*blockchain.Block)}
+	for _, block := range source.Blocks() {
+		fake.blocks[string(block.Hash)] = block
+	}
+	fake.withheld = fake.headers[height/2].Hash
+	syncing := newChain(t, "")
+
+	node, port := startNode(t, syncing, "", fake.listen(t))
+	waitFor(t, "the withheld block to be asked of the fake peer", fake.wasAsked)
+	if node.height() >= height/2 {
+		t.Fatalf("the node reached height %d past the withheld block", node.height())
+	}
+	// the honest peer connects once the node waits for the withheld block
+	startNode(t, source, "", port)
+	waitFor(t, "the node to sync", func() bool { return node.height() == height })
+	for _, header := range fake.headers {
+		if block := blockAt(node, header.Height); block == nil || !bytes.Equal(block.Hash, header.Hash) {
+			t.Fatalf("the node holds another block at height %d", header.Height)
+		}
+	}
+}
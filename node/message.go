@@ -0,0 +1,133 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+	"github.com/the-code-innovator/go-blockchain/params"
+)
+
+// constants of the wire protocol, every message is the magic bytes of the network, the command padded
+// with zeros, the length and checksum of the payload and the gob encoded payload
+const (
+	protocolVersion = 2
+	commandLength   = 12
+	checkSumLength  = 4
+	headerLength    = 4 + commandLength + 4 + checkSumLength
+	maxPayload      = 32 << 20
+	// maxInvItems bounds the hashes announced or requested in one message, maxHeaders the headers sent
+	// in one and maxLocatorHashes the hashes of a block locator
+	maxInvItems      = 500
+	maxHeaders       = 2000
+	maxLocatorHashes = 101
+)
+
+// commands of the messages peers exchange
+const (
+	commandVersion    = "version"
+	commandVerack     = "verack"
+	commandInv        = "inv"
+	commandGetData    = "getdata"
+	commandGetHeaders = "getheaders"
+	commandHeaders    = "headers"
+	commandMemPool    = "mempool"
+	commandBlock      = "block"
+	commandTx         = "tx"
+)
+
+// kinds of the items an inv or getdata message lists
+const (
+	invBlock = "block"
+	invTx    = "tx"
+)
+
+// versionMessage structure for the first message of either side of a connection, peers only talk once
+// both have sent theirs and acknowledged the other
+type versionMessage struct {
+	Version int
+	Height  int
+	Genesis []byte
+	// Nonce is drawn once per node so that it notices connecting to itself
+	Nonce uint64
+}
+
+// invMessage structure to announce blocks or transactions by their hashes, and with getdata to request them
+type invMessage struct {
+	Kind  string
+	Items [][]byte
+}
+
+// getHeadersMessage structure to ask for the headers following the last block the locator shares with the peer
+type getHeadersMessage struct {
+	Locator [][]byte
+}
+
+// headersMessage structure for the headers answering a getheaders message, oldest first
+type headersMessage struct {
+	Headers []blockchain.BlockHeader
+}
+
+// writeMessage to frame the gob encoded payload, nil for none, under the command
+func writeMessage(w io.Writer, command string, payload interface{}) error {
+	var encoded bytes.Buffer
+	if payload != nil {
+		if err := gob.NewEncoder(&encoded).Encode(payload); err != nil {
+			return err
+		}
+	}
+	magic := params.Active().Magic
+	header := make([]byte, headerLength)
+	copy(header, magic[:])
+	copy(header[4:4+commandLength], command)
+	binary.BigEndian.PutUint32(header[4+commandLength:], uint32(encoded.Len()))
+	copy(header[headerLength-checkSumLength:], checkSum(encoded.Bytes()))
+	_, err := w.Write(append(header, encoded.Bytes()...))
+	return err
+}
+
+// readMessage to read the next message, failing on the magic bytes of another network or a payload
+// that does not match its checksum
+func readMessage(r io.Reader) (string, []byte, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", nil, err
+	}
+	magic := params.Active().Magic
+	if !bytes.Equal(header[:4], magic[:]) {
+		return "", nil, fmt.Errorf("magic bytes %x are not those of %s", header[:4], params.Active().Name)
+	}
+	command := string(bytes.TrimRight(header[4:4+commandLength], "\x00"))
+	length := binary.BigEndian.Uint32(header[4+commandLength:])
+	if length > maxPayload {
+		return "", nil, fmt.Errorf("%s payload of %d bytes is too long", command, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", nil, err
+	}
+	if !bytes.Equal(checkSum(payload), header[headerLength-checkSumLength:]) {
+		return "", nil, fmt.Errorf("%s payload does not match its checksum", command)
+	}
+	return command, payload, nil
+}
+
+// decodePayload to decode the payload of a message into the value
+func decodePayload(payload []byte, value interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(value); err != nil {
+		return errors.New("payload is not valid")
+	}
+	return nil
+}
+
+// checkSum to compute the first bytes of the double SHA256 of the payload
+func checkSum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:checkSumLength]
+}
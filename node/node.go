@@ -0,0 +1,515 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+)
+
+// timeouts for opening connections to peers
+const (
+	dialTimeout      = 10 * time.Second
+	handshakeTimeout = 30 * time.Second
+)
+
+// Node structure for a node of the network, it serves its BlockChain to its peers, catches up with
+// those ahead of it and relays the blocks and transactions it learns of, mining pending transactions
+// into blocks when it has a miner address
+type Node struct {
+	chain *blockchain.BlockChain
+	// chainLock serialises the use of the BlockChain by the goroutines of the peers
+	chainLock sync.Mutex
+	miner     string
+	nonce     uint64
+	// genesis is the hash of the genesis block, nil until a node started without one downloads it
+	genesis []byte
+	// downloadLock guards the download of the blocks of the header chain, it is taken before the other locks
+	downloadLock sync.Mutex
+	download     *blockDownload
+	peersLock    sync.Mutex
+	peers        map[*peer]bool
+	stopped      bool
+	group        sync.WaitGroup
+	// mining wakes the miner, a wake-up already waiting covers the transactions arriving after it
+	mining chan struct{}
+}
+
+// NewNode to create a Node serving the BlockChain, paying the fees of the blocks it mines to the miner
+// address, which may be empty for a node that does not mine
+func NewNode(chain *blockchain.BlockChain, miner string) *Node {
+	nonce := make([]byte, 8)
+	_, err := rand.Read(nonce)
+	blockchain.PanicHandle(err)
+	return &Node{
+		chain:    chain,
+		miner:    miner,
+		nonce:    binary.BigEndian.Uint64(nonce),
+		genesis:  chain.GenesisHash(),
+		download: newBlockDownload(),
+		peers:    make(map[*peer]bool),
+		mining:   make(chan struct{}, 1),
+	}
+}
+
+// Run to listen for peers on the port and connect to the addresses, serving them until the context is cancelled
+func (node *Node) Run(ctx context.Context, port int, addresses []string) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("NODE LISTENING ON PORT %d AT HEIGHT %d.\n", port, node.height())
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		node.closePeers()
+	}()
+	node.group.Add(1)
+	go func() {
+		defer node.group.Done()
+		node.watchDownload(ctx)
+	}()
+	for _, address := range addresses {
+		node.group.Add(1)
+		go func(address string) {
+			defer node.group.Done()
+			node.Connect(address)
+		}(address)
+	}
+	if node.miner != "" {
+		node.group.Add(1)
+		go func() {
+			defer node.group.Done()
+			node.runMiner(ctx)
+		}()
+		// transactions left waiting since the node last ran are mined at once
+		node.wakeMiner()
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		node.group.Add(1)
+		go func() {
+			defer node.group.Done()
+			node.serve(newPeer(conn, true))
+		}()
+	}
+	node.group.Wait()
+	fmt.Println("NODE STOPPED.")
+	return nil
+}
+
+// Connect to open a connection to the node at the address and serve it until either side closes it
+func (node *Node) Connect(address string) {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		fmt.Printf("CANNOT CONNECT TO %s: %v.\n", address, err)
+		return
+	}
+	node.serve(newPeer(conn, false))
+}
+
+// serve to shake hands with the peer and handle its messages until the connection fails or misbehaves
+func (node *Node) serve(p *peer) {
+	if !node.addPeer(p) {
+		p.conn.Close()
+		return
+	}
+	defer node.removePeer(p)
+	if !p.inbound {
+		if err := p.send(commandVersion, node.versionMessage()); err != nil {
+			return
+		}
+	}
+	p.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	for {
+		command, payload, err := readMessage(p.conn)
+		if err != nil {
+			switch {
+			case node.isStopped():
+			case errors.Is(err, net.ErrClosed):
+				// the node closed the connection itself and said why
+			case err == io.EOF && !p.handshaken():
+				fmt.Printf("PEER %s CLOSED THE CONNECTION BEFORE THE HANDSHAKE.\n", p.address)
+			case err != io.EOF:
+				fmt.Printf("PEER %s FAILED: %v.\n", p.address, err)
+			}
+			return
+		}
+		if err := node.handle(p, command, payload); err != nil {
+			fmt.Printf("DROPPING PEER %s: %v.\n", p.address, err)
+			return
+		}
+	}
+}
+
+// handle to act on a message of the peer, an error drops the peer
+func (node *Node) handle(p *peer, command string, payload []byte) error {
+	if command != commandVersion && command != commandVerack && !p.handshaken() {
+		return fmt.Errorf("%s sent before the handshake", command)
+	}
+	switch command {
+	case commandVersion:
+		return node.handleVersion(p, payload)
+	case commandVerack:
+		return node.handleVerack(p)
+	case commandInv:
+		return node.handleInv(p, payload)
+	case commandGetData:
+		return node.handleGetData(p, payload)
+	case commandGetHeaders:
+		return node.handleGetHeaders(p, payload)
+	case commandHeaders:
+		return node.handleHeaders(p, payload)
+	case commandMemPool:
+		return node.handleMemPool(p)
+	case commandBlock:
+		return node.handleBlock(p, payload)
+	case commandTx:
+		return node.handleTx(p, payload)
+	}
+	// unknown commands are ignored, so that later versions of the protocol can add some
+	return nil
+}
+
+// handleVersion to check the version of the peer, answer it with ours when the peer connected to us,
+// and acknowledge it
+func (node *Node) handleVersion(p *peer, payload []byte) error {
+	if p.version != nil {
+		return errors.New("version sent twice")
+	}
+	var version versionMessage
+	if err := decodePayload(payload, &version); err != nil {
+		return err
+	}
+	if version.Nonce == node.nonce {
+		return errors.New("connected to itself")
+	}
+	if version.Version < protocolVersion {
+		return fmt.Errorf("protocol version %d is too old", version.Version)
+	}
+	// a node without a genesis block yet takes the chain of its peers
+	if genesis := node.genesisHash(); genesis != nil && version.Genesis != nil && !bytes.Equal(version.Genesis, genesis) {
+		return errors.New("its chain starts from another genesis block")
+	}
+	p.version = &version
+	if p.inbound {
+		if err := p.send(commandVersion, node.versionMessage()); err != nil {
+			return err
+		}
+	}
+	if err := p.send(commandVerack, nil); err != nil {
+		return err
+	}
+	return node.checkHandshake(p)
+}
+
+// handleVerack to note that the peer acknowledged our version
+func (node *Node) handleVerack(p *peer) error {
+	if p.acknowledged {
+		return errors.New("verack sent twice")
+	}
+	p.acknowledged = true
+	return node.checkHandshake(p)
+}
+
+// checkHandshake to start talking to the peer once the handshake completes: headers are asked for when the
+// peer is ahead, and its pending transactions once the node has caught up
+func (node *Node) checkHandshake(p *peer) error {
+	if !p.handshaken() {
+		return nil
+	}
+	p.conn.SetReadDeadline(time.Time{})
+	node.peersLock.Lock()
+	p.ready = true
+	node.peersLock.Unlock()
+	fmt.Printf("CONNECTED TO PEER %s AT HEIGHT %d.\n", p.address, p.version.Height)
+	node.downloadLock.Lock()
+	p.height = p.version.Height
+	ahead := p.height > tipHeight(node.headerTip())
+	p.wantsMemPool = ahead
+	node.downloadLock.Unlock()
+	if ahead {
+		return node.requestHeaders(p)
+	}
+	return p.send(commandMemPool, nil)
+}
+
+// handleMemPool to announce the pending transactions to the peer
+func (node *Node) handleMemPool(p *peer) error {
+	node.chainLock.Lock()
+	var pending [][]byte
+	for _, entry := range node.chain.TxPool().Entries() {
+		pending = append(pending, entry.Transaction.ID)
+	}
+	node.chainLock.Unlock()
+	for len(pending) > 0 {
+		count := len(pending)
+		if count > maxInvItems {
+			count = maxInvItems
+		}
+		if err := p.send(commandInv, invMessage{invTx, pending[:count]}); err != nil {
+			return err
+		}
+		pending = pending[count:]
+	}
+	return nil
+}
+
+// handleInv to ask for the headers of announced blocks and for the announced transactions the node does not have
+func (node *Node) handleInv(p *peer, payload []byte) error {
+	var inv invMessage
+	if err := decodePayload(payload, &inv); err != nil {
+		return err
+	}
+	if len(inv.Items) > maxInvItems {
+		return fmt.Errorf("inv of %d items is too long", len(inv.Items))
+	}
+	switch inv.Kind {
+	case invBlock:
+		node.downloadLock.Lock()
+		unknown := false
+		for _, hash := range inv.Items {
+			if !node.download.pending[string(hash)] && !node.hasBlock(hash) {
+				unknown = true
+			}
+		}
+		node.downloadLock.Unlock()
+		// new blocks are downloaded like the others, once their headers extend the header chain
+		if unknown {
+			return node.requestHeaders(p)
+		}
+	case invTx:
+		var wanted [][]byte
+		node.chainLock.Lock()
+		for _, hash := range inv.Items {
+			if _, ok := node.chain.TxPool().Get(hash); !ok {
+				wanted = append(wanted, hash)
+			}
+		}
+		node.chainLock.Unlock()
+		if len(wanted) > 0 {
+			return p.send(commandGetData, invMessage{invTx, wanted})
+		}
+	}
+	return nil
+}
+
+// handleGetData to send the requested blocks and transactions, those the node does not have are skipped
+func (node *Node) handleGetData(p *peer, payload []byte) error {
+	var request invMessage
+	if err := decodePayload(payload, &request); err != nil {
+		return err
+	}
+	if len(request.Items) > maxInvItems {
+		return fmt.Errorf("getdata of %d items is too long", len(request.Items))
+	}
+	for _, hash := range request.Items {
+		node.chainLock.Lock()
+		var command string
+		var item interface{}
+		switch request.Kind {
+		case invBlock:
+			if block, err := node.chain.GetBlock(hash); err == nil {
+				command, item = commandBlock, block
+			}
+		case invTx:
+			if entry, ok := node.chain.TxPool().Get(hash); ok {
+				command, item = commandTx, entry.Transaction
+			}
+		}
+		node.chainLock.Unlock()
+		if item == nil {
+			continue
+		}
+		if err := p.send(command, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleTx to add a transaction of the peer to the pool and relay it, a transaction the pool refuses
+// may merely be mined or replaced already and is ignored
+func (node *Node) handleTx(p *peer, payload []byte) error {
+	var tx blockchain.Transaction
+	if err := decodePayload(payload, &tx); err != nil {
+		return err
+	}
+	node.chainLock.Lock()
+	err := node.chain.TxPool().Add(&tx)
+	node.chainLock.Unlock()
+	if err != nil {
+		fmt.Printf("IGNORED TRANSACTION %x FROM %s: %v.\n", tx.ID, p.address, err)
+		return nil
+	}
+	fmt.Printf("TRANSACTION %x FROM %s.\n", tx.ID, p.address)
+	node.relay(p, invMessage{invTx, [][]byte{tx.ID}})
+	node.wakeMiner()
+	return nil
+}
+
+// wakeMiner to have the miner look at the pending transactions, without waiting for it
+func (node *Node) wakeMiner() {
+	if node.miner == "" {
+		return
+	}
+	select {
+	case node.mining <- struct{}{}:
+	default:
+	}
+}
+
+// runMiner to mine whenever the miner is woken, off the goroutines of the peers, until the context is cancelled
+func (node *Node) runMiner(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-node.mining:
+			if err := node.mine(); err != nil {
+				fmt.Printf("NOT MINED: %v.\n", err)
+			}
+		}
+	}
+}
+
+// mine to mine the pending transactions into a block paying the subsidy and their fees to the miner address and
+// announce it, not while the node is catching up with its peers: the block is laid out under the locks and its proof
+// of work runs without them, a block the chain moved past meanwhile is dropped
+func (node *Node) mine() error {
+	node.downloadLock.Lock()
+	node.chainLock.Lock()
+	var block *blockchain.Block
+	var err error
+	if len(node.download.headers) == 0 && node.chain.LastHash != nil {
+		block, err = node.chain.BlockTemplate(node.miner, blockchain.MaxBlockSize)
+	}
+	node.chainLock.Unlock()
+	node.downloadLock.Unlock()
+	// a block of its coinbase alone is left to the peers
+	if err != nil || block == nil || len(block.Transactions) == 1 {
+		return err
+	}
+	block.Mine()
+	node.downloadLock.Lock()
+	node.chainLock.Lock()
+	err = node.chain.AcceptBlock(block)
+	node.chainLock.Unlock()
+	node.downloadLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("block %x: %v", block.Hash, err)
+	}
+	fmt.Printf("MINED BLOCK %x AT HEIGHT %d WITH %d TRANSACTIONS.\n", block.Hash, block.Height, len(block.Transactions))
+	node.relay(nil, invMessage{invBlock, [][]byte{block.Hash}})
+	return nil
+}
+
+// relay to announce the items to every peer that completed the handshake but the one they came from
+func (node *Node) relay(source *peer, inv invMessage) {
+	for _, p := range node.readyPeers() {
+		if p == source {
+			continue
+		}
+		// a failed write closes the connection, which its own goroutine notices
+		if err := p.send(commandInv, inv); err != nil {
+			p.conn.Close()
+		}
+	}
+}
+
+// readyPeers to list the peers that completed the handshake
+func (node *Node) readyPeers() []*peer {
+	node.peersLock.Lock()
+	defer node.peersLock.Unlock()
+	var ready []*peer
+	for p := range node.peers {
+		if p.ready {
+			ready = append(ready, p)
+		}
+	}
+	return ready
+}
+
+// versionMessage to describe the node to a peer
+func (node *Node) versionMessage() versionMessage {
+	return versionMessage{protocolVersion, node.height(), node.genesisHash(), node.nonce}
+}
+
+// height to get the height of the last block, -1 before the genesis block
+func (node *Node) height() int {
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	return node.chain.BestHeight()
+}
+
+// genesisHash to get the hash of the genesis block, nil before it is downloaded
+func (node *Node) genesisHash() []byte {
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	return node.genesis
+}
+
+// hasBlock to check whether the block of the hash is in the BlockChain
+func (node *Node) hasBlock(hash []byte) bool {
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	return node.chain.HasBlock(hash)
+}
+
+// addPeer to track the peer, false once the node is stopping
+func (node *Node) addPeer(p *peer) bool {
+	node.peersLock.Lock()
+	defer node.peersLock.Unlock()
+	if node.stopped {
+		return false
+	}
+	node.peers[p] = true
+	return true
+}
+
+// removePeer to forget the peer and close its connection, the blocks it was sending are asked of others
+func (node *Node) removePeer(p *peer) {
+	node.downloadLock.Lock()
+	node.download.release(p)
+	node.peersLock.Lock()
+	delete(node.peers, p)
+	node.peersLock.Unlock()
+	requests := node.scheduleDownloads()
+	node.downloadLock.Unlock()
+	p.conn.Close()
+	if p.handshaken() {
+		fmt.Printf("DISCONNECTED FROM PEER %s.\n", p.address)
+	}
+	node.sendRequests(requests)
+}
+
+// closePeers to close the connection of every peer and refuse new ones
+func (node *Node) closePeers() {
+	node.peersLock.Lock()
+	defer node.peersLock.Unlock()
+	node.stopped = true
+	for p := range node.peers {
+		p.conn.Close()
+	}
+}
+
+// isStopped to check whether the node is stopping
+func (node *Node) isStopped() bool {
+	node.peersLock.Lock()
+	defer node.peersLock.Unlock()
+	return node.stopped
+}
@@ -0,0 +1,167 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/the-code-innovator/go-blockchain/blockchain"
+	"github.com/the-code-innovator/go-blockchain/params"
+	"github.com/the-code-innovator/go-blockchain/wallet"
+)
+
+// propagationTimeout is how long a test waits for an item to reach another node
+const propagationTimeout = 10 * time.Second
+
+// testWallets to create wallets in memory holding a new key
+func testWallets(t *testing.T) (*wallet.Wallets, string) {
+	t.Helper()
+	if err := params.SetNetwork("regtest"); err != nil {
+		t.Fatal(err)
+	}
+	w := wallet.MakeWallet(wallet.P256)
+	address := string(w.Address())
+	return &wallet.Wallets{Wallets: map[string]*wallet.Wallet{address: w}}, address
+}
+
+// newChain to create a BlockChain in a temporary directory whose genesis Block pays the address,
+// or an empty one to download from peers when the address is empty
+func newChain(t *testing.T, address string) *blockchain.BlockChain {
+	t.Helper()
+	blockchain.SetDataDir(t.TempDir())
+	var chain *blockchain.BlockChain
+	if address == "" {
+		chain = blockchain.OpenBlockChain()
+	} else {
+		chain = blockchain.InitBlockChain(address)
+	}
+	t.Cleanup(func() { chain.DataBase.Close() })
+	return chain
+}
+
+// freePort to find a port of the loopback interface no one listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// startNode to run a Node of the BlockChain on a free port connected to the ports, it stops when the test ends
+func startNode(t *testing.T, chain *blockchain.BlockChain, miner string, ports ...int) (*Node, int) {
+	t.Helper()
+	node := NewNode(chain, miner)
+	port := freePort(t)
+	var addresses []string
+	for _, peerPort := range ports {
+		addresses = append(addresses, fmt.Sprintf("127.0.0.1:%d", peerPort))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Run(ctx, port, addresses)
+	}()
+	// cleanups run in reverse, so the node stops before its BlockChain closes
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("node on port %d: %v", port, err)
+		}
+	})
+	// the listener is up once a connection to it succeeds
+	waitFor(t, fmt.Sprintf("node on port %d to listen", port), func() bool {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	})
+	return node, port
+}
+
+// waitFor to poll the condition until it holds, failing the test once propagationTimeout passes
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(propagationTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// inPool to check whether the transaction waits in the pool of the node
+func inPool(node *Node, txID []byte) bool {
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	_, ok := node.chain.TxPool().Get(txID)
+	return ok
+}
+
+// blockAt to get the block of the node at the height, nil above its last block
+func blockAt(node *Node, height int) *blockchain.Block {
+	node.chainLock.Lock()
+	defer node.chainLock.Unlock()
+	for _, block := range node.chain.Blocks() {
+		if block.Height == height {
+			return block
+		}
+	}
+	return nil
+}
+
+// sendFrom to pay the amount from the address of the wallets to the address to through the pool of the node,
+// announcing the transaction to its peers
+func sendFrom(t *testing.T, node *Node, wallets *wallet.Wallets, from, to string, amount int) *blockchain.Transaction {
+	t.Helper()
+	node.chainLock.Lock()
+	raw, err := blockchain.CreateRawTransaction(from, []blockchain.Payment{{Address: to, Amount: amount}}, "", blockchain.TxOptions{Fee: 1}, node.chain)
+	if err == nil {
+		_, err = raw.Sign(wallets)
+	}
+	if err == nil {
+		err = node.chain.TxPool().Add(&raw.Transaction)
+	}
+	node.chainLock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.relay(nil, invMessage{invTx, [][]byte{raw.Transaction.ID}})
+	return &raw.Transaction
+}
+
+func TestTransactionAndBlockPropagate(t *testing.T) {
+	wallets, address := testWallets(t)
+	_, payee := testWallets(t)
+	miner, minerPort := startNode(t, newChain(t, address), address)
+	peer, _ := startNode(t, newChain(t, ""), "", minerPort)
+	// the peer starts without a genesis block and downloads it
+	waitFor(t, "the peer to download the genesis block", func() bool { return peer.height() == 0 })
+	if !bytes.Equal(peer.genesisHash(), miner.genesisHash()) {
+		t.Fatal("the peer downloaded another genesis block")
+	}
+
+	tx := sendFrom(t, miner, wallets, address, payee, 10)
+	waitFor(t, "the transaction to reach the peer", func() bool { return inPool(peer, tx.ID) })
+
+	miner.wakeMiner()
+	waitFor(t, "the mined block to reach the peer", func() bool { return peer.height() == 1 })
+	block := blockAt(peer, 1)
+	if block == nil || len(block.Transactions) != 2 || !bytes.Equal(block.Transactions[1].ID, tx.ID) {
+		t.Fatalf("block at height 1 of the peer does not mine the transaction: %+v", block)
+	}
+	if !bytes.Equal(block.Hash, blockAt(miner, 1).Hash) {
+		t.Error("the peer holds another block at height 1 than the miner")
+	}
+	if inPool(peer, tx.ID) {
+		t.Error("the mined transaction is still waiting in the pool of the peer")
+	}
+}
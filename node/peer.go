@@ -0,0 +1,53 @@
+package node
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// writeTimeout is how long a message may take to reach a peer before the connection is given up
+const writeTimeout = 30 * time.Second
+
+// peer structure for a connection to another node, its fields other than ready are only used by the
+// goroutine reading from the connection
+type peer struct {
+	conn    net.Conn
+	address string
+	inbound bool
+	// version is the version message of the peer and acknowledged whether it acknowledged ours
+	version      *versionMessage
+	acknowledged bool
+	// the fields below are guarded by the download lock of the Node: height is the highest block height
+	// the peer is known to have, inFlight counts the blocks asked of it that have not arrived, failures
+	// the requests it let time out, headersRequested whether it owes headers and wantsMemPool whether
+	// its pending transactions are asked for once the node has caught up
+	height           int
+	inFlight         int
+	failures         int
+	headersRequested bool
+	wantsMemPool     bool
+	// ready is set under the peers lock of the Node once the handshake completes
+	ready     bool
+	writeLock sync.Mutex
+}
+
+// newPeer to wrap a connection to another node
+func newPeer(conn net.Conn, inbound bool) *peer {
+	return &peer{conn: conn, address: conn.RemoteAddr().String(), inbound: inbound}
+}
+
+// handshaken to check whether both sides have sent their version and acknowledged the other
+func (p *peer) handshaken() bool {
+	return p.version != nil && p.acknowledged
+}
+
+// send to write a message to the peer, safe to call from any goroutine
+func (p *peer) send(command string, payload interface{}) error {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+	if err := p.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return writeMessage(p.conn, command, payload)
+}
@@ -0,0 +1,93 @@
+package params
+
+import (
+	"errors"
+	"strings"
+)
+
+// ChainParams structure for everything that tells one network apart from another: the version bytes and
+// Bech32 human readable part of its addresses, its genesis block, how hard its blocks are to mine and pay,
+// and how its nodes meet; an OnDemand network is for tests, its blocks are generated at will and can be
+// stamped with any time
+type ChainParams struct {
+	Name              string
+	AddressVersion    byte
+	ScriptHashVersion byte
+	Bech32HRP         string
+	GenesisMessage    string
+	Difficulty        int
+	Subsidy           int
+	Port              int
+	Magic             [4]byte
+	OnDemand          bool
+}
+
+// the networks a node can run on, their ports, magic bytes and Bech32 human readable parts are their own, so
+// that their nodes never mistake a Bitcoin node for a peer and their addresses are never valid Bitcoin addresses
+var (
+	Mainnet = ChainParams{
+		Name:              "mainnet",
+		AddressVersion:    0x00,
+		ScriptHashVersion: 0x05,
+		Bech32HRP:         "gbc",
+		GenesisMessage:    "FIRST TRANSACTION FROM GENESIS.",
+		Difficulty:        18,
+		Subsidy:           100,
+		Port:              9733,
+		Magic:             [4]byte{0xd4, 0x9c, 0xb1, 0xe7},
+	}
+	Testnet = ChainParams{
+		Name:              "testnet",
+		AddressVersion:    0x6f,
+		ScriptHashVersion: 0xc4,
+		Bech32HRP:         "tgbc",
+		GenesisMessage:    "FIRST TRANSACTION FROM TESTNET GENESIS.",
+		Difficulty:        16,
+		Subsidy:           100,
+		Port:              19733,
+		Magic:             [4]byte{0x1b, 0xc7, 0x04, 0x8e},
+	}
+	Regtest = ChainParams{
+		Name:              "regtest",
+		AddressVersion:    0x6f,
+		ScriptHashVersion: 0xc4,
+		Bech32HRP:         "gbcrt",
+		GenesisMessage:    "FIRST TRANSACTION FROM REGTEST GENESIS.",
+		Difficulty:        1,
+		Subsidy:           100,
+		Port:              19744,
+		Magic:             [4]byte{0xe2, 0xa5, 0xc8, 0xf3},
+		OnDemand:          true,
+	}
+)
+
+// Networks lists the networks understood by SetNetwork
+var Networks = []*ChainParams{&Mainnet, &Testnet, &Regtest}
+
+// active is the network the process runs on
+var active = &Mainnet
+
+// Active to get the parameters of the network the process runs on
+func Active() *ChainParams {
+	return active
+}
+
+// NetworkNames to list the names of the networks
+func NetworkNames() []string {
+	var names []string
+	for _, network := range Networks {
+		names = append(names, network.Name)
+	}
+	return names
+}
+
+// SetNetwork to run the process on the network of the name
+func SetNetwork(name string) error {
+	for _, network := range Networks {
+		if network.Name == strings.ToLower(name) {
+			active = network
+			return nil
+		}
+	}
+	return errors.New("unknown network " + name + ", use one of " + strings.Join(NetworkNames(), ", "))
+}
@@ -0,0 +1,413 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// limits keeping the cost of running a script bounded
+const (
+	MaxScriptSize   = 10000
+	MaxElementSize  = 520
+	MaxStackSize    = 1000
+	MaxSteps        = 1000
+	MaxOps          = 201
+	MaxMultisigKeys = 16
+	// maxNumberLength bounds numeric operands, five bytes hold any lock time
+	maxNumberLength = 5
+)
+
+// errors reported while running a script
+var (
+	ErrScriptFailed   = errors.New("script evaluated to false")
+	ErrUnspendable    = errors.New("script is provably unspendable")
+	ErrStackUnderflow = errors.New("stack underflow")
+)
+
+// Checker interface for the checks a script delegates to the transaction being verified
+type Checker interface {
+	// CheckSignature to verify the signature over the transaction with the public key
+	CheckSignature(signature, publicKey []byte) bool
+	// CheckLockTime to check that the transaction cannot be mined before the lock time
+	CheckLockTime(lockTime int64) bool
+}
+
+// Engine structure for running scripts against a stack
+type Engine struct {
+	stack      [][]byte
+	conditions []bool
+	checker    Checker
+	steps      int
+	ops        int
+}
+
+// NewEngine to create an Engine delegating transaction checks to the checker
+func NewEngine(checker Checker) *Engine {
+	return &Engine{checker: checker}
+}
+
+// Verify to run the ScriptSig of an input followed by the ScriptPubKey of the output it spends,
+// a pay to script hash ScriptPubKey also runs the redeem script pushed last by the ScriptSig
+func Verify(scriptSig, scriptPubKey Script, checker Checker) error {
+	if !scriptSig.IsPushOnly() {
+		return errors.New("script sig is not push only")
+	}
+	engine := NewEngine(checker)
+	if err := engine.Run(scriptSig); err != nil {
+		return err
+	}
+	pushed := engine.copyStack()
+	if err := engine.Run(scriptPubKey); err != nil {
+		return err
+	}
+	if !engine.succeeded() {
+		return ErrScriptFailed
+	}
+	if _, ok := scriptPubKey.ScriptHash(); !ok {
+		return nil
+	}
+	if len(pushed) == 0 {
+		return ErrStackUnderflow
+	}
+	redeem := Script(pushed[len(pushed)-1])
+	redeemEngine := NewEngine(checker)
+	redeemEngine.stack = pushed[:len(pushed)-1]
+	if err := redeemEngine.Run(redeem); err != nil {
+		return err
+	}
+	if !redeemEngine.succeeded() {
+		return ErrScriptFailed
+	}
+	return nil
+}
+
+// Run to execute the script on the stack of the Engine
+func (engine *Engine) Run(script Script) error {
+	if len(script) > MaxScriptSize {
+		return errors.New("script is too large")
+	}
+	instructions, err := script.Parse()
+	if err != nil {
+		return err
+	}
+	engine.steps, engine.ops, engine.conditions = 0, 0, nil
+	for _, instruction := range instructions {
+		engine.steps++
+		if engine.steps > MaxSteps {
+			return errors.New("script takes too many steps")
+		}
+		if !instruction.Opcode.isPush() {
+			engine.ops++
+			if engine.ops > MaxOps {
+				return errors.New("script runs too many operations")
+			}
+		}
+		if err := engine.branch(instruction.Opcode); err != nil {
+			return err
+		}
+		if !engine.executing() || isConditional(instruction.Opcode) {
+			continue
+		}
+		if err := engine.step(instruction); err != nil {
+			return err
+		}
+		if len(engine.stack) > MaxStackSize {
+			return errors.New("stack is too large")
+		}
+	}
+	if len(engine.conditions) > 0 {
+		return errors.New("IF without ENDIF")
+	}
+	return nil
+}
+
+// isConditional to check whether the opcode opens, switches or closes a branch
+func isConditional(op Opcode) bool {
+	return op == OpIf || op == OpElse || op == OpEndIf
+}
+
+// executing to check whether every open branch is taken
+func (engine *Engine) executing() bool {
+	for _, condition := range engine.conditions {
+		if !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// branch to open, switch or close a branch, IF takes its branch when the top of the stack is
+// true and ELSE takes the other one, branches inside a branch not taken are never taken
+func (engine *Engine) branch(op Opcode) error {
+	switch op {
+	case OpIf:
+		condition := false
+		if engine.executing() {
+			top, err := engine.pop()
+			if err != nil {
+				return err
+			}
+			condition = isTrue(top)
+		}
+		engine.conditions = append(engine.conditions, condition)
+	case OpElse:
+		if len(engine.conditions) == 0 {
+			return errors.New("ELSE without IF")
+		}
+		last := len(engine.conditions) - 1
+		engine.conditions[last] = !engine.conditions[last]
+	case OpEndIf:
+		if len(engine.conditions) == 0 {
+			return errors.New("ENDIF without IF")
+		}
+		engine.conditions = engine.conditions[:len(engine.conditions)-1]
+	}
+	return nil
+}
+
+// step to execute a single instruction
+func (engine *Engine) step(instruction Instruction) error {
+	op := instruction.Opcode
+	if op.isPush() {
+		data := instruction.push()
+		if len(data) > MaxElementSize {
+			return errors.New("pushed data is too large")
+		}
+		engine.push(data)
+		return nil
+	}
+	switch op {
+	case OpReturn:
+		return ErrUnspendable
+	case OpVerify:
+		top, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		if !isTrue(top) {
+			return ErrScriptFailed
+		}
+	case OpDrop:
+		if _, err := engine.pop(); err != nil {
+			return err
+		}
+	case OpDup:
+		top, err := engine.peek()
+		if err != nil {
+			return err
+		}
+		engine.push(top)
+	case OpSha256:
+		top, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		engine.push(hash[:])
+	case OpHash160:
+		top, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		engine.push(Hash160(top))
+	case OpEqual, OpEqualVerify:
+		first, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		second, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(first, second)
+		if op == OpEqualVerify {
+			if !equal {
+				return ErrScriptFailed
+			}
+			return nil
+		}
+		engine.push(boolean(equal))
+	case OpCheckSig:
+		publicKey, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := engine.pop()
+		if err != nil {
+			return err
+		}
+		engine.push(boolean(len(signature) > 0 && engine.checker.CheckSignature(signature, publicKey)))
+	case OpCheckMultiSig:
+		return engine.checkMultiSig()
+	case OpCheckLockTimeVerify:
+		top, err := engine.peek()
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeNumber(top)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 || !engine.checker.CheckLockTime(lockTime) {
+			return errors.New("lock time has not been reached")
+		}
+	default:
+		return fmt.Errorf("unknown opcode 0x%02x", byte(op))
+	}
+	return nil
+}
+
+// checkMultiSig to pop n, n public keys, m and then one signature slot per public key,
+// pushing true when at least m slots are filled and every filled slot holds a valid
+// signature by its public key, empty slots let co-signers sign one after another
+func (engine *Engine) checkMultiSig() error {
+	count, err := engine.popNumber()
+	if err != nil {
+		return err
+	}
+	if count < 1 || count > MaxMultisigKeys {
+		return errors.New("multisig key count is out of range")
+	}
+	engine.ops += int(count)
+	if engine.ops > MaxOps {
+		return errors.New("script runs too many operations")
+	}
+	publicKeys := make([][]byte, count)
+	for i := count - 1; i >= 0; i-- {
+		if publicKeys[i], err = engine.pop(); err != nil {
+			return err
+		}
+	}
+	required, err := engine.popNumber()
+	if err != nil {
+		return err
+	}
+	if required < 1 || required > count {
+		return errors.New("multisig required count is out of range")
+	}
+	signatures := make([][]byte, count)
+	for i := count - 1; i >= 0; i-- {
+		if signatures[i], err = engine.pop(); err != nil {
+			return err
+		}
+	}
+	valid := int64(0)
+	for i, signature := range signatures {
+		if len(signature) == 0 {
+			continue
+		}
+		if !engine.checker.CheckSignature(signature, publicKeys[i]) {
+			engine.push(boolean(false))
+			return nil
+		}
+		valid++
+	}
+	engine.push(boolean(valid >= required))
+	return nil
+}
+
+// push to push data on the stack
+func (engine *Engine) push(data []byte) {
+	engine.stack = append(engine.stack, data)
+}
+
+// pop to take the top of the stack
+func (engine *Engine) pop() ([]byte, error) {
+	if len(engine.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	top := engine.stack[len(engine.stack)-1]
+	engine.stack = engine.stack[:len(engine.stack)-1]
+	return top, nil
+}
+
+// peek to read the top of the stack without taking it
+func (engine *Engine) peek() ([]byte, error) {
+	if len(engine.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return engine.stack[len(engine.stack)-1], nil
+}
+
+// popNumber to take the top of the stack as a number
+func (engine *Engine) popNumber() (int64, error) {
+	top, err := engine.pop()
+	if err != nil {
+		return 0, err
+	}
+	return decodeNumber(top)
+}
+
+// copyStack to copy the stack, so that a redeem script can run on what the ScriptSig pushed
+func (engine *Engine) copyStack() [][]byte {
+	return append([][]byte{}, engine.stack...)
+}
+
+// succeeded to check that the script left true on top of the stack
+func (engine *Engine) succeeded() bool {
+	top, err := engine.peek()
+	return err == nil && isTrue(top)
+}
+
+// isTrue to interpret stack data as a boolean, any non zero byte is true
+func isTrue(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// boolean to push a boolean as stack data
+func boolean(value bool) []byte {
+	if value {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+// encodeNumber to encode a number little endian with the sign in the top bit of the last byte
+func encodeNumber(number int64) []byte {
+	if number == 0 {
+		return []byte{}
+	}
+	negative := number < 0
+	if negative {
+		number = -number
+	}
+	var encoded []byte
+	for number > 0 {
+		encoded = append(encoded, byte(number&0xff))
+		number >>= 8
+	}
+	if encoded[len(encoded)-1]&0x80 != 0 {
+		encoded = append(encoded, 0)
+	}
+	if negative {
+		encoded[len(encoded)-1] |= 0x80
+	}
+	return encoded
+}
+
+// decodeNumber to decode a number encoded by encodeNumber
+func decodeNumber(encoded []byte) (int64, error) {
+	if len(encoded) > maxNumberLength {
+		return 0, errors.New("number is too long")
+	}
+	if len(encoded) == 0 {
+		return 0, nil
+	}
+	var number int64
+	for i, b := range encoded {
+		number |= int64(b) << (8 * uint(i))
+	}
+	last := encoded[len(encoded)-1]
+	if last&0x80 != 0 {
+		number &^= int64(0x80) << (8 * uint(len(encoded)-1))
+		return -number, nil
+	}
+	return number, nil
+}
@@ -0,0 +1,299 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testChecker structure for a Checker whose signatures are the public key they sign with behind a prefix,
+// and whose transaction has the lock time
+type testChecker struct {
+	lockTime int64
+	checked  []int64
+}
+
+// CheckSignature to accept the signature made by sign with the public key
+func (checker *testChecker) CheckSignature(signature, publicKey []byte) bool {
+	return bytes.Equal(signature, sign(publicKey))
+}
+
+// CheckLockTime to note the lock time and accept it up to that of the transaction
+func (checker *testChecker) CheckLockTime(lockTime int64) bool {
+	checker.checked = append(checker.checked, lockTime)
+	return lockTime <= checker.lockTime
+}
+
+// sign to make the signature the testChecker accepts for the public key
+func sign(publicKey []byte) []byte {
+	return append([]byte("signed by "), publicKey...)
+}
+
+// testKeys to make count distinct public keys
+func testKeys(count int) [][]byte {
+	var keys [][]byte
+	for i := 0; i < count; i++ {
+		keys = append(keys, bytes.Repeat([]byte{byte('a' + i)}, 33))
+	}
+	return keys
+}
+
+// repeat to append the opcode count times
+func (script Script) repeat(op Opcode, count int) Script {
+	for i := 0; i < count; i++ {
+		script = script.AddOp(op)
+	}
+	return script
+}
+
+// pushes to build a script of exactly size bytes out of pushes no larger than MaxElementSize
+func pushes(t *testing.T, size int) Script {
+	t.Helper()
+	var script Script
+	for size-len(script) >= MaxElementSize+3 {
+		script = script.AddData(make([]byte, MaxElementSize))
+	}
+	if rest := size - len(script); rest > 0 {
+		script = script.AddData(make([]byte, rest-1))
+	}
+	if len(script) != size {
+		t.Fatalf("script is %d bytes, want %d", len(script), size)
+	}
+	return script
+}
+
+// engineTest structure for a script run by itself, passing when it returns an error holding err, or
+// when err is empty and it leaves a value on the stack that is true as wanted
+type engineTest struct {
+	name   string
+	script Script
+	err    string
+	top    bool
+}
+
+// runEngineTests to run the scripts of the tests with the checker
+func runEngineTests(t *testing.T, checker Checker, tests []engineTest) {
+	t.Helper()
+	for _, test := range tests {
+		engine := NewEngine(checker)
+		err := engine.Run(test.script)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error is %v, want %q", test.name, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case engine.succeeded() != test.top:
+			t.Errorf("%s: top of the stack is %v, want %v", test.name, engine.succeeded(), test.top)
+		}
+	}
+}
+
+func TestTemplateDetection(t *testing.T) {
+	keys := testKeys(3)
+	hash := Hash160(keys[0])
+	if got, ok := PayToPublicKeyHash(hash).PublicKeyHash(); !ok || !bytes.Equal(got, hash) {
+		t.Error("pay to public key hash is not detected")
+	}
+	if got, ok := PayToScriptHash(hash).ScriptHash(); !ok || !bytes.Equal(got, hash) {
+		t.Error("pay to script hash is not detected")
+	}
+	tests := []struct {
+		name   string
+		script Script
+	}{
+		{"pay to script hash", PayToScriptHash(hash)},
+		{"pay to public key", PayToPublicKey(keys[0])},
+		{"short hash", PayToPublicKeyHash(hash[:19])},
+		{"long hash", PayToPublicKeyHash(append(hash, 0))},
+		{"CHECKSIG missing", PayToPublicKeyHash(hash)[:24]},
+		{"EQUAL instead of EQUALVERIFY", Script{}.AddOp(OpDup).AddOp(OpHash160).AddData(hash).AddOp(OpEqual).AddOp(OpCheckSig)},
+	}
+	for _, test := range tests {
+		if _, ok := test.script.PublicKeyHash(); ok {
+			t.Errorf("%s is detected as pay to public key hash", test.name)
+		}
+	}
+	tests = []struct {
+		name   string
+		script Script
+	}{
+		{"pay to public key hash", PayToPublicKeyHash(hash)},
+		{"short hash", PayToScriptHash(hash[:19])},
+		{"EQUALVERIFY instead of EQUAL", Script{}.AddOp(OpHash160).AddData(hash).AddOp(OpEqualVerify)},
+		{"trailing opcode", PayToScriptHash(hash).AddOp(OpVerify)},
+	}
+	for _, test := range tests {
+		if _, ok := test.script.ScriptHash(); ok {
+			t.Errorf("%s is detected as pay to script hash", test.name)
+		}
+	}
+	required, publicKeys, ok := Multisig(2, keys).ExtractMultisig()
+	if !ok || required != 2 || len(publicKeys) != 3 || !bytes.Equal(publicKeys[2], keys[2]) {
+		t.Error("multisig redeem script is not extracted")
+	}
+	if _, _, ok := Multisig(4, keys).ExtractMultisig(); ok {
+		t.Error("multisig requiring more signatures than keys is extracted")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	keys := testKeys(3)
+	multisig := Multisig(2, keys)
+	payToMultisig := PayToScriptHash(Hash160(multisig))
+	payToKey := PayToPublicKeyHash(Hash160(keys[0]))
+	tests := []struct {
+		name         string
+		scriptSig    Script
+		scriptPubKey Script
+		err          error
+	}{
+		{"pay to public key hash", Script{}.AddData(sign(keys[0])).AddData(keys[0]), payToKey, nil},
+		{"another public key", Script{}.AddData(sign(keys[1])).AddData(keys[1]), payToKey, ErrScriptFailed},
+		{"signature of another key", Script{}.AddData(sign(keys[1])).AddData(keys[0]), payToKey, ErrScriptFailed},
+		{"empty signature", Script{}.AddData(nil).AddData(keys[0]), payToKey, ErrScriptFailed},
+		{"public key missing", Script{}.AddData(sign(keys[0])), payToKey, ErrScriptFailed},
+		{"script sig empty", Script{}, payToKey, ErrStackUnderflow},
+		{"redeem script run",
+			Script{}.AddData(sign(keys[0])).AddData(nil).AddData(sign(keys[2])).AddData(multisig), payToMultisig, nil},
+		{"redeem script failing",
+			Script{}.AddData(sign(keys[0])).AddData(nil).AddData(nil).AddData(multisig), payToMultisig, ErrScriptFailed},
+		{"another redeem script",
+			Script{}.AddData(sign(keys[0])).AddData(sign(keys[1])).AddData(Multisig(1, keys)), payToMultisig, ErrScriptFailed},
+		{"redeem script missing", Script{}, payToMultisig, ErrStackUnderflow},
+		{"pay to public key redeem script",
+			Script{}.AddData(sign(keys[1])).AddData(PayToPublicKey(keys[1])), PayToScriptHash(Hash160(PayToPublicKey(keys[1]))), nil},
+	}
+	for _, test := range tests {
+		if err := Verify(test.scriptSig, test.scriptPubKey, &testChecker{}); !errors.Is(err, test.err) {
+			t.Errorf("%s: error is %v, want %v", test.name, err, test.err)
+		}
+	}
+	notPushOnly := Script{}.AddData(sign(keys[0])).AddData(keys[0]).AddOp(OpDup).AddOp(OpDrop)
+	if err := Verify(notPushOnly, payToKey, &testChecker{}); err == nil {
+		t.Error("a script sig running opcodes is accepted")
+	}
+}
+
+func TestCheckMultiSig(t *testing.T) {
+	keys := testKeys(3)
+	multisig := Multisig(2, keys)
+	// slots to push one signature slot per key, a nil slot stays empty
+	slots := func(signatures ...[]byte) Script {
+		var script Script
+		for _, signature := range signatures {
+			script = script.AddData(signature)
+		}
+		return script
+	}
+	one, two, three := sign(keys[0]), sign(keys[1]), sign(keys[2])
+	tests := []engineTest{
+		{"first two signatures", append(slots(one, two, nil), multisig...), "", true},
+		{"first and last signatures", append(slots(one, nil, three), multisig...), "", true},
+		{"every signature", append(slots(one, two, three), multisig...), "", true},
+		{"signatures swapped", append(slots(two, one, nil), multisig...), "", false},
+		{"signature in the slot of another key", append(slots(one, three, nil), multisig...), "", false},
+		{"one signature missing", append(slots(one, nil, nil), multisig...), "", false},
+		{"every signature missing", append(slots(nil, nil, nil), multisig...), "", false},
+		{"invalid signature besides enough valid ones", append(slots(one, two, one), multisig...), "", false},
+		{"slot missing", append(slots(one, two), multisig...), ErrStackUnderflow.Error(), false},
+		{"more required than keys", append(slots(one, two), Script{}.AddInt(3).AddData(keys[0]).AddData(keys[1]).AddInt(2).AddOp(OpCheckMultiSig)...),
+			"required count is out of range", false},
+		{"no keys", Script{}.AddInt(1).AddInt(0).AddOp(OpCheckMultiSig), "key count is out of range", false},
+		{"too many keys", Script{}.AddInt(MaxMultisigKeys + 1).AddOp(OpCheckMultiSig), "key count is out of range", false},
+	}
+	runEngineTests(t, &testChecker{}, tests)
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	const txLockTime = 1000
+	lockScript := func(lockTime int64) Script {
+		return Script{}.AddInt(lockTime).AddOp(OpCheckLockTimeVerify)
+	}
+	tests := []engineTest{
+		// the lock time stays on the stack, scripts drop it themselves
+		{"lock time reached", lockScript(txLockTime), "", true},
+		{"lock time passed", lockScript(txLockTime - 1), "", true},
+		{"lock time of zero", lockScript(0).AddOp(OpDrop).AddInt(1), "", true},
+		{"lock time not reached", lockScript(txLockTime + 1), "lock time has not been reached", false},
+		{"negative lock time", lockScript(-1), "lock time has not been reached", false},
+		{"lock time too long", Script{}.AddData(make([]byte, maxNumberLength+1)).AddOp(OpCheckLockTimeVerify), "number is too long", false},
+		{"lock time missing", Script{}.AddOp(OpCheckLockTimeVerify), ErrStackUnderflow.Error(), false},
+	}
+	checker := &testChecker{lockTime: txLockTime}
+	runEngineTests(t, checker, tests)
+	// a negative lock time fails before the transaction is asked
+	want := []int64{txLockTime, txLockTime - 1, 0, txLockTime + 1}
+	if len(checker.checked) != len(want) {
+		t.Fatalf("checker is asked %v, want %v", checker.checked, want)
+	}
+	for i, lockTime := range want {
+		if checker.checked[i] != lockTime {
+			t.Errorf("checker is asked %v, want %v", checker.checked, want)
+			break
+		}
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	choose := func(condition int64) Script {
+		return Script{}.AddInt(condition).AddOp(OpIf).AddInt(2).AddOp(OpElse).AddInt(3).AddOp(OpEndIf).AddInt(2).AddOp(OpEqual)
+	}
+	tests := []engineTest{
+		{"IF taken", choose(1), "", true},
+		{"ELSE taken", choose(0), "", false},
+		{"nested ELSE taken",
+			Script{}.AddInt(0).AddInt(1).AddOp(OpIf).AddOp(OpIf).AddInt(4).AddOp(OpElse).AddInt(5).AddOp(OpEndIf).
+				AddOp(OpElse).AddInt(6).AddOp(OpEndIf).AddInt(5).AddOp(OpEqual), "", true},
+		{"nested IF inside a branch not taken does not pop",
+			Script{}.AddInt(0).AddOp(OpIf).AddOp(OpIf).AddInt(4).AddOp(OpEndIf).AddOp(OpElse).AddInt(6).AddOp(OpEndIf).
+				AddInt(6).AddOp(OpEqual), "", true},
+		{"nested ELSE inside a branch not taken is not taken",
+			Script{}.AddInt(0).AddOp(OpIf).AddInt(0).AddOp(OpIf).AddInt(4).AddOp(OpElse).AddInt(5).AddOp(OpEndIf).
+				AddOp(OpElse).AddInt(6).AddOp(OpEndIf).AddInt(6).AddOp(OpEqual), "", true},
+		{"IF without ENDIF", Script{}.AddInt(1).AddOp(OpIf).AddInt(1), "IF without ENDIF", false},
+		{"ELSE without IF", Script{}.AddOp(OpElse), "ELSE without IF", false},
+		{"ENDIF without IF", Script{}.AddInt(1).AddOp(OpEndIf), "ENDIF without IF", false},
+		{"IF on an empty stack", Script{}.AddOp(OpIf).AddOp(OpEndIf), ErrStackUnderflow.Error(), false},
+		{"RETURN", Script{}.AddInt(1).AddOp(OpReturn), ErrUnspendable.Error(), false},
+		{"RETURN taken", Script{}.AddInt(1).AddOp(OpIf).AddOp(OpReturn).AddOp(OpEndIf).AddInt(1), ErrUnspendable.Error(), false},
+		{"RETURN not taken", Script{}.AddInt(0).AddOp(OpIf).AddOp(OpReturn).AddOp(OpEndIf).AddInt(1), "", true},
+		{"null data", NullData([]byte("data")), ErrUnspendable.Error(), false},
+		{"unknown opcode", Script{0xff}, "unknown opcode", false},
+	}
+	runEngineTests(t, &testChecker{}, tests)
+	if !NullData([]byte("data")).IsUnspendable() || choose(1).IsUnspendable() {
+		t.Error("only scripts starting with RETURN are unspendable")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	keys := testKeys(MaxMultisigKeys)
+	// opsBeforeMultisig to run count operations before a 1 of 16 multisig, which counts an operation per key
+	opsBeforeMultisig := func(count int) Script {
+		var script Script
+		for i := 0; i < count; i++ {
+			script = script.AddInt(1).AddOp(OpDrop)
+		}
+		script = script.AddData(sign(keys[0]))
+		for range keys[1:] {
+			script = script.AddData(nil)
+		}
+		return append(script, Multisig(1, keys)...)
+	}
+	tests := []engineTest{
+		{"MaxScriptSize", pushes(t, MaxScriptSize), "", false},
+		{"past MaxScriptSize", pushes(t, MaxScriptSize+1), "script is too large", false},
+		{"MaxElementSize", Script{}.AddData(bytes.Repeat([]byte{1}, MaxElementSize)), "", true},
+		{"past MaxElementSize", Script{}.AddData(bytes.Repeat([]byte{1}, MaxElementSize+1)), "pushed data is too large", false},
+		{"MaxSteps", Script{}.repeat(Op1, MaxSteps), "", true},
+		{"past MaxSteps", Script{}.repeat(Op1, MaxSteps+1), "too many steps", false},
+		{"MaxOps", Script{}.AddInt(1).repeat(OpDup, MaxOps), "", true},
+		{"past MaxOps", Script{}.AddInt(1).repeat(OpDup, MaxOps+1), "too many operations", false},
+		{"MaxOps with multisig keys", opsBeforeMultisig(MaxOps - MaxMultisigKeys - 1), "", true},
+		{"past MaxOps with multisig keys", opsBeforeMultisig(MaxOps - MaxMultisigKeys), "too many operations", false},
+	}
+	runEngineTests(t, &testChecker{}, tests)
+}
@@ -0,0 +1,60 @@
+package script
+
+// Opcode type for a single instruction of a Script
+type Opcode byte
+
+// opcodes understood by the Engine, numbered as in Bitcoin script so that disassembly reads familiar
+const (
+	OpFalse               Opcode = 0x00
+	OpPushData1           Opcode = 0x4c
+	OpPushData2           Opcode = 0x4d
+	Op1                   Opcode = 0x51
+	Op16                  Opcode = 0x60
+	OpIf                  Opcode = 0x63
+	OpElse                Opcode = 0x67
+	OpEndIf               Opcode = 0x68
+	OpVerify              Opcode = 0x69
+	OpReturn              Opcode = 0x6a
+	OpDrop                Opcode = 0x75
+	OpDup                 Opcode = 0x76
+	OpEqual               Opcode = 0x87
+	OpEqualVerify         Opcode = 0x88
+	OpSha256              Opcode = 0xa8
+	OpHash160             Opcode = 0xa9
+	OpCheckSig            Opcode = 0xac
+	OpCheckMultiSig       Opcode = 0xae
+	OpCheckLockTimeVerify Opcode = 0xb1
+)
+
+// opcodeNames for the disassembly of a Script
+var opcodeNames = map[Opcode]string{
+	OpIf:                  "IF",
+	OpElse:                "ELSE",
+	OpEndIf:               "ENDIF",
+	OpVerify:              "VERIFY",
+	OpReturn:              "RETURN",
+	OpDrop:                "DROP",
+	OpDup:                 "DUP",
+	OpEqual:               "EQUAL",
+	OpEqualVerify:         "EQUALVERIFY",
+	OpSha256:              "SHA256",
+	OpHash160:             "HASH160",
+	OpCheckSig:            "CHECKSIG",
+	OpCheckMultiSig:       "CHECKMULTISIG",
+	OpCheckLockTimeVerify: "CHECKLOCKTIMEVERIFY",
+}
+
+// isPush to check whether the opcode only pushes data or a small number
+func (op Opcode) isPush() bool {
+	return op <= OpPushData2 || (op >= Op1 && op <= Op16)
+}
+
+// isSmallInt to check whether the opcode pushes one of the numbers 1 to 16
+func (op Opcode) isSmallInt() bool {
+	return op >= Op1 && op <= Op16
+}
+
+// SmallIntOpcode to get the opcode pushing the number 1 to 16
+func SmallIntOpcode(number int) Opcode {
+	return Op1 + Opcode(number-1)
+}
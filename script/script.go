@@ -0,0 +1,297 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// hash160Length is the length of the public key and script hashes in the templates
+const hash160Length = 20
+
+// Script type for the byte code locking an output or unlocking an input
+type Script []byte
+
+// Instruction structure for one parsed step of a Script
+type Instruction struct {
+	Opcode Opcode
+	Data   []byte
+}
+
+// AddOp to append an opcode to the script
+func (script Script) AddOp(op Opcode) Script {
+	return append(script, byte(op))
+}
+
+// AddData to append the shortest push of the data to the script
+func (script Script) AddData(data []byte) Script {
+	switch {
+	case len(data) < int(OpPushData1):
+		script = append(script, byte(len(data)))
+	case len(data) <= 0xff:
+		script = append(script, byte(OpPushData1), byte(len(data)))
+	default:
+		length := make([]byte, 2)
+		binary.LittleEndian.PutUint16(length, uint16(len(data)))
+		script = append(append(script, byte(OpPushData2)), length...)
+	}
+	return append(script, data...)
+}
+
+// AddInt to append a push of the number to the script
+func (script Script) AddInt(number int64) Script {
+	if number >= 1 && number <= 16 {
+		return script.AddOp(SmallIntOpcode(int(number)))
+	}
+	return script.AddData(encodeNumber(number))
+}
+
+// Parse to split the script into its instructions
+func (script Script) Parse() ([]Instruction, error) {
+	var instructions []Instruction
+	for i := 0; i < len(script); {
+		op := Opcode(script[i])
+		i++
+		length := 0
+		switch {
+		case op > OpFalse && op < OpPushData1:
+			length = int(op)
+		case op == OpPushData1:
+			if i+1 > len(script) {
+				return nil, errors.New("script ends inside a push length")
+			}
+			length = int(script[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(script) {
+				return nil, errors.New("script ends inside a push length")
+			}
+			length = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		}
+		if i+length > len(script) {
+			return nil, errors.New("script ends inside pushed data")
+		}
+		instruction := Instruction{Opcode: op}
+		if op <= OpPushData2 {
+			instruction.Data = script[i : i+length]
+		}
+		i += length
+		instructions = append(instructions, instruction)
+	}
+	return instructions, nil
+}
+
+// IsPushOnly to check whether the script does nothing but push data
+func (script Script) IsPushOnly() bool {
+	instructions, err := script.Parse()
+	if err != nil {
+		return false
+	}
+	for _, instruction := range instructions {
+		if !instruction.Opcode.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// PushedData to get the data pushed by a push only script, in order
+func (script Script) PushedData() ([][]byte, error) {
+	instructions, err := script.Parse()
+	if err != nil {
+		return nil, err
+	}
+	var pushed [][]byte
+	for _, instruction := range instructions {
+		if !instruction.Opcode.isPush() {
+			return nil, errors.New("script is not push only")
+		}
+		pushed = append(pushed, instruction.push())
+	}
+	return pushed, nil
+}
+
+// push to get the bytes an instruction puts on the stack
+func (instruction Instruction) push() []byte {
+	if instruction.Opcode.isSmallInt() {
+		return encodeNumber(int64(instruction.Opcode-Op1) + 1)
+	}
+	return instruction.Data
+}
+
+// String to disassemble the script
+func (script Script) String() string {
+	instructions, err := script.Parse()
+	if err != nil {
+		return fmt.Sprintf("[invalid script %x]", []byte(script))
+	}
+	var parts []string
+	for _, instruction := range instructions {
+		op := instruction.Opcode
+		switch {
+		case op.isSmallInt():
+			parts = append(parts, fmt.Sprintf("%d", int(op-Op1)+1))
+		case op.isPush():
+			parts = append(parts, hex.EncodeToString(instruction.Data))
+		default:
+			name, ok := opcodeNames[op]
+			if !ok {
+				name = fmt.Sprintf("UNKNOWN_%02x", byte(op))
+			}
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Hash160 to hash data with SHA256 followed by RIPEMD160
+func Hash160(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(hash[:])
+	return hasher.Sum(nil)
+}
+
+// PayToPublicKeyHash to build the standard template locking funds to the hash of a public key,
+// unlocked by a ScriptSig pushing a signature and the public key
+func PayToPublicKeyHash(publicKeyHash []byte) Script {
+	return Script{}.AddOp(OpDup).AddOp(OpHash160).AddData(publicKeyHash).AddOp(OpEqualVerify).AddOp(OpCheckSig)
+}
+
+// PayToScriptHash to build the standard template locking funds to the hash of a redeem script,
+// unlocked by a ScriptSig pushing what the redeem script needs followed by the redeem script
+func PayToScriptHash(scriptHash []byte) Script {
+	return Script{}.AddOp(OpHash160).AddData(scriptHash).AddOp(OpEqual)
+}
+
+// Multisig to build the redeem script needing required signatures out of the public keys
+func Multisig(required int, publicKeys [][]byte) Script {
+	script := Script{}.AddInt(int64(required))
+	for _, publicKey := range publicKeys {
+		script = script.AddData(publicKey)
+	}
+	return script.AddInt(int64(len(publicKeys))).AddOp(OpCheckMultiSig)
+}
+
+// PayToPublicKey to build the redeem script locking funds to the public key itself,
+// unlocked by a ScriptSig pushing a single signature
+func PayToPublicKey(publicKey []byte) Script {
+	return Script{}.AddData(publicKey).AddOp(OpCheckSig)
+}
+
+// PublicKey to extract the public key from a pay to public key script
+func (script Script) PublicKey() ([]byte, bool) {
+	instructions, err := script.Parse()
+	if err != nil || len(instructions) != 2 || instructions[1].Opcode != OpCheckSig || len(instructions[0].Data) == 0 {
+		return nil, false
+	}
+	if !bytes.Equal(PayToPublicKey(instructions[0].Data), script) {
+		return nil, false
+	}
+	return instructions[0].Data, true
+}
+
+// PublicKeyHash to extract the public key hash from a pay to public key hash script
+func (script Script) PublicKeyHash() ([]byte, bool) {
+	if len(script) == hash160Length+5 && Opcode(script[0]) == OpDup && Opcode(script[1]) == OpHash160 &&
+		script[2] == hash160Length && Opcode(script[23]) == OpEqualVerify && Opcode(script[24]) == OpCheckSig {
+		return script[3:23], true
+	}
+	return nil, false
+}
+
+// ScriptHash to extract the script hash from a pay to script hash script
+func (script Script) ScriptHash() ([]byte, bool) {
+	if len(script) == hash160Length+3 && Opcode(script[0]) == OpHash160 &&
+		script[1] == hash160Length && Opcode(script[22]) == OpEqual {
+		return script[2:22], true
+	}
+	return nil, false
+}
+
+// ExtractMultisig to extract the required count and public keys from a multisig redeem script
+func (script Script) ExtractMultisig() (int, [][]byte, bool) {
+	instructions, err := script.Parse()
+	if err != nil || len(instructions) < 4 {
+		return 0, nil, false
+	}
+	first, count, last := instructions[0], instructions[len(instructions)-2], instructions[len(instructions)-1]
+	if !first.Opcode.isSmallInt() || !count.Opcode.isSmallInt() || last.Opcode != OpCheckMultiSig {
+		return 0, nil, false
+	}
+	var publicKeys [][]byte
+	for _, instruction := range instructions[1 : len(instructions)-2] {
+		if instruction.Opcode.isSmallInt() || !instruction.Opcode.isPush() || len(instruction.Data) == 0 {
+			return 0, nil, false
+		}
+		publicKeys = append(publicKeys, instruction.Data)
+	}
+	required := int(first.Opcode-Op1) + 1
+	if int(count.Opcode-Op1)+1 != len(publicKeys) || required > len(publicKeys) {
+		return 0, nil, false
+	}
+	if !bytes.Equal(Multisig(required, publicKeys), script) {
+		return 0, nil, false
+	}
+	return required, publicKeys, true
+}
+
+// NullData to build a provably unspendable script carrying the data, RETURN fails whatever the ScriptSig
+func NullData(data []byte) Script {
+	return Script{}.AddOp(OpReturn).AddData(data)
+}
+
+// IsUnspendable to check whether the script fails before anything can unlock it
+func (script Script) IsUnspendable() bool {
+	return len(script) > 0 && Opcode(script[0]) == OpReturn
+}
+
+// NullData to extract the data carried by a NullData script
+func (script Script) NullData() ([]byte, bool) {
+	if !script.IsUnspendable() {
+		return nil, false
+	}
+	pushed, err := script[1:].PushedData()
+	if err != nil || len(pushed) != 1 {
+		return nil, false
+	}
+	return pushed[0], true
+}
+
+// HashTimeLock to build the redeem script of a hash time locked contract, spendable by the recipient
+// with the preimage of the SHA256 secret hash, or by the sender once the lock time has passed
+func HashTimeLock(secretHash, recipient, sender []byte, lockTime int64) Script {
+	return Script{}.AddOp(OpIf).
+		AddOp(OpSha256).AddData(secretHash).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpHash160).AddData(recipient).
+		AddOp(OpElse).
+		AddInt(lockTime).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpHash160).AddData(sender).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).AddOp(OpCheckSig)
+}
+
+// ExtractHashTimeLock to extract the secret hash, the recipient and sender public key hashes and
+// the lock time from a hash time locked contract
+func (script Script) ExtractHashTimeLock() ([]byte, []byte, []byte, int64, bool) {
+	instructions, err := script.Parse()
+	if err != nil || len(instructions) != 17 {
+		return nil, nil, nil, 0, false
+	}
+	secretHash, recipient, sender := instructions[2].Data, instructions[6].Data, instructions[13].Data
+	lockTime, err := decodeNumber(instructions[8].push())
+	if err != nil || !instructions[8].Opcode.isPush() {
+		return nil, nil, nil, 0, false
+	}
+	if !bytes.Equal(HashTimeLock(secretHash, recipient, sender, lockTime), script) {
+		return nil, nil, nil, 0, false
+	}
+	return secretHash, recipient, sender, lockTime, true
+}
@@ -0,0 +1,195 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/the-code-innovator/go-blockchain/params"
+)
+
+// constants for Bech32 addresses as BIP173 and BIP350 describe them
+const (
+	bech32Charset   = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32Separator = '1'
+	bech32MaxLength = 90
+	// the characters of the human readable part are printable US-ASCII
+	bech32MinHRPChar  = 33
+	bech32MaxHRPChar  = 126
+	bech32ChecksumLen = 6
+	// bech32Constant ends the checksum of version 0 addresses, bech32mConstant that of later versions
+	bech32Constant  = uint32(1)
+	bech32mConstant = uint32(0x2bc830a3)
+	// bech32KeyVersion addresses lock to a public key hash, bech32ScriptVersion ones to a script hash
+	bech32KeyVersion    = byte(0)
+	bech32ScriptVersion = byte(1)
+	// bech32Bits is the width of the value every character stands for
+	bech32Bits = 5
+)
+
+// Address formats a Wallet's address can be shown in, both lock funds the same way
+const (
+	FormatBase58 = "base58"
+	FormatBech32 = "bech32"
+)
+
+// bech32Polymod to compute the BCH checksum of the five bit values
+func bech32Polymod(values []byte) uint32 {
+	generators := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i, generator := range generators {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= generator
+			}
+		}
+	}
+	return checksum
+}
+
+// bech32HRPExpand to spread the human readable part over five bit values for the checksum
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, 2*len(hrp)+1)
+	for _, character := range []byte(hrp) {
+		expanded = append(expanded, character>>5)
+	}
+	expanded = append(expanded, 0)
+	for _, character := range []byte(hrp) {
+		expanded = append(expanded, character&31)
+	}
+	return expanded
+}
+
+// bech32Encode to encode the five bit values behind the human readable part with the checksum of the constant
+func bech32Encode(hrp string, data []byte, constant uint32) string {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, make([]byte, bech32ChecksumLen)...)) ^ constant
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteByte(bech32Separator)
+	for _, value := range data {
+		encoded.WriteByte(bech32Charset[value])
+	}
+	for i := 0; i < bech32ChecksumLen; i++ {
+		encoded.WriteByte(bech32Charset[(polymod>>uint(bech32Bits*(bech32ChecksumLen-1-i)))&31])
+	}
+	return encoded.String()
+}
+
+// bech32Decode to split a Bech32 or Bech32m string into its human readable part and five bit values,
+// returning the constant its checksum ends with
+func bech32Decode(encoded string) (string, []byte, uint32, error) {
+	if len(encoded) > bech32MaxLength {
+		return "", nil, 0, errors.New("bech32 string is too long")
+	}
+	if strings.ToLower(encoded) != encoded && strings.ToUpper(encoded) != encoded {
+		return "", nil, 0, errors.New("bech32 string mixes upper and lower case")
+	}
+	encoded = strings.ToLower(encoded)
+	separator := strings.LastIndexByte(encoded, bech32Separator)
+	if separator < 1 || separator+bech32ChecksumLen+1 > len(encoded) {
+		return "", nil, 0, errors.New("bech32 string has no separator")
+	}
+	hrp := encoded[:separator]
+	for _, character := range []byte(hrp) {
+		if character < bech32MinHRPChar || character > bech32MaxHRPChar {
+			return "", nil, 0, errors.New("bech32 human readable part has an invalid character")
+		}
+	}
+	var data []byte
+	for _, character := range encoded[separator+1:] {
+		value := strings.IndexRune(bech32Charset, character)
+		if value < 0 {
+			return "", nil, 0, errors.New("bech32 string has an invalid character")
+		}
+		data = append(data, byte(value))
+	}
+	constant := bech32Polymod(append(bech32HRPExpand(hrp), data...))
+	if constant != bech32Constant && constant != bech32mConstant {
+		return "", nil, 0, errors.New("bech32 checksum does not match")
+	}
+	return hrp, data[:len(data)-bech32ChecksumLen], constant, nil
+}
+
+// convertBits to regroup the bits of the values from one width to another, padding the last group when asked
+func convertBits(values []byte, from, to uint, pad bool) ([]byte, error) {
+	var converted []byte
+	accumulator, bits := uint32(0), uint(0)
+	maximum := uint32(1)<<to - 1
+	for _, value := range values {
+		if uint32(value)>>from != 0 {
+			return nil, errors.New("value is out of range")
+		}
+		accumulator = accumulator<<from | uint32(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			converted = append(converted, byte(accumulator>>bits&maximum))
+		}
+	}
+	if pad && bits > 0 {
+		converted = append(converted, byte(accumulator<<(to-bits)&maximum))
+	} else if !pad && (bits >= from || accumulator<<(to-bits)&maximum != 0) {
+		return nil, errors.New("padding is not valid")
+	}
+	return converted, nil
+}
+
+// Bech32Address to encode the hash behind the human readable part of the active network: a public key hash
+// as a version 0 Bech32 address like a BIP173 key hash, a script hash as a version 1 Bech32m address
+func Bech32Address(hash []byte, scriptHash bool) string {
+	version, constant := bech32KeyVersion, bech32Constant
+	if scriptHash {
+		version, constant = bech32ScriptVersion, bech32mConstant
+	}
+	program, _ := convertBits(hash, 8, bech32Bits, true)
+	return bech32Encode(params.Active().Bech32HRP, append([]byte{version}, program...), constant)
+}
+
+// decodeBech32Address to get the hash a Bech32 address of the active network locks funds to and whether it is a script hash
+func decodeBech32Address(hrp string, data []byte, constant uint32) ([]byte, bool, error) {
+	network := params.Active()
+	if hrp != network.Bech32HRP {
+		return nil, false, errors.New("address is not for " + network.Name)
+	}
+	if len(data) == 0 {
+		return nil, false, errors.New("address has no version")
+	}
+	version := data[0]
+	if (version == bech32KeyVersion) != (constant == bech32Constant) || version > bech32ScriptVersion {
+		return nil, false, errors.New("address version does not match its checksum")
+	}
+	hash, err := convertBits(data[1:], bech32Bits, 8, false)
+	if err != nil || len(hash) != hashLength {
+		return nil, false, errors.New("address is not valid")
+	}
+	return hash, version == bech32ScriptVersion, nil
+}
+
+// FormatAddress to show an address of the active network in the format, Base58 or Bech32
+func FormatAddress(address, format string) (string, error) {
+	hash, scriptHash, err := DecodeAddress(address)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case FormatBase58:
+		if scriptHash {
+			return string(AddressFromScriptHash(hash)), nil
+		}
+		return string(AddressFromPublicKeyHash(hash)), nil
+	case FormatBech32:
+		return Bech32Address(hash, scriptHash), nil
+	}
+	return "", errors.New("unknown address format " + format + ", use " + FormatBase58 + " or " + FormatBech32)
+}
+
+// Base58Address to get the Base58 form of an address, under which the wallets file keeps it,
+// addresses that do not decode are returned as they are
+func Base58Address(address string) string {
+	if formatted, err := FormatAddress(address, FormatBase58); err == nil {
+		return formatted
+	}
+	return address
+}
@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/the-code-innovator/go-blockchain/params"
+)
+
+// the valid strings of the test vectors of BIP173 and BIP350
+var (
+	bech32Valid = []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+		"?1ezyfcl",
+	}
+	bech32mValid = []string{
+		"A1LQFN3A",
+		"a1lqfn3a",
+		"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6",
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+		"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8",
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+		"?1v759aa",
+	}
+)
+
+// the invalid strings of the test vectors of BIP173 and BIP350
+var bech32Invalid = []string{
+	// human readable part character out of range
+	"\x201nwldj5", "\x7f1axkwrx", "\x801eym55h", "\x201xj0phk", "\x7f1g6xzxy", "\x801vctc34",
+	// overall max length exceeded
+	"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+	"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4",
+	// no separator character
+	"pzry9x0s0muk", "qyrz8wqd2c9m",
+	// empty human readable part
+	"1pzry9x0s0muk", "10a06t8", "1qzzfhee", "1qyrz8wqd2c9m", "16plkw9", "1p2gdwpf",
+	// invalid data character
+	"x1b4n0q5v", "y1b0jsk6g", "lt1igcx5c0",
+	// too short checksum
+	"li1dgmt3", "in1muywd",
+	// invalid character in checksum
+	"de1lg7wt\xff", "mm1crxm3i", "au1s5cgom",
+	// checksum calculated with the upper case form of the human readable part
+	"A1G7SGD8", "M1VUXWEZ",
+	// mixed case
+	"a12UEL5L", "A12uEL5L",
+}
+
+func TestBech32Vectors(t *testing.T) {
+	for _, constant := range []uint32{bech32Constant, bech32mConstant} {
+		valid := bech32Valid
+		if constant == bech32mConstant {
+			valid = bech32mValid
+		}
+		for _, encoded := range valid {
+			hrp, data, decodedConstant, err := bech32Decode(encoded)
+			if err != nil {
+				t.Errorf("%q does not decode: %v", encoded, err)
+				continue
+			}
+			if decodedConstant != constant {
+				t.Errorf("%q decodes with constant %x, want %x", encoded, decodedConstant, constant)
+			}
+			if reencoded := bech32Encode(hrp, data, constant); reencoded != strings.ToLower(encoded) {
+				t.Errorf("%q encodes again as %q", encoded, reencoded)
+			}
+		}
+	}
+}
+
+func TestBech32InvalidVectors(t *testing.T) {
+	for _, encoded := range bech32Invalid {
+		if _, _, _, err := bech32Decode(encoded); err == nil {
+			t.Errorf("%q decodes", encoded)
+		}
+	}
+}
+
+func TestBech32Address(t *testing.T) {
+	defer params.SetNetwork(params.Mainnet.Name)
+	hash := bytes.Repeat([]byte{0x75}, hashLength)
+	for _, network := range params.Networks {
+		if err := params.SetNetwork(network.Name); err != nil {
+			t.Fatal(err)
+		}
+		for _, scriptHash := range []bool{false, true} {
+			address := Bech32Address(hash, scriptHash)
+			prefix := network.Bech32HRP + "1q"
+			if scriptHash {
+				prefix = network.Bech32HRP + "1p"
+			}
+			if !strings.HasPrefix(address, prefix) {
+				t.Errorf("%s address %s does not start with %s", network.Name, address, prefix)
+			}
+			decoded, decodedScriptHash, err := DecodeAddress(address)
+			if err != nil {
+				t.Errorf("%s address %s does not decode: %v", network.Name, address, err)
+				continue
+			}
+			if !bytes.Equal(decoded, hash) || decodedScriptHash != scriptHash {
+				t.Errorf("%s address %s decodes to %x, %v", network.Name, address, decoded, decodedScriptHash)
+			}
+		}
+	}
+}
+
+func TestBech32AddressOfBitcoin(t *testing.T) {
+	defer params.SetNetwork(params.Mainnet.Name)
+	// the BIP173 pay to witness public key hash addresses of Bitcoin mainnet and testnet
+	for _, address := range []string{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"} {
+		for _, network := range params.Networks {
+			params.SetNetwork(network.Name)
+			if _, _, err := DecodeAddress(address); err == nil {
+				t.Errorf("Bitcoin address %s decodes on %s", address, network.Name)
+			}
+		}
+	}
+}
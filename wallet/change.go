@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// ChangePolicy type for deciding where the change of a spend is sent
+type ChangePolicy int
+
+// change policies supported by the wallets file
+const (
+	// FreshChange derives a new internal address for every change output
+	FreshChange ChangePolicy = iota
+	// SenderChange returns the change to the address that is spending
+	SenderChange
+)
+
+// String to output the name of the ChangePolicy
+func (policy ChangePolicy) String() string {
+	switch policy {
+	case FreshChange:
+		return "fresh"
+	case SenderChange:
+		return "sender"
+	}
+	return fmt.Sprintf("ChangePolicy(%d)", int(policy))
+}
+
+// ParseChangePolicy to parse a ChangePolicy from its name
+func ParseChangePolicy(name string) (ChangePolicy, error) {
+	switch name {
+	case "fresh":
+		return FreshChange, nil
+	case "sender":
+		return SenderChange, nil
+	}
+	return FreshChange, fmt.Errorf("unknown change policy %q", name)
+}
+
+// ChangeAddress to get the address that receives the change of a spend from the address from,
+// a fresh change address is derived from the key of the address from at its next index, so that
+// restoring a backup of that key restores its change as well
+func (wallets *Wallets) ChangeAddress(from string) string {
+	if wallets.ChangePolicy == SenderChange {
+		return from
+	}
+	parent, _, ok := wallets.KeyFor(from)
+	if !ok {
+		// no key to derive from, only a backup of this random key restores the change
+		address := wallets.AddWallet(P256)
+		wallets.Change[address] = true
+		return address
+	}
+	parentAddress := string(parent.Address())
+	index := wallets.ChangeIndexes[parentAddress]
+	wallets.ChangeIndexes[parentAddress] = index + 1
+	return wallets.addChange(ChangeWallet(parent, index))
+}
+
+// RestoreChange to add the first count change addresses derived from the Wallet, returning those
+// that were not in the wallet yet
+func (wallets *Wallets) RestoreChange(parent *Wallet, count int) []string {
+	var restored []string
+	for index := 0; index < count; index++ {
+		change := ChangeWallet(parent, uint32(index))
+		if _, ok := wallets.Wallets[string(change.Address())]; !ok {
+			restored = append(restored, wallets.addChange(change))
+		}
+	}
+	parentAddress := string(parent.Address())
+	if wallets.ChangeIndexes[parentAddress] < uint32(count) {
+		wallets.ChangeIndexes[parentAddress] = uint32(count)
+	}
+	return restored
+}
+
+// addChange to add the Wallet as a change address
+func (wallets *Wallets) addChange(change *Wallet) string {
+	address := string(change.Address())
+	wallets.Wallets[address] = change
+	wallets.Change[address] = true
+	return address
+}
+
+// ChangeWallet to derive the Wallet of the change address at the index from the private key of the parent,
+// an HMAC-SHA256 of the index keyed by that private key, drawn again with a counter until it is a valid key
+func ChangeWallet(parent *Wallet, index uint32) *Wallet {
+	scheme := parent.PrivateKey.Scheme
+	for counter := uint32(0); ; counter++ {
+		mac := hmac.New(sha256.New, parent.PrivateKey.D)
+		mac.Write([]byte("change " + scheme.Name()))
+		var position [8]byte
+		binary.BigEndian.PutUint32(position[:4], index)
+		binary.BigEndian.PutUint32(position[4:], counter)
+		mac.Write(position[:])
+		candidate := mac.Sum(nil)
+		if validPrivateKey(scheme, candidate) {
+			private := PrivateKey{scheme, candidate}
+			return &Wallet{private, private.PublicKey()}
+		}
+	}
+}
+
+// validPrivateKey to check that the bytes are a private key of the scheme, any seed for Ed25519
+// and a scalar below the order of the curve otherwise
+func validPrivateKey(scheme SignatureScheme, privateKey []byte) bool {
+	switch scheme {
+	case Ed25519:
+		return true
+	case P256:
+		d := new(big.Int).SetBytes(privateKey)
+		return d.Sign() > 0 && d.Cmp(elliptic.P256().Params().N) < 0
+	}
+	_, err := secp256k1Key(privateKey)
+	return err == nil
+}
+
+// IsChange to check whether the address was derived for receiving change
+func (wallets *Wallets) IsChange(address string) bool {
+	return wallets.Change[address]
+}
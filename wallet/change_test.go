@@ -0,0 +1,52 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestChangeWalletIsDerived(t *testing.T) {
+	hash := sha256.Sum256([]byte("change"))
+	for _, scheme := range []SignatureScheme{P256, Secp256k1, Ed25519, Schnorr} {
+		parent := MakeWallet(scheme)
+		first, again, second := ChangeWallet(parent, 0), ChangeWallet(parent, 0), ChangeWallet(parent, 1)
+		if !bytes.Equal(first.PrivateKey.D, again.PrivateKey.D) {
+			t.Errorf("%s: change key at index 0 differs between derivations", scheme.Name())
+		}
+		if bytes.Equal(first.PrivateKey.D, second.PrivateKey.D) {
+			t.Errorf("%s: change keys at index 0 and 1 are the same", scheme.Name())
+		}
+		if first.PrivateKey.Scheme != scheme {
+			t.Errorf("%s: change key signs with %s", scheme.Name(), first.PrivateKey.Scheme.Name())
+		}
+		signature, err := first.PrivateKey.Sign(hash[:])
+		if err != nil {
+			t.Fatalf("%s: change key does not sign: %v", scheme.Name(), err)
+		}
+		if !scheme.Verify(first.PublicKey, signature, hash[:]) {
+			t.Errorf("%s: signature of the change key does not verify", scheme.Name())
+		}
+	}
+}
+
+func TestRestoreChange(t *testing.T) {
+	parent := MakeWallet(Secp256k1)
+	from := string(parent.Address())
+	wallets := Wallets{Wallets: map[string]*Wallet{from: parent}, Change: make(map[string]bool), ChangeIndexes: make(map[string]uint32)}
+	first, second := wallets.ChangeAddress(from), wallets.ChangeAddress(from)
+	if first == second {
+		t.Fatal("two spends got the same change address")
+	}
+	restored := Wallets{Wallets: map[string]*Wallet{from: parent}, Change: make(map[string]bool), ChangeIndexes: make(map[string]uint32)}
+	addresses := restored.RestoreChange(parent, 2)
+	if len(addresses) != 2 || addresses[0] != first || addresses[1] != second {
+		t.Fatalf("restored %v, want [%s %s]", addresses, first, second)
+	}
+	if !restored.IsChange(first) || !restored.IsChange(second) {
+		t.Error("restored addresses are not marked as change")
+	}
+	if next := restored.ChangeAddress(from); next == first || next == second {
+		t.Error("a restored change address is handed out again")
+	}
+}
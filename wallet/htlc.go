@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"github.com/the-code-innovator/go-blockchain/script"
+)
+
+// secretLength is the size of the secrets generated for hash time locked contracts
+const secretLength = 32
+
+// HTLC structure for a hash time locked contract, the Recipient claims the funds with the
+// preimage of the SecretHash and the Sender takes them back once the LockTime has passed,
+// Recipient and Sender are public key hashes
+type HTLC struct {
+	SecretHash []byte
+	Recipient  []byte
+	Sender     []byte
+	LockTime   int64
+}
+
+// NewHTLC to create a contract paying the recipient address for the preimage of the secret hash,
+// refunding the sender address after the lock time
+func NewHTLC(secretHash []byte, recipient, sender string, lockTime int64) (*HTLC, error) {
+	if len(secretHash) != sha256.Size {
+		return nil, errors.New("secret hash is not a SHA256 hash")
+	}
+	if lockTime <= 0 {
+		return nil, errors.New("lock time must be positive")
+	}
+	recipientHash, err := publicKeyHashOf(recipient)
+	if err != nil {
+		return nil, err
+	}
+	senderHash, err := publicKeyHashOf(sender)
+	if err != nil {
+		return nil, err
+	}
+	return &HTLC{secretHash, recipientHash, senderHash, lockTime}, nil
+}
+
+// publicKeyHashOf to get the public key hash behind an address, which must not be a script hash address
+func publicKeyHashOf(address string) ([]byte, error) {
+	publicKeyHash, scriptHash, err := DecodeAddress(address)
+	if err != nil || scriptHash {
+		return nil, errors.New("address " + address + " is not a key address")
+	}
+	return publicKeyHash, nil
+}
+
+// NewSecret to generate a random secret and its SHA256 hash for a contract
+func NewSecret() ([]byte, []byte) {
+	secret := make([]byte, secretLength)
+	_, err := rand.Read(secret)
+	PanicHandle(err)
+	secretHash := sha256.Sum256(secret)
+	return secret, secretHash[:]
+}
+
+// Redeem to build the redeem script of the contract, the address of the contract commits to this script
+func (htlc *HTLC) Redeem() []byte {
+	return script.HashTimeLock(htlc.SecretHash, htlc.Recipient, htlc.Sender, htlc.LockTime)
+}
+
+// ParseHTLC to rebuild the contract from its redeem script
+func ParseHTLC(redeem []byte) (*HTLC, error) {
+	secretHash, recipient, sender, lockTime, ok := script.Script(redeem).ExtractHashTimeLock()
+	if !ok {
+		return nil, errors.New("redeem is not a hash time locked contract")
+	}
+	return &HTLC{secretHash, recipient, sender, lockTime}, nil
+}
+
+// Matches to check whether the preimage hashes to the SecretHash of the contract
+func (htlc *HTLC) Matches(preimage []byte) bool {
+	hash := sha256.Sum256(preimage)
+	return bytes.Equal(hash[:], htlc.SecretHash)
+}
+
+// ScriptHash to hash the Redeem script the way PublicKeyHash hashes a public key
+func (htlc *HTLC) ScriptHash() []byte {
+	return PublicKeyHash(htlc.Redeem())
+}
+
+// Address to find the script hash address the contract is funded at
+func (htlc *HTLC) Address() []byte {
+	return AddressFromScriptHash(htlc.ScriptHash())
+}
+
+// RecipientAddress to find the address of the key that redeems the contract
+func (htlc *HTLC) RecipientAddress() string {
+	return string(AddressFromPublicKeyHash(htlc.Recipient))
+}
+
+// SenderAddress to find the address of the key that refunds the contract
+func (htlc *HTLC) SenderAddress() string {
+	return string(AddressFromPublicKeyHash(htlc.Sender))
+}
+
+// AddHTLC to remember a contract the wallet takes part in
+func (wallets *Wallets) AddHTLC(htlc *HTLC) string {
+	address := string(htlc.Address())
+	wallets.Contracts[address] = htlc
+	return address
+}
+
+// AddSecret to remember the secret of a contract until it is redeemed
+func (wallets *Wallets) AddSecret(secret []byte) {
+	secretHash := sha256.Sum256(secret)
+	wallets.Secrets[hex.EncodeToString(secretHash[:])] = secret
+}
+
+// GetSecret to find the secret behind the secret hash of a contract
+func (wallets *Wallets) GetSecret(secretHash []byte) ([]byte, bool) {
+	secret, ok := wallets.Secrets[hex.EncodeToString(secretHash)]
+	return secret, ok
+}
+
+// GetContractAddresses to get the addresses of the contracts in the wallets file
+func (wallets *Wallets) GetContractAddresses() []string {
+	var addresses []string
+	for address := range wallets.Contracts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
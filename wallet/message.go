@@ -0,0 +1,53 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// messagePrefix keeps signed messages from ever being valid transaction signatures
+const messagePrefix = "Go BlockChain Signed Message:\n"
+
+// coordinateLength is the fixed width of every integer in a message signature
+const coordinateLength = 32
+
+// MessageHash to hash a message the way it is signed by SignMessage
+func MessageHash(message string) []byte {
+	firstHash := sha256.Sum256([]byte(messagePrefix + message))
+	secondHash := sha256.Sum256(firstHash[:])
+	return secondHash[:]
+}
+
+// SignMessage to sign a message with the Wallet's key, the Base64 signature carries the public key
+// the address commits to followed by R||S, each 32 bytes wide, and is the same every time
+func (w Wallet) SignMessage(message string) (string, error) {
+	signed, err := w.PrivateKey.Sign(MessageHash(message))
+	if err != nil {
+		return "", err
+	}
+	signature := append(append([]byte{}, w.PublicKey...), signed...)
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyMessage to check that the signature over the message was made by the key owning the address
+func VerifyMessage(address, signature, message string) (bool, error) {
+	lockedHash, _, err := DecodeAddress(address)
+	if err != nil {
+		return false, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, err
+	}
+	if len(decoded) <= signatureLength {
+		return false, errors.New("signature has the wrong length")
+	}
+	publicKey := decoded[:len(decoded)-signatureLength]
+	// the address commits to the public key in the exact form the signature carries it
+	if !bytes.Equal(PublicKeyHash(publicKey), lockedHash) {
+		return false, nil
+	}
+	return VerifySignature(publicKey, decoded[len(publicKey):], MessageHash(message)), nil
+}
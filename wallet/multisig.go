@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/the-code-innovator/go-blockchain/script"
+)
+
+// maxMultisigKeys is the most public keys an m-of-n multi-signature account takes
+const maxMultisigKeys = script.MaxMultisigKeys
+
+// Multisig structure for an m-of-n multi-signature account, the public keys are kept sorted
+// so that every co-signer derives the same address whatever order the keys were given in
+type Multisig struct {
+	Required   int
+	PublicKeys [][]byte
+}
+
+// NewMultisig to create a Multisig account needing required signatures out of the public keys
+func NewMultisig(required int, publicKeys [][]byte) (*Multisig, error) {
+	if len(publicKeys) == 0 || len(publicKeys) > maxMultisigKeys {
+		return nil, fmt.Errorf("a multisig account takes 1 to %d public keys", maxMultisigKeys)
+	}
+	if required < 1 || required > len(publicKeys) {
+		return nil, fmt.Errorf("required signatures must be between 1 and %d", len(publicKeys))
+	}
+	sorted := make([][]byte, len(publicKeys))
+	copy(sorted, publicKeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	for i, publicKey := range sorted {
+		if len(publicKey) == 0 || len(publicKey) > 255 {
+			return nil, errors.New("public key has an invalid length")
+		}
+		if i > 0 && bytes.Equal(sorted[i-1], publicKey) {
+			return nil, errors.New("public key is given more than once")
+		}
+	}
+	return &Multisig{required, sorted}, nil
+}
+
+// Redeem to build the multi-signature redeem script of the account,
+// the address of the account commits to this script
+func (multisig *Multisig) Redeem() []byte {
+	return script.Multisig(multisig.Required, multisig.PublicKeys)
+}
+
+// ParseRedeem to rebuild the Multisig account from its redeem script
+func ParseRedeem(redeem []byte) (*Multisig, error) {
+	required, publicKeys, ok := script.Script(redeem).ExtractMultisig()
+	if !ok {
+		return nil, errors.New("redeem is not a multisig script")
+	}
+	multisig, err := NewMultisig(required, publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(multisig.Redeem(), redeem) {
+		return nil, errors.New("redeem public keys are not sorted")
+	}
+	return multisig, nil
+}
+
+// ScriptHash to hash the Redeem script the way PublicKeyHash hashes a public key
+func (multisig *Multisig) ScriptHash() []byte {
+	return PublicKeyHash(multisig.Redeem())
+}
+
+// Address to find the multi-signature address of the account
+func (multisig *Multisig) Address() []byte {
+	return AddressFromScriptHash(multisig.ScriptHash())
+}
+
+// AddMultisig to remember a multi-signature account the wallet takes part in
+func (wallets *Wallets) AddMultisig(multisig *Multisig) string {
+	address := string(multisig.Address())
+	wallets.Multisigs[address] = multisig
+	return address
+}
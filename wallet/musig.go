@@ -0,0 +1,269 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/the-code-innovator/go-blockchain/script"
+)
+
+// the sizes of what the signers of a MuSig account share: a public nonce is two compressed points
+// and a partial signature is its scalar followed by the compressed nonce point of the session
+const (
+	publicNonceLength      = musig2.PubNonceSize
+	partialSignatureLength = coordinateLength + compressedKeyLength
+)
+
+// MuSig structure for an n-of-n account whose signers add their Schnorr keys up into one aggregate
+// key and their partial signatures into one signature as BIP327 (MuSig2) describes, so a spend carries
+// a single signature whatever the number of signers, the public keys are kept sorted like those of a Multisig
+type MuSig struct {
+	PublicKeys [][]byte
+}
+
+// NewMuSig to create a MuSig account of the Schnorr public keys
+func NewMuSig(publicKeys [][]byte) (*MuSig, error) {
+	if len(publicKeys) < 2 || len(publicKeys) > maxMultisigKeys {
+		return nil, fmt.Errorf("a musig account takes 2 to %d public keys", maxMultisigKeys)
+	}
+	sorted := make([][]byte, len(publicKeys))
+	copy(sorted, publicKeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	for i, publicKey := range sorted {
+		if _, err := parseXOnlyKey(publicKey); err != nil {
+			return nil, err
+		}
+		if i > 0 && bytes.Equal(sorted[i-1], publicKey) {
+			return nil, errors.New("public key is given more than once")
+		}
+	}
+	musig := &MuSig{sorted}
+	if _, err := musig.aggregate(); err != nil {
+		return nil, err
+	}
+	return musig, nil
+}
+
+// keys to parse the public keys of the account, which NewMuSig checked
+func (musig *MuSig) keys() []*btcec.PublicKey {
+	var keys []*btcec.PublicKey
+	for _, publicKey := range musig.PublicKeys {
+		key, _ := parseXOnlyKey(publicKey)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// aggregate to add up the public keys of the account, each multiplied by its coefficient
+func (musig *MuSig) aggregate() (*btcec.PublicKey, error) {
+	aggregate, _, _, err := musig2.AggregateKeys(musig.keys(), false)
+	if err != nil {
+		return nil, err
+	}
+	return aggregate.FinalKey, nil
+}
+
+// AggregateKey to get the Schnorr public key the aggregated signature verifies against
+func (musig *MuSig) AggregateKey() []byte {
+	key, err := musig.aggregate()
+	if err != nil {
+		return nil
+	}
+	return xOnlyKey(key)
+}
+
+// Redeem to build the redeem script of the account, paying to the aggregate key,
+// the address of the account commits to this script
+func (musig *MuSig) Redeem() []byte {
+	return script.PayToPublicKey(musig.AggregateKey())
+}
+
+// ScriptHash to hash the Redeem script the way PublicKeyHash hashes a public key
+func (musig *MuSig) ScriptHash() []byte {
+	return PublicKeyHash(musig.Redeem())
+}
+
+// Address to find the script hash address of the account
+func (musig *MuSig) Address() []byte {
+	return AddressFromScriptHash(musig.ScriptHash())
+}
+
+// IsPublicNonce to check whether the data has the size of a public nonce
+func IsPublicNonce(data []byte) bool {
+	return len(data) == publicNonceLength
+}
+
+// NewNonce to draw the secret nonce the signer of the Schnorr public key uses for one signing session and
+// the public nonce to share with the other signers, a secret nonce must never sign twice
+func NewNonce(publicKey []byte) ([]byte, []byte, error) {
+	key, err := parseXOnlyKey(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonces, err := musig2.GenNonces(musig2.WithPublicKey(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	return nonces.SecNonce[:], nonces.PubNonce[:], nil
+}
+
+// combinedNonce to add up the public nonces of every signer, in the order of the public keys
+func (musig *MuSig) combinedNonce(publicNonces [][]byte) ([musig2.PubNonceSize]byte, error) {
+	if len(publicNonces) != len(musig.PublicKeys) {
+		return [musig2.PubNonceSize]byte{}, errors.New("every signer needs a public nonce")
+	}
+	var nonces [][musig2.PubNonceSize]byte
+	for _, encoded := range publicNonces {
+		if !IsPublicNonce(encoded) {
+			return [musig2.PubNonceSize]byte{}, errors.New("public nonce has the wrong length")
+		}
+		nonces = append(nonces, *(*[musig2.PubNonceSize]byte)(encoded))
+	}
+	return musig2.AggregateNonces(nonces)
+}
+
+// keyIndex to find the position of the public key in the account
+func (musig *MuSig) keyIndex(publicKey []byte) int {
+	for keyID, candidate := range musig.PublicKeys {
+		if bytes.Equal(candidate, publicKey) {
+			return keyID
+		}
+	}
+	return -1
+}
+
+// PartialSign to sign the message with the private key of one of the signers and its secret nonce,
+// whose public nonce must be among the public nonces of the session
+func (musig *MuSig) PartialSign(privateKey PrivateKey, secretNonce []byte, publicNonces [][]byte, message []byte) ([]byte, error) {
+	keyID := musig.keyIndex(privateKey.PublicKey())
+	if privateKey.Scheme != Schnorr || keyID < 0 {
+		return nil, errors.New("private key is not a key of the account")
+	}
+	if len(secretNonce) != musig2.SecNonceSize || len(message) != coordinateLength {
+		return nil, errors.New("secret nonce or message has the wrong length")
+	}
+	combined, err := musig.combinedNonce(publicNonces)
+	if err != nil {
+		return nil, err
+	}
+	key, err := secp256k1Key(privateKey.D)
+	if err != nil {
+		return nil, err
+	}
+	// the key of the account is the x-only key, so the private key must give an even Y
+	if key.PubKey().SerializeCompressed()[0] == secp256k1.PubKeyFormatCompressedOdd {
+		key.Key.Negate()
+	}
+	signed, err := musig2.Sign(*(*[musig2.SecNonceSize]byte)(secretNonce), key, combined, musig.keys(),
+		*(*[coordinateLength]byte)(message))
+	if err != nil {
+		return nil, err
+	}
+	partial := make([]byte, coordinateLength, partialSignatureLength)
+	signed.S.PutBytesUnchecked(partial)
+	partial = append(partial, signed.R.SerializeCompressed()...)
+	if !musig.PartialVerify(keyID, partial, publicNonces, message) {
+		return nil, errors.New("secret nonce does not match the public nonce of the signer")
+	}
+	return partial, nil
+}
+
+// parsePartial to read the scalar and the nonce point of a partial signature
+func parsePartial(partial []byte) (*musig2.PartialSignature, error) {
+	var s btcec.ModNScalar
+	if len(partial) != partialSignatureLength || s.SetByteSlice(partial[:coordinateLength]) {
+		return nil, errors.New("partial signature is not valid")
+	}
+	nonce, err := secp256k1.ParsePubKey(partial[coordinateLength:])
+	if err != nil {
+		return nil, err
+	}
+	signature := musig2.NewPartialSignature(&s, nonce)
+	return &signature, nil
+}
+
+// PartialVerify to check the partial signature of the signer at the position against its public key and nonce
+func (musig *MuSig) PartialVerify(keyID int, partial []byte, publicNonces [][]byte, message []byte) bool {
+	if keyID < 0 || keyID >= len(musig.PublicKeys) || len(message) != coordinateLength {
+		return false
+	}
+	combined, err := musig.combinedNonce(publicNonces)
+	if err != nil {
+		return false
+	}
+	signature, err := parsePartial(partial)
+	if err != nil {
+		return false
+	}
+	keys := musig.keys()
+	return signature.Verify(*(*[musig2.PubNonceSize]byte)(publicNonces[keyID]), combined, keys, keys[keyID],
+		*(*[coordinateLength]byte)(message))
+}
+
+// AggregateSignatures to add up the partial signatures of every signer into the Schnorr signature of the account
+func (musig *MuSig) AggregateSignatures(publicNonces, partials [][]byte, message []byte) ([]byte, error) {
+	if len(partials) != len(musig.PublicKeys) {
+		return nil, errors.New("every signer needs a partial signature")
+	}
+	var signatures []*musig2.PartialSignature
+	for keyID, partial := range partials {
+		if !musig.PartialVerify(keyID, partial, publicNonces, message) {
+			return nil, fmt.Errorf("partial signature of key %x is not valid", musig.PublicKeys[keyID])
+		}
+		// the nonce point is not covered by PartialVerify, every signer must name the same one and the
+		// aggregated signature is checked against it below
+		if !bytes.Equal(partial[coordinateLength:], partials[0][coordinateLength:]) {
+			return nil, errors.New("partial signatures name different nonces")
+		}
+		signature, _ := parsePartial(partial)
+		signatures = append(signatures, signature)
+	}
+	signature := musig2.CombineSigs(signatures[0].R, signatures).Serialize()
+	if !VerifySignature(musig.AggregateKey(), signature, message) {
+		return nil, errors.New("aggregated signature is not valid")
+	}
+	return signature, nil
+}
+
+// AddMuSig to remember a MuSig account the wallet takes part in
+func (wallets *Wallets) AddMuSig(musig *MuSig) string {
+	address := string(musig.Address())
+	wallets.MuSigs[address] = musig
+	return address
+}
+
+// GetMuSigAddresses to get the MuSig addresses in the wallets file
+func (wallets *Wallets) GetMuSigAddresses() []string {
+	var addresses []string
+	for address := range wallets.MuSigs {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// nonceKey to name the secret nonce of the public key for signing the message
+func nonceKey(message, publicKey []byte) string {
+	return hex.EncodeToString(message) + ":" + hex.EncodeToString(publicKey)
+}
+
+// AddNonce to keep the secret nonce of the public key until it signs the message
+func (wallets *Wallets) AddNonce(message, publicKey, secretNonce []byte) {
+	wallets.Nonces[nonceKey(message, publicKey)] = secretNonce
+}
+
+// TakeNonce to take out the secret nonce of the public key for the message, so that it never signs twice
+func (wallets *Wallets) TakeNonce(message, publicKey []byte) ([]byte, bool) {
+	key := nonceKey(message, publicKey)
+	secretNonce, ok := wallets.Nonces[key]
+	delete(wallets.Nonces, key)
+	return secretNonce, ok
+}
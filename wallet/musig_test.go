@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestMuSigRoundTrip(t *testing.T) {
+	const signers = 3
+	privateKeys := make([]PrivateKey, signers)
+	publicKeys := make([][]byte, signers)
+	for i := range privateKeys {
+		d, err := Schnorr.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privateKeys[i] = PrivateKey{Scheme: Schnorr, D: d}
+		publicKeys[i] = privateKeys[i].PublicKey()
+	}
+	musig, err := NewMuSig(publicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the signers may sort into another order than they were given in
+	secretNonces := make([][]byte, signers)
+	publicNonces := make([][]byte, signers)
+	for i := range musig.PublicKeys {
+		if secretNonces[i], publicNonces[i], err = NewNonce(musig.PublicKeys[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message := sha256.Sum256([]byte("musig"))
+	partials := make([][]byte, signers)
+	for _, privateKey := range privateKeys {
+		keyID := musig.keyIndex(privateKey.PublicKey())
+		if partials[keyID], err = musig.PartialSign(privateKey, secretNonces[keyID], publicNonces, message[:]); err != nil {
+			t.Fatal(err)
+		}
+		if !musig.PartialVerify(keyID, partials[keyID], publicNonces, message[:]) {
+			t.Errorf("partial signature of key %x does not verify", privateKey.PublicKey())
+		}
+	}
+	signature, err := musig.AggregateSignatures(publicNonces, partials, message[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifySignature(musig.AggregateKey(), signature, message[:]) {
+		t.Error("aggregated signature does not verify")
+	}
+	other := sha256.Sum256([]byte("other"))
+	if VerifySignature(musig.AggregateKey(), signature, other[:]) {
+		t.Error("aggregated signature verifies another message")
+	}
+
+	// a partial signature with a single bit flipped is caught before aggregation
+	tampered := append([]byte(nil), partials[0]...)
+	tampered[0] ^= 1
+	if musig.PartialVerify(0, tampered, publicNonces, message[:]) {
+		t.Error("tampered partial signature verifies")
+	}
+	if _, err := musig.AggregateSignatures(publicNonces, [][]byte{tampered, partials[1], partials[2]}, message[:]); err == nil {
+		t.Error("tampered partial signature aggregates")
+	}
+	// so is a partial signature naming another nonce point
+	renamed := append(append([]byte(nil), partials[0][:coordinateLength]...), publicNonces[0][:compressedKeyLength]...)
+	if _, err := musig.AggregateSignatures(publicNonces, [][]byte{renamed, partials[1], partials[2]}, message[:]); err == nil {
+		t.Error("partial signature naming another nonce aggregates")
+	}
+	// a secret nonce used with the public nonce of another signer is refused
+	keyID := musig.keyIndex(privateKeys[0].PublicKey())
+	if _, err := musig.PartialSign(privateKeys[0], secretNonces[(keyID+1)%signers], publicNonces, message[:]); err == nil {
+		t.Error("partial signature with the nonce of another signer is made")
+	}
+}
@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+)
+
+// SEC1 public key lengths and prefixes, the compressed form is the one new wallets use
+const (
+	compressedKeyLength   = 1 + coordinateLength
+	uncompressedKeyLength = 1 + 2*coordinateLength
+	uncompressedKeyPrefix = byte(0x04)
+)
+
+// EncodePublicKey to lay the public key out in SEC1 form, 0x02 or 0x03 followed by X when compressed,
+// 0x04 followed by X and Y when not, every coordinate 32 bytes wide
+func EncodePublicKey(publicKey *ecdsa.PublicKey, compressed bool) []byte {
+	if compressed {
+		return elliptic.MarshalCompressed(publicKey.Curve, publicKey.X, publicKey.Y)
+	}
+	encoded := make([]byte, uncompressedKeyLength)
+	encoded[0] = uncompressedKeyPrefix
+	publicKey.X.FillBytes(encoded[1 : 1+coordinateLength])
+	publicKey.Y.FillBytes(encoded[1+coordinateLength:])
+	return encoded
+}
+
+// ParsePublicKey to rebuild the P256 public key from its SEC1 compressed or uncompressed form,
+// or from the X||Y form of wallets created before SEC1
+func ParsePublicKey(publicKey []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+	switch {
+	case isCompressedKey(publicKey):
+		x, y = elliptic.UnmarshalCompressed(curve, publicKey)
+		if x == nil {
+			return nil, errors.New("public key is not on the curve")
+		}
+	case isUncompressedKey(publicKey):
+		x = new(big.Int).SetBytes(publicKey[1 : 1+coordinateLength])
+		y = new(big.Int).SetBytes(publicKey[1+coordinateLength:])
+	default:
+		var ok bool
+		if x, y, ok = parseLegacyPublicKey(curve, publicKey); !ok {
+			return nil, errors.New("public key is not on the curve")
+		}
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("public key is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// parseLegacyPublicKey to split an X||Y public key, whose coordinates lost their leading zero
+// bytes, by trying every split that leaves both coordinates at most 32 bytes wide
+func parseLegacyPublicKey(curve elliptic.Curve, publicKey []byte) (*big.Int, *big.Int, bool) {
+	for split := len(publicKey) - coordinateLength; split <= coordinateLength; split++ {
+		if split <= 0 || split >= len(publicKey) {
+			continue
+		}
+		x := new(big.Int).SetBytes(publicKey[:split])
+		y := new(big.Int).SetBytes(publicKey[split:])
+		if curve.IsOnCurve(x, y) {
+			return x, y, true
+		}
+	}
+	return nil, nil, false
+}
+
+// IsLegacyPublicKey to check whether the P256 public key is still in the X||Y form rather than in SEC1 form
+func IsLegacyPublicKey(publicKey []byte) bool {
+	return SchemeOf(publicKey) == P256 && !isCompressedKey(publicKey) && !isUncompressedKey(publicKey)
+}
+
+// legacyPublicKey to lay the public key out the way wallets created before SEC1 did,
+// which the addresses of those wallets commit to
+func legacyPublicKey(publicKey *ecdsa.PublicKey) []byte {
+	return append(publicKey.X.Bytes(), publicKey.Y.Bytes()...)
+}
+
+// SamePublicKey to check whether two encodings are of the same public key
+func SamePublicKey(first, second []byte) bool {
+	if bytes.Equal(first, second) {
+		return true
+	}
+	firstKey, err := ParsePublicKey(first)
+	if err != nil {
+		return false
+	}
+	secondKey, err := ParsePublicKey(second)
+	if err != nil {
+		return false
+	}
+	return firstKey.X.Cmp(secondKey.X) == 0 && firstKey.Y.Cmp(secondKey.Y) == 0
+}
+
+// isCompressedKey to check for the SEC1 compressed form
+func isCompressedKey(publicKey []byte) bool {
+	return len(publicKey) == compressedKeyLength && (publicKey[0] == 0x02 || publicKey[0] == 0x03)
+}
+
+// isUncompressedKey to check for the SEC1 uncompressed form
+func isUncompressedKey(publicKey []byte) bool {
+	return len(publicKey) == uncompressedKeyLength && publicKey[0] == uncompressedKeyPrefix
+}
@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// tags in front of the public keys of every scheme but P256, whose SEC1 keys stay untagged
+// so that the addresses of existing wallets do not change
+const (
+	secp256k1Tag = byte(0x10)
+	ed25519Tag   = byte(0x11)
+	schnorrTag   = byte(0x12)
+)
+
+// SignatureScheme interface for the signature algorithms a Wallet's key can use, the public key
+// carries the tag of its scheme and the address commits to it, so an output knows how to be verified
+type SignatureScheme interface {
+	Name() string
+	GenerateKey() ([]byte, error)
+	PublicKey(privateKey []byte) []byte
+	Sign(privateKey, hash []byte) ([]byte, error)
+	Verify(publicKey, signature, hash []byte) bool
+}
+
+// the schemes a Wallet can be created with
+var (
+	P256      SignatureScheme = p256Scheme{}
+	Secp256k1 SignatureScheme = secp256k1Scheme{}
+	Ed25519   SignatureScheme = ed25519Scheme{}
+)
+
+// SchemeNames lists the schemes understood by ParseScheme
+var SchemeNames = []string{P256.Name(), Secp256k1.Name(), Ed25519.Name(), Schnorr.Name()}
+
+// ParseScheme to find the signature scheme by its name
+func ParseScheme(name string) (SignatureScheme, error) {
+	for _, scheme := range []SignatureScheme{P256, Secp256k1, Ed25519, Schnorr} {
+		if scheme.Name() == strings.ToLower(name) {
+			return scheme, nil
+		}
+	}
+	return nil, errors.New("unknown signature scheme " + name + ", use one of " + strings.Join(SchemeNames, ", "))
+}
+
+// SchemeOf to find the scheme of an encoded public key by its tag, untagged keys are P256
+func SchemeOf(publicKey []byte) SignatureScheme {
+	switch {
+	case len(publicKey) == 1+compressedKeyLength && publicKey[0] == secp256k1Tag:
+		return Secp256k1
+	case len(publicKey) == 1+ed25519.PublicKeySize && publicKey[0] == ed25519Tag:
+		return Ed25519
+	case len(publicKey) == 1+coordinateLength && publicKey[0] == schnorrTag:
+		return Schnorr
+	}
+	return P256
+}
+
+// PrivateKey structure for the secret key of a Wallet and the scheme it signs with
+type PrivateKey struct {
+	Scheme SignatureScheme
+	D      []byte
+}
+
+// Sign to sign the hash, the same key and hash always give the same 64 byte signature
+func (key PrivateKey) Sign(hash []byte) ([]byte, error) {
+	return key.Scheme.Sign(key.D, hash)
+}
+
+// PublicKey to get the encoded public key of the private key, tagged with its scheme
+func (key PrivateKey) PublicKey() []byte {
+	return key.Scheme.PublicKey(key.D)
+}
+
+// p256Scheme for ECDSA over NIST P-256, the scheme of every wallet from before schemes
+type p256Scheme struct{}
+
+// Name of the scheme
+func (p256Scheme) Name() string {
+	return "p256"
+}
+
+// GenerateKey to draw a new private key
+func (p256Scheme) GenerateKey() ([]byte, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return private.D.FillBytes(make([]byte, coordinateLength)), nil
+}
+
+// PublicKey in its untagged SEC1 compressed form
+func (p256Scheme) PublicKey(privateKey []byte) []byte {
+	return EncodePublicKey(&p256Key(privateKey).PublicKey, true)
+}
+
+// Sign with deterministic low S ECDSA
+func (p256Scheme) Sign(privateKey, hash []byte) ([]byte, error) {
+	return p256Sign(p256Key(privateKey), hash)
+}
+
+// Verify against a SEC1 or X||Y public key
+func (p256Scheme) Verify(publicKey, signature, hash []byte) bool {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	return verify(key, signature, hash)
+}
+
+// p256Key to rebuild the P256 key pair from the private scalar
+func p256Key(privateKey []byte) *ecdsa.PrivateKey {
+	curve := elliptic.P256()
+	private := ecdsa.PrivateKey{D: new(big.Int).SetBytes(privateKey)}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(privateKey)
+	return &private
+}
+
+// secp256k1Scheme for ECDSA over secp256k1, the curve of most external tooling
+type secp256k1Scheme struct{}
+
+// Name of the scheme
+func (secp256k1Scheme) Name() string {
+	return "secp256k1"
+}
+
+// GenerateKey to draw a new private key
+func (secp256k1Scheme) GenerateKey() ([]byte, error) {
+	return newScalar()
+}
+
+// PublicKey as the tag followed by the SEC1 compressed point
+func (secp256k1Scheme) PublicKey(privateKey []byte) []byte {
+	return append([]byte{secp256k1Tag}, secp256k1.PrivKeyFromBytes(privateKey).PubKey().SerializeCompressed()...)
+}
+
+// Sign with deterministic low S ECDSA
+func (secp256k1Scheme) Sign(privateKey, hash []byte) ([]byte, error) {
+	return secp256k1Sign(privateKey, hash)
+}
+
+// Verify against a tagged public key
+func (secp256k1Scheme) Verify(publicKey, signature, hash []byte) bool {
+	if SchemeOf(publicKey) != Secp256k1 || !isCompressedKey(publicKey[1:]) {
+		return false
+	}
+	key, err := secp256k1.ParsePubKey(publicKey[1:])
+	if err != nil {
+		return false
+	}
+	return secp256k1Verify(key, signature, hash)
+}
+
+// ed25519Scheme for Ed25519, whose signatures are deterministic by design
+type ed25519Scheme struct{}
+
+// Name of the scheme
+func (ed25519Scheme) Name() string {
+	return "ed25519"
+}
+
+// GenerateKey to draw a new private key seed
+func (ed25519Scheme) GenerateKey() ([]byte, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	_, err := rand.Read(seed)
+	return seed, err
+}
+
+// PublicKey as the tag followed by the 32 byte Ed25519 public key
+func (ed25519Scheme) PublicKey(privateKey []byte) []byte {
+	public := ed25519.NewKeyFromSeed(privateKey).Public().(ed25519.PublicKey)
+	return append([]byte{ed25519Tag}, public...)
+}
+
+// Sign the hash as the message
+func (ed25519Scheme) Sign(privateKey, hash []byte) ([]byte, error) {
+	if len(privateKey) != ed25519.SeedSize {
+		return nil, errors.New("private key is not an Ed25519 seed")
+	}
+	return ed25519.Sign(ed25519.NewKeyFromSeed(privateKey), hash), nil
+}
+
+// Verify against a tagged public key
+func (ed25519Scheme) Verify(publicKey, signature, hash []byte) bool {
+	if SchemeOf(publicKey) != Ed25519 || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(publicKey[1:]), hash, signature)
+}
@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Schnorr signs with BIP340 Schnorr signatures over secp256k1, whose keys add up so that
+// several signers can share one key and one signature, see MuSig
+var Schnorr SignatureScheme = schnorrScheme{}
+
+// schnorrScheme for BIP340 Schnorr signatures over secp256k1
+type schnorrScheme struct{}
+
+// Name of the scheme
+func (schnorrScheme) Name() string {
+	return "schnorr"
+}
+
+// GenerateKey to draw a new private key
+func (schnorrScheme) GenerateKey() ([]byte, error) {
+	return newScalar()
+}
+
+// PublicKey as the tag followed by the X coordinate of the public point
+func (schnorrScheme) PublicKey(privateKey []byte) []byte {
+	return xOnlyKey(secp256k1.PrivKeyFromBytes(privateKey).PubKey())
+}
+
+// Sign with all zero auxiliary randomness, so that signing is deterministic like the other schemes
+func (schnorrScheme) Sign(privateKey, hash []byte) ([]byte, error) {
+	key, err := secp256k1Key(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := schnorr.Sign(key, hash, schnorr.CustomNonce([coordinateLength]byte{}))
+	if err != nil {
+		return nil, err
+	}
+	return signature.Serialize(), nil
+}
+
+// Verify against a tagged x-only public key
+func (schnorrScheme) Verify(publicKey, signature, hash []byte) bool {
+	key, err := parseXOnlyKey(publicKey)
+	if err != nil {
+		return false
+	}
+	parsed, err := schnorr.ParseSignature(signature)
+	return err == nil && parsed.Verify(hash, key)
+}
+
+// xOnlyKey to encode the public key as the tag followed by its X coordinate, the Y coordinate is taken to be even
+func xOnlyKey(publicKey *btcec.PublicKey) []byte {
+	return append([]byte{schnorrTag}, schnorr.SerializePubKey(publicKey)...)
+}
+
+// parseXOnlyKey to find the point with the even Y coordinate behind a tagged x-only public key
+func parseXOnlyKey(publicKey []byte) (*btcec.PublicKey, error) {
+	if SchemeOf(publicKey) != Schnorr {
+		return nil, errors.New("public key is not a Schnorr key")
+	}
+	return schnorr.ParsePubKey(publicKey[1:])
+}
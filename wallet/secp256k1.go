@@ -0,0 +1,53 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// secp256k1Key to read a private key of the curve, refusing zero and values past the order of the curve
+func secp256k1Key(privateKey []byte) (*secp256k1.PrivateKey, error) {
+	var d secp256k1.ModNScalar
+	if len(privateKey) != coordinateLength || d.SetByteSlice(privateKey) || d.IsZero() {
+		return nil, errors.New("private key is out of range")
+	}
+	return secp256k1.NewPrivateKey(&d), nil
+}
+
+// newScalar to draw a random private key of the curve
+func newScalar() ([]byte, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return key.Serialize(), nil
+}
+
+// secp256k1Sign to sign the hash with ECDSA over secp256k1, the nonce from RFC 6979 and S kept low
+func secp256k1Sign(privateKey, hash []byte) ([]byte, error) {
+	key, err := secp256k1Key(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signed := ecdsa.Sign(key, hash)
+	r, s := signed.R(), signed.S()
+	signature := make([]byte, signatureLength)
+	r.PutBytesUnchecked(signature[:coordinateLength])
+	s.PutBytesUnchecked(signature[coordinateLength:])
+	return signature, nil
+}
+
+// secp256k1Verify to verify a fixed width R||S signature with a low S over the hash
+func secp256k1Verify(publicKey *secp256k1.PublicKey, signature, hash []byte) bool {
+	if len(signature) != signatureLength {
+		return false
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:coordinateLength]) || s.SetByteSlice(signature[coordinateLength:]) ||
+		r.IsZero() || s.IsZero() || s.IsOverHalfOrder() {
+		return false
+	}
+	return ecdsa.NewSignature(&r, &s).Verify(hash, publicKey)
+}
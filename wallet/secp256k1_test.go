@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// the RFC 6979 secp256k1 signatures over SHA-256 shared by Trezor and CoreBitcoin, with S low
+var secp256k1Vectors = []struct {
+	key, message, r, s string
+}{
+	{
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"Satoshi Nakamoto",
+		"934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8",
+		"2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+	},
+	{
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140",
+		"Satoshi Nakamoto",
+		"fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d0",
+		"6b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5",
+	},
+	{
+		"f8b8af8ce3c7cca5e300d33939540c10d45ce001b8f252bfbc57ba0342904181",
+		"Alan Turing",
+		"7063ae83e7f62bbb171798131b4a0564b956930092b33b07b395615d9ec7e15c",
+		"58dfcc1e00a35e1572f366ffe34ba0fc47db1e7189759b9fb233c5b05ab388ea",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"All those moments will be lost in time, like tears in rain. Time to die...",
+		"8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b",
+		"547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+	},
+}
+
+func TestSecp256k1SignRFC6979(t *testing.T) {
+	for _, vector := range secp256k1Vectors {
+		privateKey, _ := hex.DecodeString(vector.key)
+		hash := sha256.Sum256([]byte(vector.message))
+		signature, err := secp256k1Sign(privateKey, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := vector.r + vector.s; hex.EncodeToString(signature) != want {
+			t.Errorf("signature of %q is %x, want %s", vector.message, signature, want)
+		}
+		if !Secp256k1.Verify(Secp256k1.PublicKey(privateKey), signature, hash[:]) {
+			t.Errorf("signature of %q does not verify", vector.message)
+		}
+	}
+}
+
+func TestSecp256k1VerifyRejectsHighS(t *testing.T) {
+	privateKey, _ := hex.DecodeString(secp256k1Vectors[2].key)
+	hash := sha256.Sum256([]byte(secp256k1Vectors[2].message))
+	signature, err := secp256k1Sign(privateKey, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r, s secp256k1.ModNScalar
+	r.SetByteSlice(signature[:coordinateLength])
+	s.SetByteSlice(signature[coordinateLength:])
+	s.Negate()
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], secp256k1.PrivKeyFromBytes(privateKey).PubKey()) {
+		t.Fatal("high S form of the signature is not a valid ECDSA signature")
+	}
+	high := s.Bytes()
+	flipped := append(append([]byte(nil), signature[:coordinateLength]...), high[:]...)
+	if Secp256k1.Verify(Secp256k1.PublicKey(privateKey), flipped, hash[:]) {
+		t.Error("signature with a high S verifies")
+	}
+}
+
+func TestSecp256k1KeyRange(t *testing.T) {
+	order, _ := hex.DecodeString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	hash := sha256.Sum256([]byte("range"))
+	for _, privateKey := range [][]byte{make([]byte, coordinateLength), order, {1}} {
+		if _, err := Secp256k1.Sign(privateKey, hash[:]); err == nil {
+			t.Errorf("private key %x signs", privateKey)
+		}
+	}
+}
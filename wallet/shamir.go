@@ -0,0 +1,257 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+// constants for the shares of a backup
+const (
+	maxShares      = 255
+	keyLength      = 32
+	shareHeaderLen = checkSumLength + 2
+)
+
+// Share structure for one of the Shamir shares a private key is split into, any Threshold shares
+// with the same ID give the key back while fewer tell nothing about it, the ID is hashed from the
+// address of the key so that shares of different keys are never mixed and the result can be checked
+type Share struct {
+	ID        []byte
+	Threshold byte
+	Index     byte
+	Value     []byte
+}
+
+// gfExp and gfLog tables for multiplying in GF(256) with the AES polynomial x⁸ + x⁴ + x³ + x + 1
+var gfExp, gfLog = gfTables()
+
+// gfTables to build the powers of the generator 3 and their logarithms
+func gfTables() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = x, x
+		log[x] = byte(i)
+		// x·3 = x·2 + x, reducing by the polynomial when x·2 overflows
+		doubled := x << 1
+		if x&0x80 != 0 {
+			doubled ^= 0x1b
+		}
+		x ^= doubled
+	}
+	return exp, log
+}
+
+// gfMul to multiply two elements of GF(256)
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfDiv to divide an element of GF(256) by a non zero one
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// SplitSecret to split the secret into n shares of which any threshold give it back, every byte is
+// the constant term of its own random polynomial of degree threshold-1 evaluated at the share index
+func SplitSecret(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > maxShares {
+		return nil, fmt.Errorf("threshold must be 2 or more and at most the %d shares, which are at most %d", n, maxShares)
+	}
+	values := make([][]byte, n)
+	for i := range values {
+		values[i] = make([]byte, len(secret))
+	}
+	coefficients := make([]byte, threshold-1)
+	for position, secretByte := range secret {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, err
+		}
+		for i := range values {
+			x := byte(i + 1)
+			// Horner's rule from the highest coefficient down to the secret
+			y := byte(0)
+			for c := len(coefficients) - 1; c >= 0; c-- {
+				y = gfMul(y, x) ^ coefficients[c]
+			}
+			values[i][position] = gfMul(y, x) ^ secretByte
+		}
+	}
+	return values, nil
+}
+
+// CombineShares to interpolate the secret at zero from the values of shares at distinct indexes
+func CombineShares(indexes []byte, values [][]byte) ([]byte, error) {
+	if len(indexes) == 0 || len(indexes) != len(values) {
+		return nil, errors.New("no shares to combine")
+	}
+	secret := make([]byte, len(values[0]))
+	for i, xi := range indexes {
+		if xi == 0 || len(values[i]) != len(secret) {
+			return nil, errors.New("shares do not belong together")
+		}
+		// Lagrange basis at zero: the product of xj / (xj - xi), subtraction being XOR
+		basis := byte(1)
+		for j, xj := range indexes {
+			if i == j {
+				continue
+			}
+			if xi == xj {
+				return nil, fmt.Errorf("share %d is given more than once", xi)
+			}
+			basis = gfMul(basis, gfDiv(xj, xj^xi))
+		}
+		for position := range secret {
+			secret[position] ^= gfMul(basis, values[i][position])
+		}
+	}
+	return secret, nil
+}
+
+// shareID to hash the address of a key into the ID of its shares
+func shareID(address []byte) []byte {
+	hash := sha256.Sum256(address)
+	return hash[:checkSumLength]
+}
+
+// BackupShares to split the private key of the address into n shares of which threshold restore it,
+// the scheme of the key is split along with it
+func (wallets *Wallets) BackupShares(address string, n, threshold int) ([]Share, error) {
+	w, _, ok := wallets.KeyFor(address)
+	if !ok {
+		return nil, errors.New("address is not in the wallet")
+	}
+	if len(w.PrivateKey.D) > keyLength {
+		return nil, errors.New("private key is too long to back up")
+	}
+	secret := make([]byte, 1+keyLength)
+	for schemeID, name := range SchemeNames {
+		if name == w.PrivateKey.Scheme.Name() {
+			secret[0] = byte(schemeID)
+		}
+	}
+	copy(secret[1+keyLength-len(w.PrivateKey.D):], w.PrivateKey.D)
+	values, err := SplitSecret(secret, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+	id := shareID(w.Address())
+	var shares []Share
+	for i, value := range values {
+		shares = append(shares, Share{id, byte(threshold), byte(i + 1), value})
+	}
+	return shares, nil
+}
+
+// RestoreShares to recombine the shares of a backup into the Wallet they were split from,
+// failing unless they are enough shares of one backup and give back the key of the backed up address
+func RestoreShares(shares []Share) (*Wallet, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares given")
+	}
+	first := shares[0]
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("%d shares are needed, %d were given", first.Threshold, len(shares))
+	}
+	var indexes []byte
+	var values [][]byte
+	for _, share := range shares[:first.Threshold] {
+		if !bytes.Equal(share.ID, first.ID) || share.Threshold != first.Threshold {
+			return nil, errors.New("shares belong to different backups")
+		}
+		indexes = append(indexes, share.Index)
+		values = append(values, share.Value)
+	}
+	secret, err := CombineShares(indexes, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) != 1+keyLength || int(secret[0]) >= len(SchemeNames) {
+		return nil, errors.New("shares do not give back a private key")
+	}
+	scheme, _ := ParseScheme(SchemeNames[secret[0]])
+	private := PrivateKey{scheme, secret[1:]}
+	w := &Wallet{private, private.PublicKey()}
+	if !bytes.Equal(shareID(w.Address()), first.ID) {
+		return nil, errors.New("shares do not give back the backed up key")
+	}
+	return w, nil
+}
+
+// payload to lay the share out as its ID, threshold, index and value followed by a checksum
+func (share Share) payload() []byte {
+	payload := append(append([]byte{}, share.ID...), share.Threshold, share.Index)
+	payload = append(payload, share.Value...)
+	return append(payload, GenerateCheckSum(payload)...)
+}
+
+// Base58 to encode the share as a Base58 string
+func (share Share) Base58() string {
+	return string(Base58Encode(share.payload()))
+}
+
+// Mnemonic to encode the share as words, one per byte
+func (share Share) Mnemonic() string {
+	var words []string
+	for _, b := range share.payload() {
+		words = append(words, wordList[b])
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseShare to decode a share from its mnemonic or Base58 form, checking its checksum
+func ParseShare(encoded string) (Share, error) {
+	var payload []byte
+	words := strings.Fields(strings.ToLower(encoded))
+	if len(words) > 1 {
+		for _, word := range words {
+			b, ok := wordByte(word)
+			if !ok {
+				return Share{}, fmt.Errorf("%q is not a share word", word)
+			}
+			payload = append(payload, b)
+		}
+	} else {
+		decoded, err := base58.Decode(strings.TrimSpace(encoded))
+		if err != nil {
+			return Share{}, err
+		}
+		payload = decoded
+	}
+	if len(payload) <= shareHeaderLen+checkSumLength {
+		return Share{}, errors.New("share is too short")
+	}
+	body, checksum := payload[:len(payload)-checkSumLength], payload[len(payload)-checkSumLength:]
+	if !bytes.Equal(GenerateCheckSum(body), checksum) {
+		return Share{}, errors.New("share checksum does not match, it was mistyped")
+	}
+	return Share{
+		ID:        body[:checkSumLength],
+		Threshold: body[checkSumLength],
+		Index:     body[checkSumLength+1],
+		Value:     body[shareHeaderLen:],
+	}, nil
+}
+
+// wordByte to find the byte of a share word, by the whole word or its first four letters
+func wordByte(word string) (byte, bool) {
+	for b, candidate := range wordList {
+		if candidate == word || (len(word) >= 4 && strings.HasPrefix(candidate, word)) {
+			return byte(b), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestGF256(t *testing.T) {
+	// the multiplication example of FIPS 197 section 4.2
+	if product := gfMul(0x57, 0x83); product != 0xc1 {
+		t.Errorf("57 times 83 is %02x, want c1", product)
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if quotient := gfDiv(gfMul(byte(a), byte(b)), byte(b)); quotient != byte(a) {
+				t.Fatalf("%02x times %02x divided by %02x is %02x", a, b, b, quotient)
+			}
+		}
+	}
+}
+
+func TestSplitAndCombine(t *testing.T) {
+	secret := make([]byte, 1+keyLength)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	const n, threshold = 5, 3
+	values, err := SplitSecret(secret, n, threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every choice of shares of each size, in the order of the bits of the mask
+	for mask := 1; mask < 1<<n; mask++ {
+		var indexes []byte
+		var chosen [][]byte
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 {
+				indexes = append(indexes, byte(i+1))
+				chosen = append(chosen, values[i])
+			}
+		}
+		combined, err := CombineShares(indexes, chosen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enough := len(indexes) >= threshold; bytes.Equal(combined, secret) != enough {
+			t.Errorf("shares %v give back %x for the secret %x", indexes, combined, secret)
+		}
+	}
+	if _, err := CombineShares([]byte{1, 1, 2}, [][]byte{values[0], values[0], values[1]}); err == nil {
+		t.Error("a share given twice combines")
+	}
+	for _, bounds := range [][2]int{{3, 1}, {2, 3}, {256, 3}} {
+		if _, err := SplitSecret(secret, bounds[0], bounds[1]); err == nil {
+			t.Errorf("%d shares with a threshold of %d split", bounds[0], bounds[1])
+		}
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	wallets := Wallets{Wallets: make(map[string]*Wallet)}
+	for _, name := range SchemeNames {
+		scheme, err := ParseScheme(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := wallets.AddWallet(scheme)
+		shares, err := wallets.BackupShares(address, 5, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the shares go through their written forms on the way back
+		parsed := make([]Share, len(shares))
+		for i, share := range shares {
+			encoded := share.Base58()
+			if i%2 == 1 {
+				encoded = share.Mnemonic()
+			}
+			if parsed[i], err = ParseShare(encoded); err != nil {
+				t.Fatalf("%s share %d does not parse: %v", name, share.Index, err)
+			}
+		}
+		restored, err := RestoreShares([]Share{parsed[4], parsed[1], parsed[2]})
+		if err != nil {
+			t.Fatalf("%s shares do not restore: %v", name, err)
+		}
+		original := wallets.Wallets[address]
+		if restored.PrivateKey.Scheme != original.PrivateKey.Scheme || !bytes.Equal(restored.Address(), original.Address()) {
+			t.Errorf("%s shares restore %s, want %s", name, restored.Address(), original.Address())
+		}
+		if _, err := RestoreShares(parsed[:2]); err == nil {
+			t.Errorf("%s restores from 2 of 3 shares", name)
+		}
+		tampered := parsed[0]
+		tampered.Value = append([]byte(nil), tampered.Value...)
+		tampered.Value[0] ^= 1
+		if _, err := RestoreShares([]Share{tampered, parsed[1], parsed[2]}); err == nil {
+			t.Errorf("%s restores from a tampered share", name)
+		}
+		mistyped := []byte(shares[0].Base58())
+		mistyped[len(mistyped)/2] ^= 1
+		if _, err := ParseShare(string(mistyped)); err == nil {
+			t.Errorf("mistyped %s share parses", name)
+		}
+	}
+}
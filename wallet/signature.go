@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// signatureLength is the fixed width of a signature, R||S with each integer 32 bytes wide
+const signatureLength = 2 * coordinateLength
+
+// p256Sign to sign the hash with the P256 private key through crypto/ecdsa, which derives the nonce from the key
+// and the hash as RFC 6979 describes when no random source is given, so the same key and hash always give the same
+// signature, and S is moved into the lower half of the curve order so that nobody can flip it into a second valid one
+func p256Sign(privateKey *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	encoded, err := privateKey.Sign(nil, hash, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	var signature struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(encoded, &signature); err != nil || len(rest) > 0 {
+		return nil, errors.New("signature is not valid ASN.1")
+	}
+	return fixedWidthSignature(signature.R, signature.S, privateKey.Curve.Params().N), nil
+}
+
+// VerifySignature to verify a signature over the hash with an encoded public key, the scheme
+// tag of the public key decides how
+func VerifySignature(publicKey, signature, hash []byte) bool {
+	return SchemeOf(publicKey).Verify(publicKey, signature, hash)
+}
+
+// verify to verify a fixed width R||S signature over the hash, rejecting a high S
+func verify(publicKey *ecdsa.PublicKey, signature, hash []byte) bool {
+	r, s, ok := splitSignature(signature, publicKey.Curve.Params().N)
+	if !ok {
+		return false
+	}
+	return ecdsa.Verify(publicKey, hash, r, s)
+}
+
+// fixedWidthSignature to lay out R||S with each integer 32 bytes wide, moving S into the lower half of the order
+func fixedWidthSignature(r, s, order *big.Int) []byte {
+	if s.Cmp(halfOrder(order)) > 0 {
+		s = new(big.Int).Sub(order, s)
+	}
+	signature := make([]byte, signatureLength)
+	r.FillBytes(signature[:coordinateLength])
+	s.FillBytes(signature[coordinateLength:])
+	return signature
+}
+
+// splitSignature to read R and S of a fixed width signature, rejecting values out of range and a high S
+func splitSignature(signature []byte, order *big.Int) (*big.Int, *big.Int, bool) {
+	if len(signature) != signatureLength {
+		return nil, nil, false
+	}
+	r := new(big.Int).SetBytes(signature[:coordinateLength])
+	s := new(big.Int).SetBytes(signature[coordinateLength:])
+	if r.Sign() == 0 || r.Cmp(order) >= 0 || s.Sign() == 0 || s.Cmp(halfOrder(order)) > 0 {
+		return nil, nil, false
+	}
+	return r, s, true
+}
+
+// halfOrder to get half of the curve order, the largest S a signature may carry
+func halfOrder(order *big.Int) *big.Int {
+	return new(big.Int).Rsh(order, 1)
+}
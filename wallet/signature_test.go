@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// the P-256 key and the SHA-256 signatures of RFC 6979 A.2.5
+const rfc6979P256Key = "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721"
+
+var rfc6979P256Vectors = []struct {
+	message string
+	r, s    string
+}{
+	{
+		"sample",
+		"efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716",
+		"f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8",
+	},
+	{
+		"test",
+		"f1abb023518351cd71d881567b1ea663ed3efcf6c5132b354f28d3b0b7d38367",
+		"019f4113742a2b14bd25926b49c649155f267e60d3814b4c0cc84250e46f0083",
+	},
+}
+
+func TestP256SignRFC6979(t *testing.T) {
+	privateKey := p256Key(fromHex(rfc6979P256Key).Bytes())
+	n := elliptic.P256().Params().N
+	for _, vector := range rfc6979P256Vectors {
+		hash := sha256.Sum256([]byte(vector.message))
+		signature, err := p256Sign(privateKey, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the signature carries the low S
+		s := fromHex(vector.s)
+		if s.Cmp(halfOrder(n)) > 0 {
+			s.Sub(n, s)
+		}
+		want := fixedWidthSignature(fromHex(vector.r), s, n)
+		if hex.EncodeToString(signature) != hex.EncodeToString(want) {
+			t.Errorf("signature of %q is %x, want %x", vector.message, signature, want)
+		}
+		if !verify(&privateKey.PublicKey, signature, hash[:]) {
+			t.Errorf("signature of %q does not verify", vector.message)
+		}
+	}
+}
+
+func TestP256VerifyRejectsHighS(t *testing.T) {
+	privateKey := p256Key(fromHex(rfc6979P256Key).Bytes())
+	hash := sha256.Sum256([]byte("sample"))
+	signature, err := p256Sign(privateKey, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := elliptic.P256().Params().N
+	r, s, _ := splitSignature(signature, n)
+	flipped := make([]byte, signatureLength)
+	r.FillBytes(flipped[:coordinateLength])
+	new(big.Int).Sub(n, s).FillBytes(flipped[coordinateLength:])
+	if !ecdsa.Verify(&privateKey.PublicKey, hash[:], r, new(big.Int).Sub(n, s)) {
+		t.Fatal("high S form of the signature is not a valid ECDSA signature")
+	}
+	if verify(&privateKey.PublicKey, flipped, hash[:]) {
+		t.Error("signature with a high S verifies")
+	}
+}
+
+// fromHex to read a hexadecimal test value
+func fromHex(encoded string) *big.Int {
+	value, ok := new(big.Int).SetString(encoded, 16)
+	if !ok {
+		panic("invalid hexadecimal value " + encoded)
+	}
+	return value
+}
@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mr-tron/base58"
+	"github.com/the-code-innovator/go-blockchain/params"
+)
+
+// base58Alphabet holds the characters of a Base58 string, without 0, O, I and l
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// vanityRange to find the lowest and the highest address of the active network, the version byte
+// fixes how they start; the Base58 alphabet is in ASCII order, so addresses of one length compare as strings
+func vanityRange() (string, string) {
+	lowest := make([]byte, addressLength)
+	highest := bytes.Repeat([]byte{0xff}, addressLength)
+	lowest[0], highest[0] = params.Active().AddressVersion, params.Active().AddressVersion
+	return string(Base58Encode(lowest)), string(Base58Encode(highest))
+}
+
+// ValidateVanityPrefix to check that addresses of the active network can start with the prefix: it is
+// made of Base58 characters and lies between the lowest and the highest address of the version byte
+func ValidateVanityPrefix(prefix string) error {
+	if prefix == "" {
+		return errors.New("prefix is empty")
+	}
+	for _, character := range prefix {
+		if !strings.ContainsRune(base58Alphabet, character) {
+			return fmt.Errorf("%q is not a Base58 character, which exclude 0, O, I and l", character)
+		}
+	}
+	lowest, highest := vanityRange()
+	if params.Active().AddressVersion == 0 {
+		// the zero version byte is a leading 1 and the hash behind it takes any length
+		if prefix[0] != '1' {
+			return errors.New("addresses start with \"1\"")
+		}
+		return nil
+	}
+	if len(prefix) > len(highest) || prefix < lowest[:len(prefix)] || prefix > highest[:len(prefix)] {
+		return fmt.Errorf("addresses of %s run from %s to %s", params.Active().Name, lowest, highest)
+	}
+	return nil
+}
+
+// VanityDifficulty to estimate how many keys are tried on average before an address starts with the
+// prefix: the share of the addresses of the version byte that start with it, each character
+// after the version byte dividing the chance by 58
+func VanityDifficulty(prefix string) float64 {
+	lowest, highest := vanityRange()
+	if params.Active().AddressVersion == 0 {
+		return math.Pow(float64(len(base58Alphabet)), float64(len(prefix)-1))
+	}
+	// the addresses starting with the prefix are those between the prefix padded with the lowest and the highest digit
+	padding := len(highest) - len(prefix)
+	from := maxString(lowest, prefix+strings.Repeat("1", padding))
+	to := minString(highest, prefix+strings.Repeat("z", padding))
+	matching := new(big.Float).SetInt(new(big.Int).Sub(base58Value(to), base58Value(from)))
+	all := new(big.Float).SetInt(new(big.Int).Sub(base58Value(highest), base58Value(lowest)))
+	difficulty, _ := all.Quo(all, matching).Float64()
+	return difficulty
+}
+
+// base58Value to read a Base58 string as a number
+func base58Value(encoded string) *big.Int {
+	decoded, _ := base58.Decode(encoded)
+	return new(big.Int).SetBytes(decoded)
+}
+
+// minString to find the lower of two Base58 strings of the same length
+func minString(a, b string) string {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxString to find the higher of two Base58 strings of the same length
+func maxString(a, b string) string {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// VanityChance to estimate the chance of having found the prefix after trying the keys
+func VanityChance(difficulty float64, tried uint64) float64 {
+	return 1 - math.Exp(float64(tried)*math.Log1p(-1/difficulty))
+}
+
+// FindVanity to generate keys of the scheme on the workers until the address of one starts with the prefix
+// or the context is cancelled, counting the keys tried in tried as it goes
+func FindVanity(ctx context.Context, scheme SignatureScheme, prefix string, workers int, tried *uint64) (*Wallet, error) {
+	if err := ValidateVanityPrefix(prefix); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan *Wallet, workers)
+	var group sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for ctx.Err() == nil {
+				w := MakeWallet(scheme)
+				atomic.AddUint64(tried, 1)
+				if strings.HasPrefix(string(w.Address()), prefix) {
+					found <- w
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	group.Wait()
+	select {
+	case w := <-found:
+		return w, nil
+	default:
+		return nil, ctx.Err()
+	}
+}
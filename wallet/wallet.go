@@ -2,33 +2,34 @@ package wallet
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
+	"errors"
 
+	"github.com/mr-tron/base58"
+	"github.com/the-code-innovator/go-blockchain/params"
 	"golang.org/x/crypto/ripemd160"
 )
 
-// constants for version handling the blockchain
+// constants for address handling in the blockchain, the version bytes come from the active network
 const (
 	checkSumLength = 4
-	version        = byte(0x00)
+	hashLength     = 20
+	addressLength  = 1 + hashLength + checkSumLength
 )
 
 // Wallet structure for the Wallet type in the blockchain
 type Wallet struct {
-	PrivateKey ecdsa.PrivateKey
+	PrivateKey PrivateKey
 	PublicKey  []byte
 }
 
-// NewKeyPair to create a new KeyPair
-func NewKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+// NewKeyPair to create a new KeyPair of the scheme, the public key of P256 in its 33 byte SEC1 compressed form
+func NewKeyPair(scheme SignatureScheme) (PrivateKey, []byte) {
+	d, err := scheme.GenerateKey()
 	PanicHandle(err)
-	public := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-	return *private, public
+	private := PrivateKey{scheme, d}
+	return private, private.PublicKey()
 }
 
 // PublicKeyHash to create the public hash
@@ -48,9 +49,9 @@ func GenerateCheckSum(payload []byte) []byte {
 	return secondHash[:checkSumLength]
 }
 
-// MakeWallet to create a wallet
-func MakeWallet() *Wallet {
-	private, public := NewKeyPair()
+// MakeWallet to create a wallet signing with the scheme
+func MakeWallet(scheme SignatureScheme) *Wallet {
+	private, public := NewKeyPair(scheme)
 	wallet := Wallet{private, public}
 	return &wallet
 }
@@ -58,6 +59,52 @@ func MakeWallet() *Wallet {
 // Address to find the address of the Wallet
 func (w Wallet) Address() []byte {
 	publicKeyHash := PublicKeyHash(w.PublicKey)
+	return AddressFromPublicKeyHash(publicKeyHash)
+}
+
+// AddressFromPublicKeyHash to find the address that locks funds to the public key hash
+func AddressFromPublicKeyHash(publicKeyHash []byte) []byte {
+	return addressWithVersion(params.Active().AddressVersion, publicKeyHash)
+}
+
+// AddressFromScriptHash to find the address locking funds to the hash of a script,
+// such as a multi-signature account or a contract
+func AddressFromScriptHash(scriptHash []byte) []byte {
+	return addressWithVersion(params.Active().ScriptHashVersion, scriptHash)
+}
+
+// IsScriptHashAddress to check whether the address locks funds to the hash of a script
+func IsScriptHashAddress(address string) bool {
+	_, scriptHash, err := DecodeAddress(address)
+	return err == nil && scriptHash
+}
+
+// DecodeAddress to get the public key or script hash a Base58 or Bech32 address of the active network
+// locks funds to, and whether it is a script hash, failing for mistyped addresses and addresses of other networks
+func DecodeAddress(address string) ([]byte, bool, error) {
+	if hrp, data, constant, err := bech32Decode(address); err == nil {
+		return decodeBech32Address(hrp, data, constant)
+	}
+	fullHash, err := base58.Decode(address)
+	if err != nil || len(fullHash) != addressLength {
+		return nil, false, errors.New("address is not valid")
+	}
+	versionedHash := fullHash[:addressLength-checkSumLength]
+	if !bytes.Equal(GenerateCheckSum(versionedHash), fullHash[addressLength-checkSumLength:]) {
+		return nil, false, errors.New("address checksum does not match")
+	}
+	network := params.Active()
+	switch versionedHash[0] {
+	case network.AddressVersion:
+		return versionedHash[1:], false, nil
+	case network.ScriptHashVersion:
+		return versionedHash[1:], true, nil
+	}
+	return nil, false, errors.New("address is not for " + network.Name)
+}
+
+// addressWithVersion to encode the hash behind the version byte with its checksum
+func addressWithVersion(version byte, publicKeyHash []byte) []byte {
 	versionedHash := append([]byte{version}, publicKeyHash...)
 	checkSum := GenerateCheckSum(versionedHash)
 	fullHash := append(versionedHash, checkSum...)
@@ -65,12 +112,42 @@ func (w Wallet) Address() []byte {
 	return address
 }
 
-// ValidateAddress to validate the address that is passed into the blockchain
+// walletData structure for the persisted form of a Wallet, wallets from before schemes have no Scheme and are P256
+type walletData struct {
+	PrivateKey []byte
+	PublicKey  []byte
+	Scheme     string
+}
+
+// GobEncode to encode the Wallet without serializing the curve itself
+func (w Wallet) GobEncode() ([]byte, error) {
+	var content bytes.Buffer
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(walletData{w.PrivateKey.D, w.PublicKey, w.PrivateKey.Scheme.Name()})
+	return content.Bytes(), err
+}
+
+// GobDecode to rebuild the Wallet and the scheme of its key from the persisted form
+func (w *Wallet) GobDecode(data []byte) error {
+	var persisted walletData
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&persisted); err != nil {
+		return err
+	}
+	scheme := P256
+	if persisted.Scheme != "" {
+		var err error
+		if scheme, err = ParseScheme(persisted.Scheme); err != nil {
+			return err
+		}
+	}
+	w.PrivateKey = PrivateKey{scheme, persisted.PrivateKey}
+	w.PublicKey = persisted.PublicKey
+	return nil
+}
+
+// ValidateAddress to validate the address that is passed into the blockchain, which must be for the active network
 func ValidateAddress(address string) bool {
-	publicKeyHash := Base58Decode([]byte(address))
-	actualChecksum := publicKeyHash[len(publicKeyHash)-checkSumLength:]
-	actualVersion := publicKeyHash[0]
-	publicKeyHash = publicKeyHash[1 : len(publicKeyHash)-checkSumLength]
-	targetChecksum := GenerateCheckSum(append([]byte{actualVersion}, publicKeyHash...))
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	_, _, err := DecodeAddress(address)
+	return err == nil
 }
@@ -2,31 +2,55 @@ package wallet
 
 import (
 	"bytes"
-	"crypto/elliptic"
 	"encoding/gob"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 )
 
-const walletFile = "./tmp/wallets.data"
+// walletFile is where the wallets are kept, inside the data directory
+var walletFile = "./tmp/wallets.data"
+
+// SetDataDir to keep the wallets file in the data directory
+func SetDataDir(dir string) {
+	walletFile = filepath.Join(dir, "wallets.data")
+}
 
 // Wallets structure for map of wallets
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets       map[string]*Wallet
+	Change        map[string]bool
+	ChangeIndexes map[string]uint32
+	ChangePolicy  ChangePolicy
+	Multisigs     map[string]*Multisig
+	Contracts     map[string]*HTLC
+	Secrets       map[string][]byte
+	Legacy        map[string]string
+	MuSigs        map[string]*MuSig
+	Nonces        map[string][]byte
 }
 
 // CreateWallets to create a wallets file
 func CreateWallets() (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Change = make(map[string]bool)
+	wallets.ChangeIndexes = make(map[string]uint32)
+	wallets.Multisigs = make(map[string]*Multisig)
+	wallets.Contracts = make(map[string]*HTLC)
+	wallets.Secrets = make(map[string][]byte)
+	wallets.Legacy = make(map[string]string)
+	wallets.MuSigs = make(map[string]*MuSig)
+	wallets.Nonces = make(map[string][]byte)
 	err := wallets.LoadFile()
 	return &wallets, err
 }
 
-// AddWallet to add the wallet to the wallets file
-func (wallets *Wallets) AddWallet() string {
-	wallet := MakeWallet()
+// AddWallet to add a wallet signing with the scheme to the wallets file
+func (wallets *Wallets) AddWallet(scheme SignatureScheme) string {
+	wallet := MakeWallet(scheme)
 	address := fmt.Sprintf("%s", wallet.Address())
 	wallets.Wallets[address] = wallet
 	return address
@@ -37,12 +61,88 @@ func (wallets Wallets) GetWallet(address string) Wallet {
 	return *wallets.Wallets[address]
 }
 
+// GetWalletByPublicKey to find the wallet holding the private key of the public key, in whichever form it is encoded
+func (wallets *Wallets) GetWalletByPublicKey(publicKey []byte) (*Wallet, bool) {
+	w, _, ok := wallets.KeyFor(string(AddressFromPublicKeyHash(PublicKeyHash(publicKey))))
+	if ok {
+		return w, true
+	}
+	parsed, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, false
+	}
+	w, ok = wallets.Wallets[string(AddressFromPublicKeyHash(PublicKeyHash(EncodePublicKey(parsed, true))))]
+	return w, ok
+}
+
+// KeyFor to find the wallet owning the address, in either format, and the encoding of its public key the address
+// commits to, which for an address from before the migration is the X||Y form rather than the wallet's own
+func (wallets *Wallets) KeyFor(address string) (*Wallet, []byte, bool) {
+	address = Base58Address(address)
+	if w, ok := wallets.Wallets[address]; ok {
+		return w, w.PublicKey, true
+	}
+	migrated, ok := wallets.Legacy[address]
+	if !ok {
+		return nil, nil, false
+	}
+	w, ok := wallets.Wallets[migrated]
+	if !ok {
+		return nil, nil, false
+	}
+	return w, legacyPublicKey(&p256Key(w.PrivateKey.D).PublicKey), true
+}
+
+// MigrateKeys to re-encode every X||Y public key in the wallets in SEC1 compressed form, the wallet moves
+// to the address of the new form and the old address is remembered so its funds can still be spent,
+// returning the old address of every migrated wallet mapped to its new one
+func (wallets *Wallets) MigrateKeys() map[string]string {
+	migrated := make(map[string]string)
+	for address, w := range wallets.Wallets {
+		if !IsLegacyPublicKey(w.PublicKey) {
+			continue
+		}
+		w.PublicKey = w.PrivateKey.PublicKey()
+		newAddress := string(w.Address())
+		delete(wallets.Wallets, address)
+		wallets.Wallets[newAddress] = w
+		if wallets.Change[address] {
+			delete(wallets.Change, address)
+			wallets.Change[newAddress] = true
+		}
+		wallets.Legacy[address] = newAddress
+		migrated[address] = newAddress
+	}
+	return migrated
+}
+
+// GetLegacyAddresses to get the addresses of the wallets from before the migration
+func (wallets *Wallets) GetLegacyAddresses() []string {
+	var addresses []string
+	for address := range wallets.Legacy {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// GetMultisigAddresses to get the multi-signature addresses in the wallets file
+func (wallets *Wallets) GetMultisigAddresses() []string {
+	var addresses []string
+	for address := range wallets.Multisigs {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
 // GetAllAddresses to get the addresses in the wallets file
 func (wallets *Wallets) GetAllAddresses() []string {
 	var addresses []string
 	for address := range wallets.Wallets {
 		addresses = append(addresses, address)
 	}
+	sort.Strings(addresses)
 	return addresses
 }
 
@@ -53,19 +153,46 @@ func (wallets *Wallets) LoadFile() error {
 	}
 	var walletsLocal Wallets
 	fileContent, err := ioutil.ReadFile(walletFile)
-	ReturnHandle(err)
-	gob.Register(elliptic.P256())
+	if err != nil {
+		return err
+	}
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&walletsLocal)
-	ReturnHandle(err)
+	if err != nil {
+		return err
+	}
 	wallets.Wallets = walletsLocal.Wallets
+	if walletsLocal.Change != nil {
+		wallets.Change = walletsLocal.Change
+	}
+	if walletsLocal.ChangeIndexes != nil {
+		wallets.ChangeIndexes = walletsLocal.ChangeIndexes
+	}
+	wallets.ChangePolicy = walletsLocal.ChangePolicy
+	if walletsLocal.Multisigs != nil {
+		wallets.Multisigs = walletsLocal.Multisigs
+	}
+	if walletsLocal.Contracts != nil {
+		wallets.Contracts = walletsLocal.Contracts
+	}
+	if walletsLocal.Secrets != nil {
+		wallets.Secrets = walletsLocal.Secrets
+	}
+	if walletsLocal.Legacy != nil {
+		wallets.Legacy = walletsLocal.Legacy
+	}
+	if walletsLocal.MuSigs != nil {
+		wallets.MuSigs = walletsLocal.MuSigs
+	}
+	if walletsLocal.Nonces != nil {
+		wallets.Nonces = walletsLocal.Nonces
+	}
 	return nil
 }
 
 // SaveFile to save the file after edit
 func (wallets *Wallets) SaveFile() {
 	var content bytes.Buffer
-	gob.Register(elliptic.P256())
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(wallets)
 	PanicHandle(err)
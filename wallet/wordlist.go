@@ -0,0 +1,38 @@
+package wallet
+
+// wordList holds one word per byte value for the mnemonic form of a Share, no two words
+// share their first four letters so a word can be told by its prefix
+var wordList = [256]string{
+	"acid", "acorn", "actor", "adult", "aisle", "alarm", "album", "alley",
+	"amber", "angle", "ankle", "apple", "apron", "arena", "armor", "arrow",
+	"atlas", "attic", "audio", "autumn", "avocado", "badge", "bagel", "baker",
+	"bamboo", "banjo", "barn", "basil", "basket", "beach", "beard", "beetle",
+	"bench", "berry", "bicycle", "bison", "blade", "blanket", "blossom", "boat",
+	"bonus", "border", "bottle", "boxer", "bracket", "bread", "brick", "bridge",
+	"broom", "bubble", "bucket", "buffalo", "bugle", "butter", "cabin", "cactus",
+	"camel", "candle", "canoe", "canyon", "carbon", "carpet", "castle", "cattle",
+	"cedar", "cellar", "cement", "cherry", "cider", "cinema", "circus", "citrus",
+	"claw", "clock", "cloud", "clover", "cobra", "cocoa", "collar", "comet",
+	"copper", "coral", "cotton", "cougar", "cradle", "crater", "crayon", "dagger",
+	"daisy", "dancer", "debris", "delta", "denim", "desert", "dinner", "domino",
+	"donkey", "dragon", "drawer", "dryer", "eagle", "earth", "echo", "elbow",
+	"elder", "ember", "engine", "fabric", "falcon", "fence", "ferry", "fiber",
+	"fiddle", "finger", "flame", "flute", "forest", "fossil", "fox", "galaxy",
+	"garden", "garlic", "giant", "ginger", "glove", "goblet", "grape", "gravel",
+	"guitar", "hammer", "harbor", "hazel", "helmet", "heron", "hockey", "honey",
+	"hornet", "hotel", "husky", "igloo", "insect", "island", "ivory", "jacket",
+	"jaguar", "jelly", "jewel", "jungle", "kayak", "kernel", "kettle", "kidney",
+	"kitten", "koala", "ladder", "lagoon", "laptop", "lemon", "lizard", "locket",
+	"lumber", "magnet", "mango", "maple", "marble", "meadow", "melon", "mirror",
+	"mitten", "monkey", "mosaic", "muffin", "napkin", "nectar", "needle", "nickel",
+	"noodle", "nutmeg", "oasis", "ocean", "olive", "onion", "orange", "orchid",
+	"otter", "oyster", "paddle", "palace", "panda", "parrot", "peanut", "pebble",
+	"pepper", "piano", "pigeon", "pillow", "pilot", "pirate", "planet", "plum",
+	"pocket", "pony", "potato", "puzzle", "quartz", "quiver", "rabbit", "radar",
+	"radish", "raven", "record", "ribbon", "rocket", "saddle", "salmon", "sandal",
+	"scarf", "shadow", "shovel", "silver", "sketch", "spider", "sponge", "stable",
+	"statue", "sugar", "summer", "sunset", "tablet", "tanker", "teapot", "tennis",
+	"tiger", "timber", "tomato", "tongue", "torch", "tulip", "tunnel", "turtle",
+	"valley", "velvet", "violin", "wagon", "walnut", "walrus", "whale", "wheat",
+	"willow", "window", "winter", "wizard", "yacht", "yogurt", "zebra", "zipper",
+}